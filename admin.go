@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AdminTokenEnv serve 命令运维接口令牌的环境变量
+const AdminTokenEnv = "ADMIN_TOKEN"
+
+// logLevelRevert 临时日志级别到期后的恢复任务
+var logLevelRevert struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	at       time.Time
+	previous string
+}
+
+// SetLogLevel 修改 db 的 SQL 日志级别（silent/error/warn/info），并取消 SetLogLevelFor 尚未执行的恢复
+func SetLogLevel(db *gorm.DB, level string) error {
+	return SetLogLevelFor(db, level, 0)
+}
+
+// SetLogLevelFor 临时修改 SQL 日志级别，d 之后恢复为修改前的级别，d <= 0 时不恢复。
+// 排查故障时可临时打开 info 日志而不必记得改回：
+//
+//	SetLogLevelFor(db, "info", 15*time.Minute)
+func SetLogLevelFor(db *gorm.DB, level string, d time.Duration) error {
+	logLevelRevert.mu.Lock()
+	defer logLevelRevert.mu.Unlock()
+
+	previous, _ := LogLevel(db)
+	if err := ApplyRuntimeSettings(db, RuntimeSettings{LogLevel: level}); err != nil {
+		return err
+	}
+	if t := logLevelRevert.timer; t != nil {
+		t.Stop()
+		// 连续临时修改时恢复为最初的级别
+		previous = logLevelRevert.previous
+		logLevelRevert.timer = nil
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		logLevelRevert.mu.Lock()
+		defer logLevelRevert.mu.Unlock()
+		if logLevelRevert.timer != t {
+			return
+		}
+		logLevelRevert.timer = nil
+		log.Printf("临时日志级别已到期，恢复为 %s", logLevelRevert.previous)
+		if err := ApplyRuntimeSettings(db, RuntimeSettings{LogLevel: logLevelRevert.previous}); err != nil {
+			log.Printf("恢复日志级别失败: %v", err)
+		}
+	})
+	logLevelRevert.timer, logLevelRevert.at, logLevelRevert.previous = t, time.Now().Add(d), previous
+	return nil
+}
+
+// deferLogLevel 临时级别生效期间把到期后恢复的级别改为 level，返回是否已推迟；没有临时级别时返回 false
+func deferLogLevel(level string) bool {
+	logLevelRevert.mu.Lock()
+	defer logLevelRevert.mu.Unlock()
+	if level == "" || logLevelRevert.timer == nil {
+		return false
+	}
+	logLevelRevert.previous = level
+	return true
+}
+
+// LogLevel db 当前的 SQL 日志级别，db 未使用 ReloadableLogger 时返回 false
+func LogLevel(db *gorm.DB) (string, bool) {
+	rl, ok := db.Config.Logger.(*ReloadableLogger)
+	if !ok {
+		return "", false
+	}
+	return logLevelName(rl.Config().LogLevel), true
+}
+
+// ReloadOnSIGHUP 收到 SIGHUP 时立即重新加载配置文件中可热加载的设置（kill -HUP <pid>），直到 ctx 取消；logLevel 见 WatchConfig
+func ReloadOnSIGHUP(ctx context.Context, db *gorm.DB, path, profile, logLevel string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			log.Printf("收到 SIGHUP，重新加载配置 %s", path)
+			reloadConfig(db, path, profile, logLevel)
+		}
+	}
+}
+
+// logLevelRequest 修改日志级别的请求体，duration 为空时不自动恢复
+type logLevelRequest struct {
+	Level    string `json:"level" validate:"required,oneof=silent error warn info"`
+	Duration string `json:"duration"`
+}
+
+// logLevelResponse 当前日志级别，revert_at 为临时级别的恢复时间
+type logLevelResponse struct {
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// RegisterAdminRoutes 注册运维接口，请求需携带 Authorization: Bearer <token>：
+//
+//	GET /admin/log-level
+//	PUT /admin/log-level  {"level": "info", "duration": "15m"}
+//	GET /admin/replicas   启用读写分离时返回各副本的延迟与是否在读轮换中
+//	POST /admin/jobs      {"query": "user_stats", "params": {}} 提交异步查询作业
+//	GET /admin/jobs/:id   查询作业状态与结果
+func RegisterAdminRoutes(r gin.IRouter, db *gorm.DB, token string) {
+	g := r.Group("/admin", func(c *gin.Context) {
+		got := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) != 1 {
+			writeProblem(c, http.StatusUnauthorized, "需要管理令牌", nil)
+		}
+	})
+	g.GET("/log-level", func(c *gin.Context) {
+		c.JSON(http.StatusOK, currentLogLevel(db))
+	})
+	g.PUT("/log-level", func(c *gin.Context) {
+		var req logLevelRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		var d time.Duration
+		if req.Duration != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+				writeProblem(c, http.StatusBadRequest, "duration 不是合法的时长: "+req.Duration, nil)
+				return
+			}
+		}
+		if err := SetLogLevelFor(db, req.Level, d); err != nil {
+			writeProblem(c, http.StatusConflict, err.Error(), nil)
+			return
+		}
+		log.Printf("%s 通过管理接口将 SQL 日志级别改为 %s（%s）", c.ClientIP(), req.Level, req.Duration)
+		c.JSON(http.StatusOK, currentLogLevel(db))
+	})
+	if replicaRouter != nil {
+		g.GET("/replicas", func(c *gin.Context) {
+			c.JSON(http.StatusOK, replicaRouter.Status())
+		})
+	}
+	registerJobRoutes(g, db)
+}
+
+// registerJobRoutes 注册异步查询作业接口：POST /admin/jobs 提交（202，Location 指向作业），GET /admin/jobs/:id 轮询结果
+func registerJobRoutes(g gin.IRouter, db *gorm.DB) {
+	q, err := NewJobQueue(db, JobQueueConfig{})
+	if err != nil {
+		log.Printf("查询作业接口未注册: %v", err)
+		return
+	}
+	g.POST("/jobs", func(c *gin.Context) {
+		var spec QuerySpec
+		if !bindJSON(c, &spec) {
+			return
+		}
+		if _, ok := queryHandler(spec.Query); !ok {
+			writeProblem(c, http.StatusBadRequest, fmt.Sprintf("查询 %s 未注册，可用的查询: %v", spec.Query, RegisteredQueries()), nil)
+			return
+		}
+		id, err := q.SubmitQuery(c.Request.Context(), spec)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Header("Location", fmt.Sprintf("/admin/jobs/%d", id))
+		c.JSON(http.StatusAccepted, gin.H{"id": id})
+	})
+	g.GET("/jobs/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, "作业编号无效", nil)
+			return
+		}
+		job, err := q.JobResult(c.Request.Context(), JobID(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeProblem(c, http.StatusNotFound, "作业不存在", nil)
+			return
+		}
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+}
+
+func currentLogLevel(db *gorm.DB) logLevelResponse {
+	level, _ := LogLevel(db)
+	resp := logLevelResponse{Level: level}
+	logLevelRevert.mu.Lock()
+	defer logLevelRevert.mu.Unlock()
+	if logLevelRevert.timer != nil {
+		at := logLevelRevert.at
+		resp.RevertAt = &at
+	}
+	return resp
+}
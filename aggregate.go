@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AggFunc 聚合函数
+type AggFunc string
+
+const (
+	AggCount         AggFunc = "COUNT"
+	AggCountDistinct AggFunc = "COUNT_DISTINCT"
+	AggSum           AggFunc = "SUM"
+	AggAvg           AggFunc = "AVG"
+	AggMin           AggFunc = "MIN"
+	AggMax           AggFunc = "MAX"
+)
+
+// Aggregation 单个聚合项，如 {Func: AggAvg, Column: "age", Alias: "avg_age"}
+// Column 必须是模型的列名，为空时仅 AggCount 合法（COUNT(*)）；Alias 为空时默认为 func_column
+type Aggregation struct {
+	Func   AggFunc
+	Column string
+	Alias  string
+}
+
+// GroupExpr 分组表达式，Expr 必须是模型的列名，如 {Expr: "status"}；
+// 按时间分组等 SQL 表达式由 TimeBucket 构造，不接受任意 SQL
+type GroupExpr struct {
+	Expr  string
+	Alias string
+
+	trusted bool // Expr 由本包构造的 SQL 表达式，不按列名校验
+}
+
+// AggregateSpec 聚合查询描述
+type AggregateSpec struct {
+	GroupBy      []GroupExpr
+	Aggregations []Aggregation
+	Filter       Spec     // 过滤条件，作用于 WHERE
+	Having       string   // 分组过滤，如 "COUNT(*) > ?"，会直接拼接进 SQL，只能使用常量
+	HavingArgs   []any    // Having 的参数
+	OrderBy      []string // 排序，为别名或列名，可带 ASC/DESC，如 "month DESC"
+}
+
+// Row 聚合结果行，键为分组别名/聚合别名
+type Row = map[string]any
+
+// Aggregate 分组聚合查询，例如按注册月份统计平均年龄：
+//
+//	rows, err := repo.Aggregate(ctx, AggregateSpec{
+//		GroupBy:      []GroupExpr{TimeBucket("created_at", BucketMonth, "month")},
+//		Aggregations: []Aggregation{{Func: AggAvg, Column: "age", Alias: "avg_age"}},
+//		OrderBy:      []string{"month"},
+//	})
+func (r *BaseRepository[T, ID]) Aggregate(ctx context.Context, agg AggregateSpec) ([]Row, error) {
+	return invoke(ctx, r, "Aggregate", OpRead, func(ctx context.Context) ([]Row, error) { return r.aggregate(ctx, agg) }, agg)
+}
+
+func (r *BaseRepository[T, ID]) aggregate(ctx context.Context, agg AggregateSpec) ([]Row, error) {
+	if len(agg.Aggregations) == 0 {
+		return nil, errors.New("聚合查询至少需要一个聚合项")
+	}
+
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	column := func(name string) (string, error) {
+		f := s.LookUpField(name)
+		if f == nil || f.DBName == "" {
+			return "", fmt.Errorf("表 %s 没有列 %s", s.Table, name)
+		}
+		return r.db.Statement.Quote(f.DBName), nil
+	}
+
+	selects := make([]string, 0, len(agg.GroupBy)+len(agg.Aggregations))
+	groups := make([]string, 0, len(agg.GroupBy))
+	aliases := make([]string, 0, cap(selects))
+	for _, g := range agg.GroupBy {
+		expr := g.Expr
+		if !g.trusted {
+			if expr, err = column(g.Expr); err != nil {
+				return nil, err
+			}
+		}
+		alias := g.Alias
+		if alias == "" {
+			alias = g.Expr
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, r.db.Statement.Quote(alias)))
+		groups = append(groups, expr)
+		aliases = append(aliases, alias)
+	}
+	for _, a := range agg.Aggregations {
+		col := ""
+		if a.Column != "" {
+			if col, err = column(a.Column); err != nil {
+				return nil, err
+			}
+		}
+		expr, err := a.expr(col)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, r.db.Statement.Quote(a.alias())))
+		aliases = append(aliases, a.alias())
+	}
+
+	db := newQueryOptions(agg.Filter).filter(r.session(ctx).Model(new(T)))
+	db = db.Select(strings.Join(selects, ", "))
+	for _, g := range groups {
+		db = db.Group(g)
+	}
+	if agg.Having != "" {
+		db = db.Having(agg.Having, agg.HavingArgs...)
+	}
+	for _, o := range agg.OrderBy {
+		name, dir, _ := strings.Cut(strings.TrimSpace(o), " ")
+		dir = strings.ToUpper(strings.TrimSpace(dir))
+		if dir != "" && dir != "ASC" && dir != "DESC" {
+			return nil, fmt.Errorf("无效的排序: %s", o)
+		}
+		expr := r.db.Statement.Quote(name)
+		if !slices.Contains(aliases, name) {
+			if expr, err = column(name); err != nil {
+				return nil, err
+			}
+		}
+		db = db.Order(strings.TrimSpace(expr + " " + dir))
+	}
+
+	var rows []Row
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("聚合查询失败: %w", err)
+	}
+	return rows, nil
+}
+
+// expr 聚合表达式，col 为已校验并加引号的列名
+func (a Aggregation) expr(col string) (string, error) {
+	switch a.Func {
+	case AggCount:
+		if col == "" {
+			return "COUNT(*)", nil
+		}
+		return fmt.Sprintf("COUNT(%s)", col), nil
+	case AggCountDistinct:
+		if col == "" {
+			return "", errors.New("COUNT DISTINCT 需要指定列")
+		}
+		return fmt.Sprintf("COUNT(DISTINCT %s)", col), nil
+	case AggSum, AggAvg, AggMin, AggMax:
+		if col == "" {
+			return "", fmt.Errorf("%s 需要指定列", a.Func)
+		}
+		return fmt.Sprintf("%s(%s)", a.Func, col), nil
+	default:
+		return "", fmt.Errorf("不支持的聚合函数: %s", a.Func)
+	}
+}
+
+func (a Aggregation) alias() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	if a.Column == "" {
+		return strings.ToLower(string(a.Func))
+	}
+	return strings.ToLower(string(a.Func)) + "_" + a.Column
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// AnonymizeFunc 脱敏策略：就地修改字段值，key 为 Anonymizer 的密钥，用于生成确定性的假值
+type AnonymizeFunc func(field reflect.Value, key []byte) error
+
+var (
+	anonymizersMu sync.RWMutex
+	anonymizers   = map[string]AnonymizeFunc{
+		"hash":       anonymizeHash,
+		"mask_email": anonymizeEmail,
+		"mask":       anonymizeMask,
+		"null":       anonymizeNull,
+	}
+)
+
+// RegisterAnonymizer 注册脱敏策略，之后可在 anonymize 标签中按名称引用
+func RegisterAnonymizer(name string, fn AnonymizeFunc) {
+	anonymizersMu.Lock()
+	defer anonymizersMu.Unlock()
+	anonymizers[name] = fn
+}
+
+// Anonymizer 按字段上的 anonymize 标签对实体脱敏，用于把生产数据导出或复制到测试环境
+//
+//	type User struct {
+//		Name  string  `anonymize:"hash"`       // 确定性哈希，同一个值在不同表/批次中结果一致
+//		Email string  `anonymize:"mask_email"` // 保持邮箱格式与唯一性
+//		Phone *string `anonymize:"null"`       // 置空（非指针类型置零值）
+//		Age   int     `anonymize:"fixed=30"`   // 替换为固定的占位值，用于非空且有取值校验的字段
+//	}
+//
+// 哈希使用 HMAC-SHA256，不知道密钥时无法通过枚举常见值反推原值
+type Anonymizer struct {
+	key []byte
+}
+
+// NewAnonymizer 创建脱敏器，key 应为随机密钥且不随数据一起分发
+func NewAnonymizer(key string) *Anonymizer {
+	return &Anonymizer{key: []byte(key)}
+}
+
+// Apply 对实体（结构体指针）就地脱敏，包括嵌入的结构体
+func (a *Anonymizer) Apply(entity any) error {
+	rv := reflect.ValueOf(entity)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("脱敏需要结构体指针，传入的是 %T", entity)
+	}
+	return a.apply(rv.Elem())
+}
+
+func (a *Anonymizer) apply(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := a.apply(v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name := sf.Tag.Get("anonymize")
+		if name == "" || name == "-" {
+			continue
+		}
+		anonymizersMu.RLock()
+		fn, ok := anonymizers[name]
+		anonymizersMu.RUnlock()
+		if value, isFixed := strings.CutPrefix(name, "fixed="); isFixed {
+			fn, ok = anonymizeFixed(value), true
+		}
+		if !ok {
+			return fmt.Errorf("字段 %s.%s: 未知的脱敏策略 %q", t.Name(), sf.Name, name)
+		}
+		if err := fn(v.Field(i), a.key); err != nil {
+			return fmt.Errorf("字段 %s.%s: %w", t.Name(), sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// CopyAnonymized 把 src 中满足 spec 的实体分批脱敏后写入 dst（如生产库 -> 测试库），返回复制的行数
+// dst 中的表需已存在；主键原样保留，因此可重复执行前应先清空目标表
+func CopyAnonymized[T any](ctx context.Context, src, dst *gorm.DB, a *Anonymizer, spec Spec, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	var copied int64
+	var batch []*T
+	err := newQueryOptions(spec).filter(src.WithContext(ctx).Model(new(T))).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for _, entity := range batch {
+			if err := a.Apply(entity); err != nil {
+				return err
+			}
+		}
+		if err := dst.WithContext(ctx).Create(batch).Error; err != nil {
+			return fmt.Errorf("写入脱敏数据失败: %w", err)
+		}
+		copied += int64(len(batch))
+		return nil
+	}).Error
+	return copied, err
+}
+
+// hmacHex 计算 value 的 HMAC-SHA256 十六进制摘要的前 n 位
+func hmacHex(key []byte, value string, n int) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:n]
+}
+
+// anonymizeHash 字符串替换为确定性哈希
+func anonymizeHash(field reflect.Value, key []byte) error {
+	if field.Kind() != reflect.String {
+		return fmt.Errorf("hash 策略只支持字符串，字段类型为 %s", field.Type())
+	}
+	if field.String() != "" {
+		field.SetString("anon_" + hmacHex(key, field.String(), 12))
+	}
+	return nil
+}
+
+// anonymizeEmail 邮箱替换为 <哈希>@example.com，保持格式合法且不同邮箱结果不同（不破坏唯一索引）
+func anonymizeEmail(field reflect.Value, key []byte) error {
+	if field.Kind() != reflect.String {
+		return fmt.Errorf("mask_email 策略只支持字符串，字段类型为 %s", field.Type())
+	}
+	if email := strings.ToLower(field.String()); email != "" {
+		field.SetString(hmacHex(key, email, 16) + "@example.com")
+	}
+	return nil
+}
+
+// anonymizeMask 保留首尾字符，中间替换为 *，如 13812345678 -> 1*********8
+func anonymizeMask(field reflect.Value, _ []byte) error {
+	if field.Kind() != reflect.String {
+		return fmt.Errorf("mask 策略只支持字符串，字段类型为 %s", field.Type())
+	}
+	r := []rune(field.String())
+	if len(r) <= 2 {
+		field.SetString(strings.Repeat("*", len(r)))
+		return nil
+	}
+	field.SetString(string(r[0]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1]))
+	return nil
+}
+
+// anonymizeNull 置为零值（指针类型即 NULL）
+func anonymizeNull(field reflect.Value, _ []byte) error {
+	field.SetZero()
+	return nil
+}
+
+// anonymizeFixed 替换为标签中给出的占位值（fixed=<值>），按字段类型解析，空值保持不变
+func anonymizeFixed(value string) AnonymizeFunc {
+	return func(field reflect.Value, _ []byte) error {
+		if field.IsZero() {
+			return nil
+		}
+		if field.Kind() == reflect.String {
+			field.SetString(value)
+			return nil
+		}
+		v := reflect.New(field.Type())
+		if _, err := fmt.Sscan(value, v.Interface()); err != nil {
+			return fmt.Errorf("占位值 %q 无法解析为 %s: %w", value, field.Type(), err)
+		}
+		field.Set(v.Elem())
+		return nil
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// 脱敏后的用户仍需满足模型的校验规则，才能写入目标库或经 API 回放
+func TestAnonymizedUserValid(t *testing.T) {
+	user := &User{Name: "zhangsan_with_a_long_name", Email: "ZhangSan@Example.com", Age: 87}
+	if err := NewAnonymizer("test-key").Apply(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Age != 30 {
+		t.Errorf("Age 脱敏为 %d，期望占位值 30", user.Age)
+	}
+	if err := binding.Validator.ValidateStruct(user); err != nil {
+		t.Errorf("脱敏后的用户未通过校验: %v", err)
+	}
+}
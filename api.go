@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultPageSize 未指定 limit 时每页的行数
+	defaultPageSize = 20
+	// maxPageSize 单页最多返回的行数
+	maxPageSize = 100
+)
+
+func init() {
+	// 模型上的校验规则写在 validate 标签中，让 gin 的绑定校验使用同一套标签
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.SetTagName("validate")
+	}
+}
+
+// Problem RFC 7807 错误响应（application/problem+json）
+type Problem struct {
+	Type     string       `json:"type" example:"about:blank"`
+	Title    string       `json:"title" example:"Bad Request"`
+	Status   int          `json:"status" example:"400"`
+	Detail   string       `json:"detail,omitempty" example:"请求参数校验失败"`
+	Instance string       `json:"instance,omitempty" example:"/users"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field string `json:"field" example:"email"`
+	Rule  string `json:"rule" example:"email"`
+	Param string `json:"param,omitempty"`
+}
+
+// UserInput 创建/更新用户的请求体
+type UserInput struct {
+	Name   string     `json:"name" validate:"required,max=20" example:"john_doe"`
+	Email  string     `json:"email" validate:"required,email" example:"john@example.com"`
+	Age    int        `json:"age" validate:"min=0,max=120" example:"30"`
+	Status UserStatus `json:"status,omitempty" validate:"omitempty,oneof=active disabled" example:"active"`
+}
+
+// UserPage 用户分页结果，具名类型使接口文档中的模型名为 UserPage
+type UserPage Page[User]
+
+// UserHandler 用户资源的 REST 接口
+type UserHandler struct {
+	repo UserRepository
+}
+
+// NewUserHandler 创建用户接口
+func NewUserHandler(repo UserRepository) *UserHandler {
+	return &UserHandler{repo: repo}
+}
+
+// RegisterRoutes 按 userOperations 注册路由，接口文档（OpenAPISpec）由同一张表生成：
+//
+//	GET    /users?offset=0&limit=20&name=张&status=active&min_age=18&max_age=60
+//	POST   /users
+//	GET    /users/stats
+//	GET    /users/:id
+//	PUT    /users/:id
+//	DELETE /users/:id
+func (h *UserHandler) RegisterRoutes(r gin.IRouter) {
+	for _, op := range userOperations {
+		handle := op.handler
+		r.Handle(strings.ToUpper(op.method), op.ginPath(), func(c *gin.Context) { handle(h, c) })
+	}
+}
+
+// NewServer 创建 HTTP 服务，每个请求挂载 Loader 容器；接口文档见 /openapi.json 与 /docs
+func NewServer(repo UserRepository) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Logger(), gin.Recovery(), func(c *gin.Context) {
+		c.Request = c.Request.WithContext(WithLoaders(c.Request.Context()))
+		c.Next()
+	})
+	if replicaRouter != nil {
+		r.Use(ConsistencyMiddleware(replicaRouter))
+	}
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	RegisterOpenAPI(r)
+	NewUserHandler(repo).RegisterRoutes(r)
+	return r
+}
+
+// list 分页查询用户
+func (h *UserHandler) list(c *gin.Context) {
+	offset, err1 := queryInt(c, "offset", 0)
+	limit, err2 := queryInt(c, "limit", defaultPageSize)
+	minAge, err3 := queryInt(c, "min_age", -1)
+	maxAge, err4 := queryInt(c, "max_age", -1)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		writeProblem(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if limit > maxPageSize {
+		writeProblem(c, http.StatusBadRequest, fmt.Sprintf("limit 不能超过 %d", maxPageSize), nil)
+		return
+	}
+	offset, limit = max(offset, 0), max(limit, 1)
+
+	opts := []QueryOption{OrderBy("id")}
+	if name := c.Query("name"); name != "" {
+		opts = append(opts, Where("name ILIKE ?", "%"+escapeLike(name)+"%"))
+	}
+	if status := c.Query("status"); status != "" {
+		if err := ValidateEnum(UserStatus(status)); err != nil {
+			writeProblem(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		opts = append(opts, Where("status = ?", status))
+	}
+	if minAge >= 0 {
+		opts = append(opts, Where("age >= ?", minAge))
+	}
+	if maxAge >= 0 {
+		opts = append(opts, Where("age <= ?", maxAge))
+	}
+
+	page, err := h.repo.List(c.Request.Context(), offset, limit, opts...)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, UserPage(*page))
+}
+
+// stats 用户统计
+func (h *UserHandler) stats(c *gin.Context) {
+	stats, err := h.repo.Stats(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// create 创建用户
+func (h *UserHandler) create(c *gin.Context) {
+	var in UserInput
+	if !bindJSON(c, &in) {
+		return
+	}
+	user := &User{Name: in.Name, Email: CIText(in.Email), Age: in.Age, Status: in.Status}
+	if user.Status == "" {
+		user.Status = UserStatusActive
+	}
+	status := http.StatusCreated
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		created, isNew, err := h.repo.CreateIdempotent(c.Request.Context(), key, user)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if user = created; !isNew {
+			status = http.StatusOK
+		}
+	} else if err := h.repo.Create(c.Request.Context(), user); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/users/%d", user.ID))
+	c.JSON(status, user)
+}
+
+// get 查询单个用户
+func (h *UserHandler) get(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	user, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// update 全量更新用户
+func (h *UserHandler) update(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var in UserInput
+	if !bindJSON(c, &in) {
+		return
+	}
+	user, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	user.Name, user.Email, user.Age = in.Name, CIText(in.Email), in.Age
+	if in.Status != "" {
+		user.Status = in.Status
+	}
+	if err := h.repo.Update(c.Request.Context(), user); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// delete 软删除用户
+func (h *UserHandler) delete(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.repo.GetByID(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := h.repo.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindJSON 绑定并校验请求体，失败时写入 problem+json 并返回 false
+func bindJSON(c *gin.Context, dst any) bool {
+	err := c.ShouldBindJSON(dst)
+	if err == nil {
+		return true
+	}
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, len(verrs))
+		for i, fe := range verrs {
+			fields[i] = FieldError{Field: jsonFieldName(fe), Rule: fe.Tag(), Param: fe.Param()}
+		}
+		writeProblem(c, http.StatusBadRequest, "请求参数校验失败", fields)
+		return false
+	}
+	writeProblem(c, http.StatusBadRequest, "请求体不是合法的 JSON: "+err.Error(), nil)
+	return false
+}
+
+// jsonFieldName 校验错误对应的 JSON 字段名（UserInput 的字段名与 json 标签只差大小写与下划线）
+func jsonFieldName(fe validator.FieldError) string {
+	return toSnakeCase(fe.Field())
+}
+
+func toSnakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func pathID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		writeProblem(c, http.StatusBadRequest, "无效的用户ID: "+c.Param("id"), nil)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	v := c.Query(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("参数 %s 必须是整数", key)
+	}
+	return n, nil
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// writeError 将仓库错误映射为 HTTP 状态码
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeProblem(c, http.StatusNotFound, "用户不存在", nil)
+	case isUniqueViolation(err):
+		writeProblem(c, http.StatusConflict, "邮箱已被使用", nil)
+	case errors.Is(err, ErrIdempotentEntityGone):
+		writeProblem(c, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, ErrPageLimit), errors.Is(err, ErrInvalidCursor):
+		writeProblem(c, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, ErrRateLimited):
+		writeProblem(c, http.StatusTooManyRequests, err.Error(), nil)
+	case errors.Is(err, ErrShuttingDown), errors.Is(err, ErrCircuitOpen):
+		writeProblem(c, http.StatusServiceUnavailable, err.Error(), nil)
+	default:
+		_ = c.Error(err)
+		writeProblem(c, http.StatusInternalServerError, "服务器内部错误", nil)
+	}
+}
+
+// isUniqueViolation 是否违反唯一约束（未开启 TranslateError 时为 pgconn.PgError 23505）
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, gorm.ErrDuplicatedKey) || errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func writeProblem(c *gin.Context, status int, detail string, fields []FieldError) {
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Errors:   fields,
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchiveSuffix 归档表名后缀：users 的归档表为 users_archive
+const ArchiveSuffix = "_archive"
+
+// ArchiveOptions 归档表定义
+type ArchiveOptions struct {
+	Partition PartitionInterval // 非空时归档表按 archived_at 做 RANGE 分区，并带默认分区兜底
+	Ahead     int               // 分区时预建的未来分区数
+}
+
+// Archived 归档表中的一行：原实体加归档时间
+type Archived[T any] struct {
+	Entity     T         `gorm:"embedded"`
+	ArchivedAt time.Time `gorm:"column:archived_at"`
+}
+
+// Archive 模型 T 的归档：硬删除时把行复制到 <table>_archive，被清理的数据仍可审计
+type Archive[T any] struct {
+	db      *gorm.DB
+	table   string
+	archive string
+	columns []string // 已加引号的列名，按模型字段顺序
+	pk      []string
+}
+
+// NewArchive 创建模型 T 的归档
+func NewArchive[T any](db *gorm.DB) (*Archive[T], error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("解析模型 %T 失败: %w", new(T), err)
+	}
+	if len(stmt.Schema.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("表 %s 没有主键", stmt.Table)
+	}
+	a := &Archive[T]{db: db, table: stmt.Table, archive: stmt.Table + ArchiveSuffix}
+	a.columns = quoteColumns(stmt.Schema.DBNames)
+	for _, f := range stmt.Schema.PrimaryFields {
+		a.pk = append(a.pk, pgx.Identifier{f.DBName}.Sanitize())
+	}
+	return a, nil
+}
+
+// Table 归档表名
+func (a *Archive[T]) Table() string { return a.archive }
+
+// EnsureTable 创建归档表（已存在则跳过）：列与原表相同但不带约束，同一主键可以被多次归档，
+// 另加 archived_at 列与主键列索引
+func (a *Archive[T]) EnsureTable(ctx context.Context, opts ArchiveOptions) error {
+	db := a.db.WithContext(ctx)
+	sql := "CREATE TABLE IF NOT EXISTS ? (LIKE ? INCLUDING DEFAULTS, archived_at timestamptz NOT NULL DEFAULT now())"
+	if opts.Partition != "" {
+		sql += " PARTITION BY RANGE (archived_at)"
+	}
+	if err := db.Exec(sql, clause.Table{Name: a.archive}, clause.Table{Name: a.table}).Error; err != nil {
+		return fmt.Errorf("创建归档表 %s 失败: %w", a.archive, err)
+	}
+	if opts.Partition != "" {
+		if err := db.Exec("CREATE TABLE IF NOT EXISTS ? PARTITION OF ? DEFAULT",
+			clause.Table{Name: a.archive + "_default"}, clause.Table{Name: a.archive}).Error; err != nil {
+			return fmt.Errorf("创建归档表默认分区失败: %w", err)
+		}
+		if err := ensureTimePartitions(ctx, a.db, a.archive, opts.Partition, opts.Ahead); err != nil {
+			return err
+		}
+	}
+	_, base, ok := strings.Cut(a.archive, ".")
+	if !ok {
+		base = a.archive
+	}
+	index := pgx.Identifier{base + "_pk_idx"}.Sanitize()
+	if err := db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", index, quoteQualified(a.archive), strings.Join(a.pk, ", "))).Error; err != nil {
+		return fmt.Errorf("创建归档表索引失败: %w", err)
+	}
+	log.Printf("归档表 %s 已就绪", a.archive)
+	return nil
+}
+
+// Delete 硬删除满足条件的行（包括已软删除的行）并写入归档表，返回删除的行数
+// 删除与归档在同一条语句中完成，要么都成功要么都不生效；db 处于事务中时随事务提交或回滚
+//
+//	n, err := archive.Delete(ctx, Where("deleted_at < ?", cutoff))
+func (a *Archive[T]) Delete(ctx context.Context, opts ...QueryOption) (int64, error) {
+	db := sessionDB(ctx, a.db)
+	keys := strings.Join(a.pk, ", ")
+	sub := newQueryOptions(opts).filter(db.Session(&gorm.Session{NewDB: true}).Model(new(T)).Unscoped().Select(keys))
+	res := db.Exec(archiveMoveSQL(a.table, a.archive, a.columns, fmt.Sprintf("(%s) IN (?)", keys)), sub)
+	if res.Error != nil {
+		return 0, fmt.Errorf("归档删除 %s 失败: %w", a.table, res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// archiveMoveSQL 删除原表中满足 where 的行并插入归档表，影响行数即移动的行数
+func archiveMoveSQL(table, archive string, columns []string, where string) string {
+	cols := strings.Join(columns, ", ")
+	return fmt.Sprintf("WITH moved AS (DELETE FROM %s WHERE %s RETURNING %s) INSERT INTO %s (%s) SELECT %s FROM moved",
+		quoteQualified(table), where, cols, quoteQualified(archive), cols, cols)
+}
+
+// Find 查询归档数据，条件与排序同仓库查询，可使用 archived_at 列；未指定排序时按归档时间倒序
+func (a *Archive[T]) Find(ctx context.Context, opts ...QueryOption) ([]*Archived[T], error) {
+	o := newQueryOptions(opts)
+	db := o.apply(sessionDB(ctx, a.db).Table(a.archive).Unscoped())
+	if len(o.orders) == 0 {
+		db = db.Order("archived_at DESC")
+	}
+	var rows []*Archived[T]
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询归档表 %s 失败: %w", a.archive, err)
+	}
+	return rows, nil
+}
+
+// FindByKey 查询某个主键的全部归档记录（同一主键可能被多次归档），按归档时间倒序
+func (a *Archive[T]) FindByKey(ctx context.Context, key map[string]any) ([]*Archived[T], error) {
+	return a.Find(ctx, Where(key))
+}
+
+func quoteColumns(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pgx.Identifier{name}.Sanitize()
+	}
+	return quoted
+}
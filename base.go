@@ -1,45 +1,86 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
-type BaseRepository[T any] struct {
-	db *gorm.DB
+type BaseRepository[T any, ID comparable] struct {
+	db         *gorm.DB
+	hooks      *repoHooks[T]
+	limits     PageLimits
+	rowLimit   RowLimit
+	middleware []RepositoryMiddleware
 }
 
-// NewBaseRepository 创建基础仓库
-func NewBaseRepository[T any](db *gorm.DB) *BaseRepository[T] {
-	return &BaseRepository[T]{db: db}
+// NewBaseRepository 创建基础仓库，ID 为主键类型（如 uint、string 形式的 UUID）
+func NewBaseRepository[T any, ID comparable](db *gorm.DB) *BaseRepository[T, ID] {
+	return &BaseRepository[T, ID]{db: db, hooks: &repoHooks[T]{}, limits: DefaultPageLimits}
 }
 
 // CreateTable 创建表
-func (r *BaseRepository[T]) CreateTable(entity *T) error {
+//
+// Deprecated: 以 RegisterModel 注册模型，启动或 migrate up 时由 MigrateAll 按依赖顺序统一迁移
+func (r *BaseRepository[T, ID]) CreateTable(entity *T) error {
 	if err := r.db.AutoMigrate(entity); err != nil {
 		return fmt.Errorf("表 %T 自动迁移失败: %w", entity, err)
 	}
+	if err := MigrateGeneratedColumns(context.Background(), r.db, entity); err != nil {
+		return err
+	}
+	if err := MigrateSoftDeleteUniques(context.Background(), r.db, entity); err != nil {
+		return err
+	}
 	log.Printf("表 %T 创建成功!", entity)
 	return nil
 }
 
 // Create 创建实体
-func (r *BaseRepository[T]) Create(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Create(entity).Error
+func (r *BaseRepository[T, ID]) Create(ctx context.Context, entity *T) error {
+	return r.run(ctx, "Create", OpWrite, func(ctx context.Context) error { return r.create(ctx, entity) }, entity)
+}
+
+func (r *BaseRepository[T, ID]) create(ctx context.Context, entity *T) error {
+	if err := r.session(ctx).Create(entity).Error; err != nil {
+		return err
+	}
+	r.hooks.fire(ctx, &r.hooks.created, entity)
+	return nil
 }
 
 // BatchCreate 批量创建实体
-func (r *BaseRepository[T]) BatchCreate(ctx context.Context, entities []*T) error {
-	return r.db.WithContext(ctx).Create(entities).Error
+func (r *BaseRepository[T, ID]) BatchCreate(ctx context.Context, entities []*T) error {
+	return r.run(ctx, "BatchCreate", OpWrite, func(ctx context.Context) error { return r.batchCreate(ctx, entities) }, entities)
+}
+
+func (r *BaseRepository[T, ID]) batchCreate(ctx context.Context, entities []*T) error {
+	if err := r.session(ctx).Create(entities).Error; err != nil {
+		return err
+	}
+	r.hooks.fire(ctx, &r.hooks.created, entities...)
+	return nil
 }
 
 // GetByID 根据ID查询实体
-func (r *BaseRepository[T]) GetByID(ctx context.Context, id uint) (*T, error) {
+func (r *BaseRepository[T, ID]) GetByID(ctx context.Context, id ID, opts ...QueryOption) (*T, error) {
+	return invoke(ctx, r, "GetByID", OpRead, func(ctx context.Context) (*T, error) { return r.getByID(ctx, id, opts...) }, id, opts)
+}
+
+func (r *BaseRepository[T, ID]) getByID(ctx context.Context, id ID, opts ...QueryOption) (*T, error) {
 	var entity T
-	err := r.db.WithContext(ctx).First(&entity, id).Error
+	db := newQueryOptions(opts).apply(r.session(ctx))
+	err := db.Where(pkEq(id)).First(&entity).Error
 	if err != nil {
 		return nil, err
 	}
@@ -47,47 +88,349 @@ func (r *BaseRepository[T]) GetByID(ctx context.Context, id uint) (*T, error) {
 }
 
 // Update 更新实体
-func (r *BaseRepository[T]) Update(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Save(entity).Error
+func (r *BaseRepository[T, ID]) Update(ctx context.Context, entity *T) error {
+	return r.run(ctx, "Update", OpWrite, func(ctx context.Context) error { return r.update(ctx, entity) }, entity)
+}
+
+func (r *BaseRepository[T, ID]) update(ctx context.Context, entity *T) error {
+	if err := r.session(ctx).Save(entity).Error; err != nil {
+		return err
+	}
+	r.hooks.fire(ctx, &r.hooks.updated, entity)
+	return nil
 }
 
 // Delete 删除实体
-func (r *BaseRepository[T]) Delete(ctx context.Context, id uint) error {
+func (r *BaseRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.run(ctx, "Delete", OpWrite, func(ctx context.Context) error { return r.delete(ctx, id) }, id)
+}
+
+func (r *BaseRepository[T, ID]) delete(ctx context.Context, id ID) error {
 	// 软删除
-	return r.db.WithContext(ctx).Delete(new(T), id).Error
+	res := r.session(ctx).Where(pkEq(id)).Delete(new(T))
 
 	// 硬删除（谨慎使用）
-	// return r.db.WithContext(ctx).Unscoped().Delete(new(T), id).Error
+	// res := r.session(ctx).Unscoped().Where(pkEq(id)).Delete(new(T))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	r.hooks.fire(ctx, &r.hooks.deleted, r.idEntity(ctx, id))
+	return nil
 }
 
-// ListAll 查询所有实体
-func (r *BaseRepository[T]) ListAll(ctx context.Context) ([]*T, error) {
-	var entities []*T
-	err := r.db.WithContext(ctx).Find(&entities).Error
-	return entities, err
+// GetByKey 根据主键（支持复合主键）查询实体，key 为 列名 -> 值，必须恰好覆盖全部主键列
+func (r *BaseRepository[T, ID]) GetByKey(ctx context.Context, key map[string]any) (*T, error) {
+	return invoke(ctx, r, "GetByKey", OpRead, func(ctx context.Context) (*T, error) { return r.getByKey(ctx, key) }, key)
+}
+
+func (r *BaseRepository[T, ID]) getByKey(ctx context.Context, key map[string]any) (*T, error) {
+	if err := r.checkKey(key); err != nil {
+		return nil, err
+	}
+	var entity T
+	err := r.session(ctx).Where(key).First(&entity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// DeleteByKey 根据主键（支持复合主键）删除实体
+func (r *BaseRepository[T, ID]) DeleteByKey(ctx context.Context, key map[string]any) error {
+	return r.run(ctx, "DeleteByKey", OpWrite, func(ctx context.Context) error { return r.deleteByKey(ctx, key) }, key)
+}
+
+func (r *BaseRepository[T, ID]) deleteByKey(ctx context.Context, key map[string]any) error {
+	if err := r.checkKey(key); err != nil {
+		return err
+	}
+	res := r.session(ctx).Where(key).Delete(new(T))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	r.hooks.fire(ctx, &r.hooks.deleted, r.keyEntity(ctx, key))
+	return nil
+}
+
+// ErrEmptySpec 批量更新/删除的规格不含过滤条件，拒绝作用于整张表
+var ErrEmptySpec = errors.New("批量更新/删除必须指定过滤条件")
+
+// UpdateWhere 批量更新满足 spec 的行，fields 为 列名 -> 值，返回影响行数
+// 不加载实体，因此不触发仓库事件回调；spec 中只有过滤条件生效
+//
+//	n, err := repo.UpdateWhere(ctx, Spec{Where("age > ?", 60)}, map[string]any{"status": UserStatusDisabled})
+func (r *BaseRepository[T, ID]) UpdateWhere(ctx context.Context, spec Spec, fields map[string]any) (int64, error) {
+	return invoke(ctx, r, "UpdateWhere", OpWrite, func(ctx context.Context) (int64, error) { return r.updateWhere(ctx, spec, fields) }, spec, fields)
+}
+
+func (r *BaseRepository[T, ID]) updateWhere(ctx context.Context, spec Spec, fields map[string]any) (int64, error) {
+	o := newQueryOptions(spec)
+	if len(o.filters) == 0 {
+		return 0, ErrEmptySpec
+	}
+	res := o.filter(r.session(ctx).Model(new(T))).Updates(fields)
+	return res.RowsAffected, res.Error
+}
+
+// DeleteWhere 批量删除满足 spec 的行（模型支持软删除时为软删除），返回影响行数；同样不触发仓库事件回调
+func (r *BaseRepository[T, ID]) DeleteWhere(ctx context.Context, spec Spec) (int64, error) {
+	return invoke(ctx, r, "DeleteWhere", OpWrite, func(ctx context.Context) (int64, error) { return r.deleteWhere(ctx, spec) }, spec)
+}
+
+func (r *BaseRepository[T, ID]) deleteWhere(ctx context.Context, spec Spec) (int64, error) {
+	o := newQueryOptions(spec)
+	if len(o.filters) == 0 {
+		return 0, ErrEmptySpec
+	}
+	res := o.filter(r.session(ctx)).Delete(new(T))
+	return res.RowsAffected, res.Error
+}
+
+// deleteBatchPause DeleteWhereInBatches 两批之间的间隔，给复制与 autovacuum 留出余量
+const deleteBatchPause = 50 * time.Millisecond
+
+// DeleteWhereInBatches 与 DeleteWhere 相同，但每次只删除 batchSize 行（<= 0 时为 1000），
+// 每批一条语句（自动提交），批次之间短暂停顿，直到不足一批，返回删除的总行数。
+// 大范围清理不会长时间持有行锁，也不会在单个事务中产生大量 WAL；ctx 中带有事务时所有批次仍在该事务中执行
+//
+//	n, err := repo.DeleteWhereInBatches(ctx, Spec{Where("created_at < ?", cutoff)}, 5000)
+func (r *BaseRepository[T, ID]) DeleteWhereInBatches(ctx context.Context, spec Spec, batchSize int) (int64, error) {
+	return invoke(ctx, r, "DeleteWhereInBatches", OpWrite, func(ctx context.Context) (int64, error) {
+		o := newQueryOptions(spec)
+		if len(o.filters) == 0 {
+			return 0, ErrEmptySpec
+		}
+		if batchSize <= 0 {
+			batchSize = 1000
+		}
+		s, err := r.modelSchema()
+		if err != nil {
+			return 0, err
+		}
+		// 按主键定位每批的行，分区表上 ctid 不唯一；没有主键的表退回 ctid
+		columns := []string{"ctid"}
+		if len(s.PrimaryFieldDBNames) > 0 {
+			columns = quoteColumns(s.PrimaryFieldDBNames)
+		}
+		where := fmt.Sprintf("(%s) IN (?)", strings.Join(columns, ", "))
+		// 子查询中的列带上表名，spec 含 JOIN 时不会有歧义
+		selected := make([]string, len(columns))
+		for i, c := range columns {
+			selected[i] = quoteQualified(s.Table) + "." + c
+		}
+		key := strings.Join(selected, ", ")
+
+		var total int64
+		for {
+			batch := o.filter(r.session(ctx).Model(new(T))).Select(key).Limit(batchSize)
+			res := r.session(ctx).Where(where, batch).Delete(new(T))
+			if res.Error != nil {
+				return total, res.Error
+			}
+			total += res.RowsAffected
+			if res.RowsAffected < int64(batchSize) {
+				return total, nil
+			}
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(deleteBatchPause):
+			}
+		}
+	}, spec, batchSize)
+}
+
+// ListAll 查询所有实体，受 SetRowLimit 设置的行数上限约束
+func (r *BaseRepository[T, ID]) ListAll(ctx context.Context) ([]*T, error) {
+	return invoke(ctx, r, "ListAll", OpRead, func(ctx context.Context) ([]*T, error) { return r.listAll(ctx) })
+}
+
+func (r *BaseRepository[T, ID]) listAll(ctx context.Context) ([]*T, error) {
+	return r.findLimited(r.session(ctx), 0)
+}
+
+// Find 根据查询选项查询实体列表，受 SetRowLimit/MaxRows 设置的行数上限约束
+func (r *BaseRepository[T, ID]) Find(ctx context.Context, opts ...QueryOption) ([]*T, error) {
+	return invoke(ctx, r, "Find", OpRead, func(ctx context.Context) ([]*T, error) { return r.find(ctx, opts...) }, opts)
+}
+
+func (r *BaseRepository[T, ID]) find(ctx context.Context, opts ...QueryOption) ([]*T, error) {
+	o := newQueryOptions(opts)
+	db := o.apply(r.session(ctx))
+	// 显式的 Limit 不超过行数上限时无需多取一行检查
+	if maxRows := cmp.Or(o.maxRows, r.rowLimit.Max); o.limit > 0 && (maxRows <= 0 || o.limit <= maxRows) {
+		var entities []*T
+		err := db.Find(&entities).Error
+		return entities, err
+	}
+	return r.findLimited(db, o.maxRows)
+}
+
+// FindInto 将查询结果投影到精简的 DTO 切片，只 SELECT R 中存在的字段（gorm smart select）
+//
+//	type UserBrief struct {
+//		ID   uint
+//		Name string
+//	}
+//	var briefs []UserBrief
+//	err := FindInto(ctx, repo, Spec{Where("age > ?", 18)}, &briefs)
+func FindInto[R any, T any, ID comparable](ctx context.Context, r *BaseRepository[T, ID], spec Spec, dest *[]R) error {
+	return r.run(ctx, "FindInto", OpRead, func(ctx context.Context) error {
+		return newQueryOptions(spec).apply(r.session(ctx).Model(new(T))).Find(dest).Error
+	}, spec, dest)
+}
+
+// Pluck 查询单列的值到 dest（如 *[]string），无需加载完整实体
+//
+//	var emails []string
+//	err := repo.Pluck(ctx, "email", &emails, Where("age > ?", 30), Distinct())
+func (r *BaseRepository[T, ID]) Pluck(ctx context.Context, column string, dest any, opts ...QueryOption) error {
+	return r.run(ctx, "Pluck", OpRead, func(ctx context.Context) error { return r.pluck(ctx, column, dest, opts...) }, column, dest, opts)
+}
+
+func (r *BaseRepository[T, ID]) pluck(ctx context.Context, column string, dest any, opts ...QueryOption) error {
+	db := newQueryOptions(opts).apply(r.session(ctx).Model(new(T)))
+	return db.Pluck(column, dest).Error
 }
 
-// List 根据offset和limit查询实体列表
-func (r *BaseRepository[T]) List(ctx context.Context, offset, limit int) ([]*T, int64, error) {
+// List 根据offset和limit分页查询，Total 为满足过滤条件的总数；offset/limit 按 SetPageLimits 的限制修正
+func (r *BaseRepository[T, ID]) List(ctx context.Context, offset, limit int, opts ...QueryOption) (*Page[T], error) {
+	return invoke(ctx, r, "List", OpRead, func(ctx context.Context) (*Page[T], error) { return r.list(ctx, offset, limit, opts...) }, offset, limit, opts)
+}
+
+func (r *BaseRepository[T, ID]) list(ctx context.Context, offset, limit int, opts ...QueryOption) (*Page[T], error) {
 	var entities []*T
 	var total int64
 
-	if total, err := r.Count(ctx); err != nil {
-		return nil, total, err
+	offset, limit, err := r.limits.normalize(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newQueryOptions(opts)
+	if err := o.filter(r.session(ctx).Model(new(T))).Count(&total).Error; err != nil {
+		return nil, err
 	}
 
-	err := r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&entities).Error
-	return entities, total, err
+	if err := o.apply(r.session(ctx)).Offset(offset).Limit(limit).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return newOffsetPage(entities, total, offset, limit), nil
 }
 
 // Count 查询实体总数
-func (r *BaseRepository[T]) Count(ctx context.Context) (int64, error) {
+func (r *BaseRepository[T, ID]) Count(ctx context.Context) (int64, error) {
+	return invoke(ctx, r, "Count", OpRead, func(ctx context.Context) (int64, error) { return r.count(ctx) })
+}
+
+func (r *BaseRepository[T, ID]) count(ctx context.Context) (int64, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(new(T)).Count(&count).Error
+	err := r.session(ctx).Model(new(T)).Count(&count).Error
 	return count, err
 }
 
+// CountWhere 统计满足 spec 的实体数
+//
+//	n, err := repo.CountWhere(ctx, Spec{Where("age > ?", 30)})
+func (r *BaseRepository[T, ID]) CountWhere(ctx context.Context, spec Spec) (int64, error) {
+	return invoke(ctx, r, "CountWhere", OpRead, func(ctx context.Context) (int64, error) { return r.countWhere(ctx, spec) }, spec)
+}
+
+func (r *BaseRepository[T, ID]) countWhere(ctx context.Context, spec Spec) (int64, error) {
+	var count int64
+	err := newQueryOptions(spec).filter(r.session(ctx).Model(new(T))).Count(&count).Error
+	return count, err
+}
+
+// CountGroupBy 按列分组统计满足 spec 的实体数，键为列值的文本形式，NULL 对应空字符串
+//
+//	byStatus, err := repo.CountGroupBy(ctx, "status", nil) // map[active:10 disabled:2]
+func (r *BaseRepository[T, ID]) CountGroupBy(ctx context.Context, column string, spec Spec) (map[string]int64, error) {
+	return invoke(ctx, r, "CountGroupBy", OpRead, func(ctx context.Context) (map[string]int64, error) { return r.countGroupBy(ctx, column, spec) }, column, spec)
+}
+
+func (r *BaseRepository[T, ID]) countGroupBy(ctx context.Context, column string, spec Spec) (map[string]int64, error) {
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	f := s.LookUpField(column)
+	if f == nil || f.DBName == "" {
+		return nil, fmt.Errorf("表 %s 没有列 %s", s.Table, column)
+	}
+
+	var rows []struct {
+		Key   sql.NullString
+		Count int64
+	}
+	col := clause.Column{Table: clause.CurrentTable, Name: f.DBName}
+	err = newQueryOptions(spec).filter(r.session(ctx).Model(new(T))).
+		Select("?::text AS key, COUNT(*) AS count", col).Group("key").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Key.String] += row.Count
+	}
+	return counts, nil
+}
+
+// pkEq 主键等值条件；不直接把 id 传给 First/Delete，避免字符串主键被 gorm 当作 SQL 条件解析
+func pkEq(id any) clause.Eq {
+	return clause.Eq{Column: clause.PrimaryColumn, Value: id}
+}
+
+// modelSchema 解析实体的 gorm schema
+func (r *BaseRepository[T, ID]) modelSchema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("解析模型 %T 失败: %w", new(T), err)
+	}
+	return stmt.Schema, nil
+}
+
+// tableName 解析实体对应的表名（含schema）
+func (r *BaseRepository[T, ID]) tableName() (string, error) {
+	return parseTableName(r.db, new(T))
+}
+
+// primaryKey 从实体中取出单列主键的值
+func (r *BaseRepository[T, ID]) primaryKey(entity *T) (ID, error) {
+	var id ID
+	s, err := r.modelSchema()
+	if err != nil {
+		return id, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return id, fmt.Errorf("表 %s 没有单列主键，无法取主键值", s.Table)
+	}
+	v, _ := s.PrioritizedPrimaryField.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	id, ok := v.(ID)
+	if !ok {
+		return id, fmt.Errorf("表 %s 主键类型 %T 与仓库 ID 类型 %T 不一致", s.Table, v, id)
+	}
+	return id, nil
+}
+
+// checkKey 校验 key 恰好包含全部主键列，防止部分主键条件误删/误查多行
+func (r *BaseRepository[T, ID]) checkKey(key map[string]any) error {
+	s, err := r.modelSchema()
+	if err != nil {
+		return err
+	}
+	if len(key) != len(s.PrimaryFields) {
+		return fmt.Errorf("表 %s 主键包含 %d 列，传入了 %d 列", s.Table, len(s.PrimaryFields), len(key))
+	}
+	for _, f := range s.PrimaryFields {
+		if _, ok := key[f.DBName]; !ok {
+			return fmt.Errorf("表 %s 缺少主键列 %s", s.Table, f.DBName)
+		}
+	}
+	return nil
+}
+
 // GetDB 获取原始的gorm.DB实例
-func (r *BaseRepository[T]) GetDB() *gorm.DB {
+func (r *BaseRepository[T, ID]) GetDB() *gorm.DB {
 	return r.db
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"postgresql-test/dbtest"
+)
+
+// 仓库操作基准，连接 TEST_DATABASE_DSN 指定的本地数据库（如 Docker 启动的 postgres 容器），以 benchstat 比较两次结果：
+//
+//	docker run -d -p 5432:5432 -e POSTGRES_PASSWORD=postgres postgres:16
+//	TEST_DATABASE_DSN="host=localhost user=postgres password=postgres sslmode=disable" go test -run '^$' -bench . -count 6 > old.txt
+//	benchstat old.txt new.txt
+
+// benchRows 分页基准需要的最少数据量，不足时用 GenerateUsers 补齐
+const benchRows = 100_000
+
+var (
+	benchDBOnce sync.Once
+	benchDBConn *gorm.DB
+	benchDBErr  error
+	benchSeq    atomic.Int64
+)
+
+// benchDB 基准使用的连接：关闭 SQL 日志（会混入基准输出）与默认事务，并保证 users 表中有足够的数据用于分页基准
+func benchDB(b *testing.B) *gorm.DB {
+	b.Helper()
+	dsn := dbtest.DSN(b)
+	benchDBOnce.Do(func() {
+		ctx := context.Background()
+		benchDBConn, benchDBErr = NewPostgresDB(dsn,
+			WithGormConfig(&gorm.Config{SkipDefaultTransaction: true}),
+			WithLogger(logger.Default.LogMode(logger.Silent)),
+		)
+		if benchDBErr != nil {
+			return
+		}
+		if benchDBErr = MigrateAll(ctx, benchDBConn); benchDBErr != nil {
+			return
+		}
+		var count int64
+		if benchDBErr = benchDBConn.Model(&User{}).Count(&count).Error; benchDBErr != nil || count >= benchRows {
+			return
+		}
+		_, benchDBErr = GenerateUsers(ctx, benchDBConn, int(benchRows-count), GenerateOptions{Seed: 1})
+	})
+	if benchDBErr != nil {
+		b.Fatalf("准备基准数据库失败: %v", benchDBErr)
+	}
+	b.ReportAllocs()
+	return benchDBConn
+}
+
+// benchUsers 生成 n 个邮箱不重复的用户
+func benchUsers(n int) []*User {
+	users := make([]*User, n)
+	for i := range users {
+		seq := benchSeq.Add(1)
+		users[i] = &User{
+			Name:  fmt.Sprintf("bench_%d", seq),
+			Email: CIText(fmt.Sprintf("bench_%d_%d@example.com", time.Now().UnixNano(), seq)),
+			Age:   int(seq%60) + 18,
+		}
+	}
+	return users
+}
+
+// reportPerRow 额外报告每行耗时，便于比较单条与批量写入
+func reportPerRow(b *testing.B, rowsPerOp int) {
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*rowsPerOp), "ns/row")
+}
+
+func BenchmarkCreate(b *testing.B) {
+	repo := NewUserRepository(benchDB(b))
+	for i := 0; i < b.N; i++ {
+		if err := repo.Create(context.Background(), benchUsers(1)[0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerRow(b, 1)
+}
+
+func BenchmarkBatchCreate100(b *testing.B) {
+	repo := NewUserRepository(benchDB(b))
+	for i := 0; i < b.N; i++ {
+		if err := repo.BatchCreate(context.Background(), benchUsers(100)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerRow(b, 100)
+}
+
+func BenchmarkCopy100(b *testing.B) {
+	db := benchDB(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := GenerateUsers(context.Background(), db, 100, GenerateOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerRow(b, 100)
+}
+
+func BenchmarkOffsetPage(b *testing.B) {
+	db := benchDB(b)
+	for i := 0; i < b.N; i++ {
+		var users []*User
+		if err := db.Order("id").Offset(50_000).Limit(20).Find(&users).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKeysetPage(b *testing.B) {
+	db := benchDB(b)
+	// 游标取 OFFSET 方式同一页的前一行 ID，保证两者读取相同的数据
+	var after uint
+	if err := db.Model(&User{}).Order("id").Offset(49_999).Limit(1).Pluck("id", &after).Error; err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var users []*User
+		if err := db.Where("id > ?", after).Order("id").Limit(20).Find(&users).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	repo := NewUserRepository(benchDB(b))
+	user := benchUsers(1)[0]
+	if err := repo.Create(context.Background(), user); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		user.Age = i%60 + 18
+		if err := repo.Update(context.Background(), user); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdates(b *testing.B) {
+	db := benchDB(b)
+	user := benchUsers(1)[0]
+	if err := db.Create(user).Error; err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Model(user).Updates(map[string]any{"age": i%60 + 18}).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态，数据库被判定为不可用，请求直接失败而不再占用连接池
+var ErrCircuitOpen = errors.New("数据库熔断中，请稍后重试")
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 正常放行
+	CircuitOpen                         // 拒绝全部请求
+	CircuitHalfOpen                     // 放行少量探测请求，成功则关闭，失败则重新打开
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerConfig 熔断器配置，零值字段使用默认值
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // 连续失败多少次后打开，默认 5
+	OpenTimeout      time.Duration // 打开后多久进入半开状态，默认 10s
+	HalfOpenProbes   int           // 半开状态下同时放行的探测请求数，默认 1
+}
+
+// CircuitBreaker 数据库熔断器：连接失败、超时、连接数耗尽等错误连续出现时打开，
+// 之后的请求立即返回 ErrCircuitOpen，避免 PostgreSQL 宕机或过载时大量 goroutine 阻塞在连接池上；
+// 记录不存在、约束冲突等业务错误不计为失败
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// NewCircuitBreaker 创建熔断器，通过 WithCircuitBreaker 或 RegisterCircuitBreaker 挂载到 *gorm.DB
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 10 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &CircuitBreaker{cfg: cfg}
+}
+
+// State 当前状态，打开超时后报告为半开
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow 判断是否放行请求，半开状态下放行的请求计为探测
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen {
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.state, b.probes = CircuitHalfOpen, 0
+	}
+	if b.state == CircuitHalfOpen {
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// record 记录放行请求的结果
+func (b *CircuitBreaker) record(err error) {
+	failed := isUnavailable(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !failed:
+		if b.state != CircuitClosed {
+			log.Println("数据库熔断器关闭，恢复正常访问")
+		}
+		b.state, b.failures, b.probes = CircuitClosed, 0, 0
+	case b.state == CircuitHalfOpen:
+		b.state, b.openedAt = CircuitOpen, time.Now()
+		log.Printf("数据库熔断器探测失败，重新打开: %v", err)
+	case b.state == CircuitClosed:
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.state, b.openedAt = CircuitOpen, time.Now()
+			log.Printf("数据库连续失败 %d 次，熔断器打开 %s: %v", b.failures, b.cfg.OpenTimeout, err)
+		}
+	}
+}
+
+// isUnavailable 错误是否表明数据库不可用（而不是请求本身的问题）
+func isUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 08 连接异常，53 资源不足（连接数耗尽、内存不足），57P01-57P03 服务端关闭或正在启动，
+		// 25006 故障切换后旧主库降为备库，写操作报只读事务错误
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "53") || pgErr.Code == "25006" ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	var connErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connErr) || errors.As(err, &netErr)
+}
+
+const breakerAdmittedKey = "app:breaker_admitted"
+
+// RegisterCircuitBreaker 在所有 gorm 处理器前后挂载熔断器：打开时在执行 SQL 前以 ErrCircuitOpen 失败
+func RegisterCircuitBreaker(db *gorm.DB, b *CircuitBreaker) error {
+	allow := func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		if err := b.allow(); err != nil {
+			db.AddError(err)
+			return
+		}
+		db.InstanceSet(breakerAdmittedKey, true)
+	}
+	record := func(db *gorm.DB) {
+		if _, ok := db.InstanceGet(breakerAdmittedKey); ok {
+			b.record(db.Error)
+		}
+	}
+	return registerAround(callbackPoints(db, "*"), callbackHook{"app:breaker_allow", allow}, callbackHook{"app:breaker_record", record})
+}
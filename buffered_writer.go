@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriterClosed BufferedWriter 已关闭，不再接收写入
+var ErrWriterClosed = errors.New("批量写入器已关闭")
+
+// BufferedWriterConfig 批量写入器配置，零值字段使用默认值
+type BufferedWriterConfig struct {
+	BatchSize     int           // 攒够多少行立即写入，默认 500
+	FlushInterval time.Duration // 第一行入队后最多等待多久写入，默认 100ms
+	QueueSize     int           // 待写入队列长度，队列满时 Write 阻塞（背压），默认 BatchSize 的 4 倍
+	FlushTimeout  time.Duration // 单个批次的写入超时，默认 30s
+}
+
+// BufferedWriterStats 批量写入器的累计指标
+type BufferedWriterStats struct {
+	Queued  int    // 当前排队的行数
+	Written uint64 // 写入成功的行数
+	Failed  uint64 // 写入失败的行数
+	Batches uint64 // 执行的批次数
+	Retried uint64 // 批量写入失败后改为逐行写入的批次数
+}
+
+// BufferedWriter 合并 Create 调用、按批次写入，用于埋点、日志等逐行 INSERT 延迟成为瓶颈的写入路径：
+// 攒够 BatchSize 行或等待 FlushInterval 后以一条多行 INSERT 写入；批量写入失败时逐行重试，
+// 只有出错的行收到错误。一个批次内的行不在同一个调用方的事务中，不适合需要与其他写操作原子提交的场景
+//
+//	w := NewBufferedWriter(repo, BufferedWriterConfig{BatchSize: 1000})
+//	defer w.Close(ctx)
+//	err := w.Create(ctx, &event) // 阻塞到所在批次写入完成
+type BufferedWriter[T any, ID comparable] struct {
+	repo  *BaseRepository[T, ID]
+	cfg   BufferedWriterConfig
+	queue chan *bufferedItem[T]
+	flush chan chan struct{}
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	written, failed, batches, retried atomic.Uint64
+}
+
+type bufferedItem[T any] struct {
+	ctx     context.Context
+	session sessionOptions // 调用方 ctx 中的会话选项，同一批次中选项相同的行一起写入
+	entity  *T
+	result  chan error
+}
+
+// NewBufferedWriter 创建批量写入器并启动后台写入，用完应调用 Close 写入剩余的行
+func NewBufferedWriter[T any, ID comparable](repo *BaseRepository[T, ID], cfg BufferedWriterConfig) *BufferedWriter[T, ID] {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 100 * time.Millisecond
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 4 * cfg.BatchSize
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = 30 * time.Second
+	}
+	w := &BufferedWriter[T, ID]{
+		repo:  repo,
+		cfg:   cfg,
+		queue: make(chan *bufferedItem[T], cfg.QueueSize),
+		flush: make(chan chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write 将实体加入队列，返回接收该行写入结果的 channel（缓冲为 1，可以不读）；
+// 队列满时阻塞到有空位或 ctx 取消。写入前 ctx 已取消的行不会写入，结果为 ctx 的错误
+func (w *BufferedWriter[T, ID]) Write(ctx context.Context, entity *T) (<-chan error, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return nil, ErrWriterClosed
+	}
+	session := sessionFrom(ctx)
+	// 调用方占用的并发配额属于其自己的事务，后台写入另行占用
+	session.holdsSlot = false
+	item := &bufferedItem[T]{ctx: ctx, session: session, entity: entity, result: make(chan error, 1)}
+	select {
+	case w.queue <- item:
+		return item.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Create 加入队列并等待所在批次写入完成，成功后实体的主键等数据库生成的字段已回填
+func (w *BufferedWriter[T, ID]) Create(ctx context.Context, entity *T) error {
+	result, err := w.Write(ctx, entity)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush 立即写入已入队的行，等待写入完成
+func (w *BufferedWriter[T, ID]) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case w.flush <- ack:
+	case <-w.done:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 停止接收写入，写入队列中剩余的行后返回；ctx 取消时不再等待，剩余的行仍在后台写入
+func (w *BufferedWriter[T, ID]) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats 返回累计指标
+func (w *BufferedWriter[T, ID]) Stats() BufferedWriterStats {
+	return BufferedWriterStats{
+		Queued:  len(w.queue),
+		Written: w.written.Load(),
+		Failed:  w.failed.Load(),
+		Batches: w.batches.Load(),
+		Retried: w.retried.Load(),
+	}
+}
+
+func (w *BufferedWriter[T, ID]) run() {
+	defer close(w.done)
+	batch := make([]*bufferedItem[T], 0, w.cfg.BatchSize)
+	timer := time.NewTimer(w.cfg.FlushInterval)
+	timer.Stop()
+	write := func() {
+		timer.Stop()
+		w.write(batch)
+		clear(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				write()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(w.cfg.FlushInterval)
+			}
+			if batch = append(batch, item); len(batch) >= w.cfg.BatchSize {
+				write()
+			}
+		case <-timer.C:
+			write()
+		case ack := <-w.flush:
+			// 已入队但尚未取出的行一并写入
+			for drained := false; !drained; {
+				select {
+				case item, ok := <-w.queue:
+					if !ok {
+						drained = true
+						continue
+					}
+					if batch = append(batch, item); len(batch) >= w.cfg.BatchSize {
+						write()
+					}
+				default:
+					drained = true
+				}
+			}
+			write()
+			close(ack)
+		}
+	}
+}
+
+// write 写入一个批次：跳过调用方已取消的行，其余按会话选项分组写入
+func (w *BufferedWriter[T, ID]) write(batch []*bufferedItem[T]) {
+	var groups [][]*bufferedItem[T]
+	for _, item := range batch {
+		if err := item.ctx.Err(); err != nil {
+			w.finish(item, err)
+			continue
+		}
+		i := slices.IndexFunc(groups, func(g []*bufferedItem[T]) bool { return g[0].session.equal(item.session) })
+		if i < 0 {
+			groups = append(groups, nil)
+			i = len(groups) - 1
+		}
+		groups[i] = append(groups[i], item)
+	}
+	for _, items := range groups {
+		w.writeGroup(items)
+	}
+}
+
+// writeGroup 写入会话选项相同的一组行并把结果发给每一行；多行 INSERT 是单条语句，失败时整批都未写入，逐行重试以找出出错的行。
+// 写入只带这组行的会话选项（SkipHooks、注释、优先级等），不含任何调用方 ctx 的取消与其他值，
+// 一个调用方的选项不会作用到其他调用方的行，也不因某一个调用方放弃而整批中断
+func (w *BufferedWriter[T, ID]) writeGroup(items []*bufferedItem[T]) {
+	w.batches.Add(1)
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), sessionKey{}, items[0].session), w.cfg.FlushTimeout)
+	defer cancel()
+	entities := make([]*T, len(items))
+	for i, item := range items {
+		entities[i] = item.entity
+	}
+	err := w.repo.BatchCreate(ctx, entities)
+	if err == nil || len(items) == 1 {
+		for _, item := range items {
+			w.finish(item, err)
+		}
+		return
+	}
+	log.Printf("批量写入 %d 行失败，改为逐行写入: %v", len(items), err)
+	w.retried.Add(1)
+	for _, item := range items {
+		if err := item.ctx.Err(); err != nil {
+			w.finish(item, err)
+			continue
+		}
+		w.finish(item, w.repo.Create(ctx, item.entity))
+	}
+}
+
+func (w *BufferedWriter[T, ID]) finish(item *bufferedItem[T], err error) {
+	if err != nil {
+		w.failed.Add(1)
+	} else {
+		w.written.Add(1)
+	}
+	item.result <- err
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// batchUpdateSize 每条 UPDATE 语句最多携带的行数；列较多时按 PostgreSQL 单条语句 65535 个参数的上限继续缩小
+const batchUpdateSize = 1000
+
+// maxBindParams PostgreSQL 扩展协议单条语句的参数个数上限
+const maxBindParams = 65535
+
+// BatchUpdate 按主键批量更新 entities 的 columns（以及 UpdatedAt 等自动更新时间列），返回影响行数。
+// 以 UPDATE ... FROM (VALUES ...) 一条语句更新所有行，比逐行 Update 少了往返，持锁时间也更短；
+// 行数超过单条语句的上限时分成多条语句，在同一个事务中执行：
+//
+//	n, err := repo.BatchUpdate(ctx, users, []string{"status"})
+//
+// 只更新未软删除的行，不存在的主键被忽略（影响行数小于 len(entities)）；
+// 不执行模型的 BeforeUpdate 等 gorm 钩子，自动更新时间列由此处填充并回写到实体；成功后触发 OnUpdated 回调
+func (r *BaseRepository[T, ID]) BatchUpdate(ctx context.Context, entities []*T, columns []string) (int64, error) {
+	return invoke(ctx, r, "BatchUpdate", OpWrite, func(ctx context.Context) (int64, error) {
+		if len(entities) == 0 {
+			return 0, nil
+		}
+		if len(columns) == 0 {
+			return 0, errors.New("BatchUpdate 至少需要一个更新列")
+		}
+		s, err := r.modelSchema()
+		if err != nil {
+			return 0, err
+		}
+		if len(s.PrimaryFields) == 0 {
+			return 0, fmt.Errorf("表 %s 没有主键，无法批量更新", s.Table)
+		}
+		fields, err := batchUpdateFields(s, columns)
+		if err != nil {
+			return 0, err
+		}
+
+		db := r.session(ctx)
+		rows, err := batchUpdateRows(db, s, entities, fields)
+		if err != nil {
+			return 0, err
+		}
+		types, err := columnSQLTypes(db, s.Table)
+		if err != nil {
+			return 0, err
+		}
+		keys := append(slices.Clone(s.PrimaryFields), fields...)
+		for _, f := range keys {
+			if types[f.DBName] == "" {
+				return 0, fmt.Errorf("表 %s 中不存在列 %s", s.Table, f.DBName)
+			}
+		}
+		var deletedAt string
+		if f := softDeleteField(s); f != nil {
+			deletedAt = f.DBName
+		}
+
+		size := min(batchUpdateSize, maxBindParams/len(keys))
+		var affected int64
+		exec := func(tx *gorm.DB) error {
+			for chunk := range slices.Chunk(rows, size) {
+				sql, vars := batchUpdateSQL(s, fields, types, deletedAt, chunk)
+				res := tx.Exec(sql, vars...)
+				if res.Error != nil {
+					return fmt.Errorf("表 %s 批量更新失败: %w", s.Table, res.Error)
+				}
+				affected += res.RowsAffected
+			}
+			return nil
+		}
+		if len(rows) <= size {
+			err = exec(db)
+		} else {
+			err = db.Transaction(exec)
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.hooks.fire(ctx, &r.hooks.updated, entities...)
+		return affected, nil
+	}, entities, columns)
+}
+
+// batchUpdateFields 校验更新列并补上自动更新时间列；主键不能作为更新列
+func batchUpdateFields(s *schema.Schema, columns []string) ([]*schema.Field, error) {
+	var fields []*schema.Field
+	for _, name := range columns {
+		f := s.LookUpField(name)
+		if f == nil || f.DBName != name {
+			return nil, fmt.Errorf("表 %s 没有列 %s", s.Table, name)
+		}
+		if f.PrimaryKey {
+			return nil, fmt.Errorf("主键列 %s 不能作为批量更新的列", name)
+		}
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	for _, f := range s.Fields {
+		if f.AutoUpdateTime > 0 && f.DBName != "" && !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// batchUpdateRows 取出每个实体的主键与更新列的值，顺序与 s.PrimaryFields、fields 一致；
+// 自动更新时间列先填充当前时间再取值，主键为零值或重复时报错
+func batchUpdateRows[T any](db *gorm.DB, s *schema.Schema, entities []*T, fields []*schema.Field) ([][]any, error) {
+	ctx := db.Statement.Context
+	now := db.NowFunc()
+	seen := make(map[string]int, len(entities))
+	rows := make([][]any, len(entities))
+	for i, entity := range entities {
+		rv := reflect.ValueOf(entity).Elem()
+		row := make([]any, 0, len(s.PrimaryFields)+len(fields))
+		for _, f := range s.PrimaryFields {
+			v, zero := f.ValueOf(ctx, rv)
+			if zero {
+				return nil, fmt.Errorf("第 %d 行的主键 %s 为零值", i+1, f.DBName)
+			}
+			row = append(row, v)
+		}
+		key := fmt.Sprintf("%#v", row)
+		if j, ok := seen[key]; ok {
+			return nil, fmt.Errorf("第 %d 行与第 %d 行主键重复", i+1, j+1)
+		}
+		seen[key] = i
+
+		for _, f := range fields {
+			if f.AutoUpdateTime > 0 {
+				if err := f.Set(ctx, rv, now); err != nil {
+					return nil, fmt.Errorf("第 %d 行的列 %s 赋值失败: %w", i+1, f.DBName, err)
+				}
+			}
+			v, _ := f.ValueOf(ctx, rv)
+			row = append(row, v)
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// batchUpdateSQL 生成一条 UPDATE ... FROM (VALUES ...) 语句；参数按列的类型显式转换，
+// 否则 VALUES 中未知类型的参数会被推断为 text，无法赋值给整数、枚举等列
+func batchUpdateSQL(s *schema.Schema, fields []*schema.Field, types map[string]string, deletedAt string, rows [][]any) (string, []any) {
+	ident := func(name string) string { return pgx.Identifier{name}.Sanitize() }
+	keys := append(slices.Clone(s.PrimaryFields), fields...)
+	names := make([]string, len(keys))
+	casts := make([]string, len(keys))
+	for i, f := range keys {
+		names[i] = f.DBName
+		casts[i] = "?::" + types[f.DBName]
+	}
+	tuple := "(" + strings.Join(casts, ", ") + ")"
+
+	sets := make([]string, len(fields))
+	for i, f := range fields {
+		sets[i] = ident(f.DBName) + " = v." + ident(f.DBName)
+	}
+	on := make([]string, 0, len(s.PrimaryFields)+1)
+	for _, f := range s.PrimaryFields {
+		on = append(on, "t."+ident(f.DBName)+" = v."+ident(f.DBName))
+	}
+	if deletedAt != "" {
+		on = append(on, "t."+ident(deletedAt)+" IS NULL")
+	}
+
+	tuples := make([]string, len(rows))
+	vars := make([]any, 0, len(rows)*len(keys))
+	for i, row := range rows {
+		tuples[i] = tuple
+		vars = append(vars, row...)
+	}
+	sql := fmt.Sprintf("UPDATE %s AS t SET %s FROM (VALUES %s) AS v (%s) WHERE %s",
+		quoteQualified(s.Table), strings.Join(sets, ", "), strings.Join(tuples, ", "),
+		strings.Join(quoteColumns(names), ", "), strings.Join(on, " AND "))
+	return sql, vars
+}
+
+// columnSQLTypes 表中各列的完整类型名（如 character varying(100)、user_status），用于参数的类型转换
+func columnSQLTypes(db *gorm.DB, table string) (map[string]string, error) {
+	var cols []struct {
+		Name string
+		Type string
+	}
+	err := db.Raw(`SELECT attname AS name, format_type(atttypid, atttypmod) AS type FROM pg_attribute
+		WHERE attrelid = ?::regclass AND attnum > 0 AND NOT attisdropped`, quoteQualified(table)).Scan(&cols).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 的列类型失败: %w", table, err)
+	}
+	types := make(map[string]string, len(cols))
+	for _, c := range cols {
+		types[c.Name] = c.Type
+	}
+	return types, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"` // 容量淘汰 + 过期淘汰
+	Size      int    `json:"size"`
+}
+
+// HitRatio 命中率，尚无请求时为 0
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// lruCache 带 TTL 的 LRU 缓存，非并发安全，由调用方加锁
+type lruCache[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[K]*list.Element
+	onEvict  func(K, V)
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func newLRUCache[K comparable, V any](capacity int, ttl time.Duration, onEvict func(K, V)) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element),
+		onEvict:  onEvict,
+	}
+}
+
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+		c.removeElement(el, true)
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *lruCache[K, V]) put(key K, value V) {
+	expiresAt := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry[K, V])
+		entry.value, entry.expiresAt = value, expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back(), true)
+	}
+}
+
+func (c *lruCache[K, V]) remove(key K) {
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el, false)
+	}
+}
+
+func (c *lruCache[K, V]) removeElement(el *list.Element, evicted bool) {
+	entry := el.Value.(*lruEntry[K, V])
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	if evicted && c.onEvict != nil {
+		c.onEvict(entry.key, entry.value)
+	}
+}
+
+func (c *lruCache[K, V]) len() int {
+	return c.ll.Len()
+}
+
+// CachedRepository 为 BaseRepository 的按主键/唯一列查询加一层进程内 LRU+TTL 缓存
+// 写操作必须通过同一个包装器（Update/Delete）才能及时失效缓存；其他进程的写入只能依赖 TTL 过期
+// 缓存中保存实体副本，返回给调用方的也是副本，调用方修改返回值不会污染缓存
+type CachedRepository[T any, ID comparable] struct {
+	base *BaseRepository[T, ID]
+
+	mu      sync.Mutex
+	byID    *lruCache[ID, T]
+	byKey   map[string]ID   // 唯一列索引：column=value -> 主键
+	keysOf  map[ID][]string // 反向索引，主键失效时一并清理唯一列索引
+	gen     uint64          // 每次失效加一；查库期间发生过失效的结果可能已过时，不写入缓存
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	evicted atomic.Uint64
+}
+
+// NewCachedRepository 创建带缓存的仓库，capacity 为最多缓存的实体数，ttl 为缓存有效期
+func NewCachedRepository[T any, ID comparable](base *BaseRepository[T, ID], capacity int, ttl time.Duration) *CachedRepository[T, ID] {
+	r := &CachedRepository[T, ID]{
+		base:   base,
+		byKey:  make(map[string]ID),
+		keysOf: make(map[ID][]string),
+	}
+	r.byID = newLRUCache(capacity, ttl, func(id ID, _ T) {
+		r.evicted.Add(1)
+		r.dropKeys(id)
+	})
+	return r
+}
+
+// GetByID 根据ID查询实体，优先读缓存
+func (r *CachedRepository[T, ID]) GetByID(ctx context.Context, id ID) (*T, error) {
+	r.mu.Lock()
+	entity, ok := r.byID.get(id)
+	gen := r.gen
+	r.mu.Unlock()
+	if ok {
+		r.hits.Add(1)
+		return &entity, nil
+	}
+	r.misses.Add(1)
+
+	loaded, err := r.base.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	if r.gen == gen {
+		r.byID.put(id, *loaded)
+	}
+	r.mu.Unlock()
+	return loaded, nil
+}
+
+// GetByUnique 根据唯一列查询实体，如 GetByUnique(ctx, "email", "zhangsan@example.com")
+// column 必须有唯一约束，否则缓存的结果不确定
+func (r *CachedRepository[T, ID]) GetByUnique(ctx context.Context, column string, value any) (*T, error) {
+	key := fmt.Sprintf("%s=%v", column, value)
+	r.mu.Lock()
+	id, ok := r.byKey[key]
+	var entity T
+	if ok {
+		entity, ok = r.byID.get(id)
+	}
+	gen := r.gen
+	r.mu.Unlock()
+	if ok {
+		r.hits.Add(1)
+		return &entity, nil
+	}
+	r.misses.Add(1)
+
+	loaded, err := invoke(ctx, r.base, "GetByUnique", OpRead, func(ctx context.Context) (*T, error) {
+		var entity T
+		err := r.base.session(ctx).Where(clause.Eq{Column: clause.Column{Name: column}, Value: value}).First(&entity).Error
+		if err != nil {
+			return nil, err
+		}
+		return &entity, nil
+	}, column, value)
+	if err != nil {
+		return nil, err
+	}
+	entity = *loaded
+	id, err = r.base.primaryKey(&entity)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	if r.gen == gen {
+		r.byID.put(id, entity)
+		r.byKey[key] = id
+		r.keysOf[id] = append(r.keysOf[id], key)
+	}
+	r.mu.Unlock()
+	return &entity, nil
+}
+
+// Update 更新实体并失效其缓存
+func (r *CachedRepository[T, ID]) Update(ctx context.Context, entity *T) error {
+	id, err := r.base.primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	defer r.Invalidate(id)
+	return r.base.Update(ctx, entity)
+}
+
+// Delete 删除实体并失效其缓存
+func (r *CachedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	defer r.Invalidate(id)
+	return r.base.Delete(ctx, id)
+}
+
+// Invalidate 失效指定主键的缓存（含其唯一列索引）
+func (r *CachedRepository[T, ID]) Invalidate(id ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	r.byID.remove(id)
+	r.dropKeys(id)
+}
+
+// Purge 清空缓存
+func (r *CachedRepository[T, ID]) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	r.byID = newLRUCache(r.byID.capacity, r.byID.ttl, r.byID.onEvict)
+	r.byKey = make(map[string]ID)
+	r.keysOf = make(map[ID][]string)
+}
+
+// Stats 返回缓存命中统计
+func (r *CachedRepository[T, ID]) Stats() CacheStats {
+	r.mu.Lock()
+	size := r.byID.len()
+	r.mu.Unlock()
+	return CacheStats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Evictions: r.evicted.Load(),
+		Size:      size,
+	}
+}
+
+// Base 返回被包装的仓库，用于不经缓存的查询；通过它执行的写操作不会失效缓存
+func (r *CachedRepository[T, ID]) Base() *BaseRepository[T, ID] {
+	return r.base
+}
+
+// dropKeys 清理主键对应的唯一列索引，调用方需持有锁
+func (r *CachedRepository[T, ID]) dropKeys(id ID) {
+	for _, key := range r.keysOf[id] {
+		delete(r.byKey, key)
+	}
+	delete(r.keysOf, id)
+}
+
+// CachedUserRepository 带缓存的用户仓库，热点用户按ID/邮箱查询走缓存
+type CachedUserRepository struct {
+	*CachedRepository[User, uint]
+}
+
+// NewCachedUserRepository 创建带缓存的用户仓库
+func NewCachedUserRepository(db *gorm.DB, capacity int, ttl time.Duration) *CachedUserRepository {
+	return &CachedUserRepository{
+		CachedRepository: NewCachedRepository(NewBaseRepository[User, uint](db), capacity, ttl),
+	}
+}
+
+// GetByEmail 根据邮箱查询用户
+func (r *CachedUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.GetByUnique(ctx, "email", email)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// gorm 的全部处理器，callbackPoints 未指定处理器时使用
+var allProcessors = []string{"create", "query", "update", "delete", "row", "raw"}
+
+// callbackRegistrar gorm 处理器 Before/After 返回的注册点
+type callbackRegistrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// callbackPoint 一个处理器中某个回调之前与之后的注册点
+type callbackPoint struct {
+	processor     string // create、query、update、delete、row、raw
+	before, after callbackRegistrar
+}
+
+// callbackPoints 返回 processors（为空时为全部处理器）中回调 anchor 前后的注册点，anchor 为 "*" 时即全部回调之前与之后
+func callbackPoints(db *gorm.DB, anchor string, processors ...string) []callbackPoint {
+	if len(processors) == 0 {
+		processors = allProcessors
+	}
+	cb := db.Callback()
+	points := make([]callbackPoint, 0, len(processors))
+	for _, name := range processors {
+		p := callbackPoint{processor: name}
+		switch name {
+		case "create":
+			p.before, p.after = cb.Create().Before(anchor), cb.Create().After(anchor)
+		case "query":
+			p.before, p.after = cb.Query().Before(anchor), cb.Query().After(anchor)
+		case "update":
+			p.before, p.after = cb.Update().Before(anchor), cb.Update().After(anchor)
+		case "delete":
+			p.before, p.after = cb.Delete().Before(anchor), cb.Delete().After(anchor)
+		case "row":
+			p.before, p.after = cb.Row().Before(anchor), cb.Row().After(anchor)
+		case "raw":
+			p.before, p.after = cb.Raw().Before(anchor), cb.Raw().After(anchor)
+		default:
+			panic(fmt.Sprintf("未知的 gorm 处理器: %s", name))
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// callbackHook 以 name 注册的回调，fn 为 nil 时不注册
+type callbackHook struct {
+	name string
+	fn   func(*gorm.DB)
+}
+
+// registerAround 在各注册点之前注册 before、之后注册 after
+func registerAround(points []callbackPoint, before, after callbackHook) error {
+	for _, p := range points {
+		if before.fn != nil {
+			if err := p.before.Register(before.name, before.fn); err != nil {
+				return err
+			}
+		}
+		if after.fn != nil {
+			if err := p.after.Register(after.name, after.fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
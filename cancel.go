@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// defaultCancelGrace 发出取消请求后等待服务端中止语句的时间，超时后断开连接
+const defaultCancelGrace = 5 * time.Second
+
+// 调用方 ctx 取消时的两种处理方式：
+//
+//   - 默认（pgx 行为）：立即中断连接上的读写，后台发送取消请求后关闭连接；语句会被取消，但连接被丢弃，
+//     高并发下请求大量超时会引发连接风暴
+//   - 优雅取消（PostgresConfig.GracefulCancel）：先向服务端发送取消请求（等同 pg_cancel_backend），
+//     等服务端以 57014 中止语句后连接照常归还连接池；服务端在 grace 内仍未响应时退回默认行为
+//
+// 两种方式下调用方得到的错误都满足 errors.Is(err, context.Canceled/DeadlineExceeded)
+
+const cancelStateKey = "app:cancel_state"
+
+type cancelState struct {
+	pool      *sql.DB
+	conn      *sql.Conn
+	callerCtx context.Context
+	stop      func() bool
+	sent      chan struct{} // 取消请求发送完毕（或失败）后关闭
+	done      context.CancelFunc
+}
+
+// RegisterGracefulCancelCallbacks 为不在事务中的语句启用优雅取消，grace <= 0 时使用默认的 5s
+// Row/Rows 的结果在回调结束后才读取、事务中的语句无法单独取得连接，这两类仍使用 pgx 的默认行为
+func RegisterGracefulCancelCallbacks(db *gorm.DB, grace time.Duration) error {
+	if grace <= 0 {
+		grace = defaultCancelGrace
+	}
+	return registerAround(callbackPoints(db, "*", "create", "query", "update", "delete", "raw"),
+		callbackHook{"app:cancel_begin", func(db *gorm.DB) { beginGracefulCancel(db, grace) }},
+		callbackHook{"app:cancel_end", endGracefulCancel})
+}
+
+// beginGracefulCancel 为语句单独取出一条连接，驱动使用不随调用方取消的 ctx，
+// 调用方取消时改由 AfterFunc 发送取消请求，grace 后仍未结束再取消驱动 ctx
+func beginGracefulCancel(db *gorm.DB, grace time.Duration) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	pool, ok := db.Statement.ConnPool.(*sql.DB)
+	ctx := db.Statement.Context
+	if !ok || ctx.Done() == nil {
+		return
+	}
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	var pgConn *pgconn.PgConn
+	_ = conn.Raw(func(driverConn any) error {
+		if c, ok := driverConn.(*stdlib.Conn); ok {
+			pgConn = c.Conn().PgConn()
+		}
+		return nil
+	})
+	if pgConn == nil {
+		conn.Close()
+		return
+	}
+
+	driverCtx, done := context.WithCancel(context.WithoutCancel(ctx))
+	st := &cancelState{pool: pool, conn: conn, callerCtx: ctx, sent: make(chan struct{}), done: done}
+	st.stop = context.AfterFunc(ctx, func() {
+		cancelCtx, cancel := context.WithTimeout(driverCtx, grace)
+		defer cancel()
+		err := pgConn.CancelRequest(cancelCtx)
+		close(st.sent)
+		if err != nil {
+			log.Printf("发送取消请求失败，断开连接: %v", err)
+			done()
+			return
+		}
+		<-cancelCtx.Done()
+		if errors.Is(cancelCtx.Err(), context.DeadlineExceeded) {
+			log.Printf("服务端 %s 内未中止语句，断开连接", grace)
+			done()
+		}
+	})
+	db.Statement.ConnPool = conn
+	db.Statement.Context = driverCtx
+	db.InstanceSet(cancelStateKey, st)
+}
+
+// endGracefulCancel 归还连接；语句因调用方取消而失败时，把错误包装为调用方 ctx 的错误，同时保留服务端的 57014 错误
+func endGracefulCancel(db *gorm.DB) {
+	v, ok := db.InstanceGet(cancelStateKey)
+	if !ok {
+		return
+	}
+	st := v.(*cancelState)
+	// 取消请求已在发送中时等其完成再归还连接，服务端对空闲连接会忽略取消请求，不会误伤下一条语句
+	if !st.stop() {
+		<-st.sent
+	}
+	st.done()
+	st.conn.Close()
+	// 语句对象可能被链式调用复用，恢复原来的连接池与 ctx
+	db.Statement.ConnPool, db.Statement.Context = st.pool, st.callerCtx
+
+	var pgErr *pgconn.PgError
+	if errors.As(db.Error, &pgErr) && pgErr.Code == "57014" && st.callerCtx.Err() != nil {
+		db.Error = fmt.Errorf("%w: %w", st.callerCtx.Err(), db.Error)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"postgresql-test/dbtest"
+)
+
+// gracefulCancelDB 只有一条连接、启用优雅取消的会话，取消后的语句能否复用同一条连接即可看出连接是否被丢弃
+func gracefulCancelDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := OpenWithDialector(postgres.New(postgres.Config{DSN: dbtest.DSN(t)}),
+		WithGormConfig(&gorm.Config{SkipDefaultTransaction: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterGracefulCancelCallbacks(db, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func backendPID(t *testing.T, db *gorm.DB) int {
+	t.Helper()
+	var pid int
+	if err := db.Raw("SELECT pg_backend_pid()").Scan(&pid).Error; err != nil {
+		t.Fatal(err)
+	}
+	return pid
+}
+
+func TestGracefulCancel(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{"canceled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(200*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 200*time.Millisecond)
+		}, context.DeadlineExceeded},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			db := gracefulCancelDB(t)
+			pid := backendPID(t, db)
+
+			ctx, cancel := c.ctx()
+			defer cancel()
+			start := time.Now()
+			err := db.WithContext(ctx).Exec("SELECT pg_sleep(30)").Error
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("取消后语句 %s 才返回", elapsed)
+			}
+			if !errors.Is(err, c.want) {
+				t.Fatalf("错误 %v 不满足 errors.Is(%v)", err, c.want)
+			}
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+				t.Fatalf("期望服务端以 57014 中止语句，实际错误: %v", err)
+			}
+
+			// 连接已归还且未被丢弃：下一条语句落在同一个服务端进程上
+			sqlDB, _ := db.DB()
+			if stats := sqlDB.Stats(); stats.InUse != 0 || stats.OpenConnections != 1 {
+				t.Errorf("连接未归还: InUse=%d OpenConnections=%d", stats.InUse, stats.OpenConnections)
+			}
+			if got := backendPID(t, db); got != pid {
+				t.Errorf("取消后连接被替换: 服务端进程 %d -> %d", pid, got)
+			}
+		})
+	}
+}
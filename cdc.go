@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gorm.io/gorm"
+)
+
+// ChangeOp 变更类型
+type ChangeOp string
+
+const (
+	ChangeInsert   ChangeOp = "insert"
+	ChangeUpdate   ChangeOp = "update"
+	ChangeDelete   ChangeOp = "delete"
+	ChangeTruncate ChangeOp = "truncate"
+)
+
+// ChangeEvent 逻辑复制解码出的一行变更
+type ChangeEvent struct {
+	Table string   // schema.table
+	Op    ChangeOp // truncate 事件的 New/Old 为空
+	// New insert/update 之后的行；未修改的 TOAST 列不会出现
+	New map[string]any
+	// Old update/delete 之前的行；默认 REPLICA IDENTITY 下只包含主键列，
+	// 需要完整旧值时执行 ALTER TABLE ... REPLICA IDENTITY FULL
+	Old map[string]any
+	// LSN 所在事务的结束位置，处理完成后传给 CDCFeed.Ack
+	LSN        pglogrepl.LSN
+	CommitTime time.Time
+}
+
+// DecodeChange 将变更行（ChangeEvent.New 或 Old）按列名填充为模型
+//
+//	user, err := DecodeChange[User](db, ev.New)
+func DecodeChange[T any](db *gorm.DB, row map[string]any) (*T, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	entity := new(T)
+	rv := reflect.ValueOf(entity).Elem()
+	for column, value := range row {
+		f := stmt.Schema.LookUpField(column)
+		if f == nil || value == nil {
+			continue
+		}
+		if err := f.Set(context.Background(), rv, value); err != nil {
+			return nil, fmt.Errorf("列 %s: %w", column, err)
+		}
+	}
+	return entity, nil
+}
+
+// CDCOptions 变更订阅配置
+type CDCOptions struct {
+	Publication string // 发布名，默认 app_cdc
+	Slot        string // 复制槽名，默认 app_cdc
+	Tables      []any  // 订阅的模型，如 &User{}
+	// StatusInterval 向服务端报告已确认位置的间隔，默认 10s（需小于服务端 wal_sender_timeout）
+	StatusInterval time.Duration
+	// Buffer 事件通道容量，默认 256
+	Buffer int
+}
+
+// CDCFeed 基于逻辑复制（pgoutput）的变更订阅，下游无需轮询即可感知数据变化
+//
+// 复制槽会保留尚未确认的 WAL：消费者处理完事件后调用 Ack 推进确认位置，
+// 重启后从上次确认的位置继续（至少一次投递，同一事务的事件可能重复收到）；
+// 不再使用时必须调用 Drop 删除复制槽，否则服务端磁盘会被 WAL 占满
+//
+//	feed, _ := NewCDCFeed(db, CDCOptions{Tables: []any{&User{}}})
+//	_ = feed.Setup(ctx)
+//	events, _ := feed.Start(ctx)
+//	for ev := range events {
+//		handle(ev)
+//		feed.Ack(ev.LSN)
+//	}
+//	err := feed.Err()
+type CDCFeed struct {
+	db     *gorm.DB
+	opts   CDCOptions
+	tables []string
+
+	acked atomic.Uint64
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewCDCFeed 创建变更订阅，需要服务端 wal_level = logical，且当前用户具有 REPLICATION 权限
+func NewCDCFeed(db *gorm.DB, opts CDCOptions) (*CDCFeed, error) {
+	if activePoolerMode == PoolerTransaction {
+		return nil, fmt.Errorf("逻辑复制: %w", ErrUnsupportedWithPooler)
+	}
+	if len(opts.Tables) == 0 {
+		return nil, fmt.Errorf("至少需要订阅一个表")
+	}
+	if opts.Publication == "" {
+		opts.Publication = "app_cdc"
+	}
+	if opts.Slot == "" {
+		opts.Slot = "app_cdc"
+	}
+	if opts.StatusInterval <= 0 {
+		opts.StatusInterval = 10 * time.Second
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 256
+	}
+
+	f := &CDCFeed{db: db, opts: opts}
+	for _, model := range opts.Tables {
+		table, err := parseTableName(db, model)
+		if err != nil {
+			return nil, err
+		}
+		f.tables = append(f.tables, table)
+	}
+	return f, nil
+}
+
+// Setup 创建（或同步）发布与复制槽，可重复执行
+func (f *CDCFeed) Setup(ctx context.Context) error {
+	quoted := make([]string, len(f.tables))
+	for i, t := range f.tables {
+		quoted[i] = quoteQualified(t)
+	}
+	pub := pgx.Identifier{f.opts.Publication}.Sanitize()
+	db := f.db.WithContext(ctx)
+
+	var exists bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = ?)", f.opts.Publication).Scan(&exists).Error; err != nil {
+		return err
+	}
+	sql := fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", pub, strings.Join(quoted, ", "))
+	if exists {
+		sql = fmt.Sprintf("ALTER PUBLICATION %s SET TABLE %s", pub, strings.Join(quoted, ", "))
+	}
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("创建发布 %s 失败: %w", f.opts.Publication, err)
+	}
+
+	err := db.Exec(`SELECT pg_create_logical_replication_slot(?, 'pgoutput')
+		WHERE NOT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = ?)`,
+		f.opts.Slot, f.opts.Slot).Error
+	if err != nil {
+		return fmt.Errorf("创建复制槽 %s 失败: %w", f.opts.Slot, err)
+	}
+	return nil
+}
+
+// Drop 删除复制槽与发布，槽正被使用时失败
+func (f *CDCFeed) Drop(ctx context.Context) error {
+	db := f.db.WithContext(ctx)
+	err := db.Exec(`SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = ?`, f.opts.Slot).Error
+	if err != nil {
+		return fmt.Errorf("删除复制槽 %s 失败: %w", f.opts.Slot, err)
+	}
+	return db.Exec("DROP PUBLICATION IF EXISTS " + pgx.Identifier{f.opts.Publication}.Sanitize()).Error
+}
+
+// Ack 确认 lsn 之前的变更已处理，下次状态报告时服务端可回收对应的 WAL
+func (f *CDCFeed) Ack(lsn pglogrepl.LSN) {
+	for {
+		cur := f.acked.Load()
+		if uint64(lsn) <= cur || f.acked.CompareAndSwap(cur, uint64(lsn)) {
+			return
+		}
+	}
+}
+
+// Err 事件通道关闭后返回导致订阅结束的错误，ctx 取消时为 nil
+func (f *CDCFeed) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Start 建立复制连接并开始接收变更，返回的通道在 ctx 取消或出错时关闭
+// 事件按事务提交顺序投递，同一事务的事件在收到提交消息后一起发出
+func (f *CDCFeed) Start(ctx context.Context) (<-chan ChangeEvent, error) {
+	conn, err := f.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = pglogrepl.StartReplication(ctx, conn, f.opts.Slot, 0, pglogrepl.StartReplicationOptions{
+		PluginArgs: []string{"proto_version '1'", "publication_names " + quoteLiteral(f.opts.Publication)},
+	})
+	if err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("启动逻辑复制失败: %w", err)
+	}
+	log.Printf("开始订阅复制槽 %s 的变更: %s", f.opts.Slot, strings.Join(f.tables, ", "))
+
+	events := make(chan ChangeEvent, f.opts.Buffer)
+	go func() {
+		defer close(events)
+		defer conn.Close(context.Background())
+		if err := f.run(ctx, conn, events); err != nil && ctx.Err() == nil {
+			f.mu.Lock()
+			f.err = err
+			f.mu.Unlock()
+		}
+	}()
+	return events, nil
+}
+
+// connect 复用连接池的连接参数，建立 replication=database 的复制连接（不能来自连接池）
+func (f *CDCFeed) connect(ctx context.Context) (*pgconn.PgConn, error) {
+	var cfg *pgconn.Config
+	err := withPgConn(ctx, f.db, func(c *pgx.Conn) error {
+		cfg = c.Config().Config.Copy()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("建立复制连接失败: %w", err)
+	}
+	return conn, nil
+}
+
+// cdcDecoder 解析 pgoutput 消息，按事务缓存变更
+type cdcDecoder struct {
+	typeMap   *pgtype.Map
+	relations map[uint32]*pglogrepl.RelationMessage
+	pending   []ChangeEvent
+}
+
+func (f *CDCFeed) run(ctx context.Context, conn *pgconn.PgConn, events chan<- ChangeEvent) error {
+	dec := &cdcDecoder{typeMap: pgtype.NewMap(), relations: map[uint32]*pglogrepl.RelationMessage{}}
+	nextStatus := time.Now().Add(f.opts.StatusInterval)
+
+	for {
+		if !time.Now().Before(nextStatus) {
+			lsn := pglogrepl.LSN(f.acked.Load())
+			if err := pglogrepl.SendStandbyStatusUpdate(ctx, conn, pglogrepl.StandbyStatusUpdate{WALWritePosition: lsn}); err != nil {
+				return fmt.Errorf("发送复制状态失败: %w", err)
+			}
+			nextStatus = time.Now().Add(f.opts.StatusInterval)
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		raw, err := conn.ReceiveMessage(recvCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if pgconn.Timeout(err) {
+				continue
+			}
+			return fmt.Errorf("接收复制消息失败: %w", err)
+		}
+
+		switch msg := raw.(type) {
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyData:
+			switch msg.Data[0] {
+			case pglogrepl.PrimaryKeepaliveMessageByteID:
+				ka, err := pglogrepl.ParsePrimaryKeepaliveMessage(msg.Data[1:])
+				if err != nil {
+					return err
+				}
+				if ka.ReplyRequested {
+					nextStatus = time.Time{}
+				}
+			case pglogrepl.XLogDataByteID:
+				xld, err := pglogrepl.ParseXLogData(msg.Data[1:])
+				if err != nil {
+					return err
+				}
+				committed, err := dec.decode(xld.WALData)
+				if err != nil {
+					return err
+				}
+				for _, ev := range committed {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
+		}
+	}
+}
+
+// decode 处理一条 pgoutput 消息，收到事务提交时返回该事务的全部变更
+func (d *cdcDecoder) decode(data []byte) ([]ChangeEvent, error) {
+	msg, err := pglogrepl.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析 pgoutput 消息失败: %w", err)
+	}
+
+	switch m := msg.(type) {
+	case *pglogrepl.RelationMessage:
+		d.relations[m.RelationID] = m
+	case *pglogrepl.BeginMessage:
+		d.pending = d.pending[:0]
+	case *pglogrepl.InsertMessage:
+		return nil, d.add(m.RelationID, ChangeInsert, m.Tuple, nil)
+	case *pglogrepl.UpdateMessage:
+		return nil, d.add(m.RelationID, ChangeUpdate, m.NewTuple, m.OldTuple)
+	case *pglogrepl.DeleteMessage:
+		return nil, d.add(m.RelationID, ChangeDelete, nil, m.OldTuple)
+	case *pglogrepl.TruncateMessage:
+		for _, id := range m.RelationIDs {
+			if err := d.add(id, ChangeTruncate, nil, nil); err != nil {
+				return nil, err
+			}
+		}
+	case *pglogrepl.CommitMessage:
+		committed := make([]ChangeEvent, len(d.pending))
+		for i, ev := range d.pending {
+			ev.LSN, ev.CommitTime = m.TransactionEndLSN, m.CommitTime
+			committed[i] = ev
+		}
+		d.pending = d.pending[:0]
+		return committed, nil
+	}
+	return nil, nil
+}
+
+func (d *cdcDecoder) add(relationID uint32, op ChangeOp, newTuple, oldTuple *pglogrepl.TupleData) error {
+	rel, ok := d.relations[relationID]
+	if !ok {
+		return fmt.Errorf("未知的关系 ID %d", relationID)
+	}
+	ev := ChangeEvent{Table: rel.Namespace + "." + rel.RelationName, Op: op}
+	var err error
+	if ev.New, err = d.row(rel, newTuple); err != nil {
+		return err
+	}
+	if ev.Old, err = d.row(rel, oldTuple); err != nil {
+		return err
+	}
+	d.pending = append(d.pending, ev)
+	return nil
+}
+
+// row 将文本格式的元组按列类型解码；服务端未知的类型（如枚举）保留为字符串
+func (d *cdcDecoder) row(rel *pglogrepl.RelationMessage, tuple *pglogrepl.TupleData) (map[string]any, error) {
+	if tuple == nil {
+		return nil, nil
+	}
+	row := make(map[string]any, len(tuple.Columns))
+	for i, col := range tuple.Columns {
+		name := rel.Columns[i].Name
+		switch col.DataType {
+		case 'n':
+			row[name] = nil
+		case 'u':
+			// 未修改的 TOAST 值不随消息发送
+		case 't':
+			oid := rel.Columns[i].DataType
+			if dt, ok := d.typeMap.TypeForOID(oid); ok {
+				v, err := dt.Codec.DecodeValue(d.typeMap, oid, pgtype.TextFormatCode, col.Data)
+				if err != nil {
+					return nil, fmt.Errorf("解码列 %s 失败: %w", name, err)
+				}
+				row[name] = v
+			} else {
+				row[name] = string(col.Data)
+			}
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ChaosConfig 故障注入配置，各比例取值 0-1，全部为 0 时不启用。只用于测试与预发环境，prod 配置档中开启时 LoadConfig 报错；
+// 配置文件中为 chaos 段，也可用 CHAOS_<键名大写> 环境变量覆盖，如 CHAOS_CONN_ERROR_RATE=0.2
+type ChaosConfig struct {
+	LatencyRate       float64       `yaml:"latency_rate"`       // 注入延迟的比例
+	Latency           time.Duration `yaml:"latency"`            // 注入的最大延迟，实际延迟在 0-Latency 间均匀分布，0 表示 500ms
+	ConnErrorRate     float64       `yaml:"conn_error_rate"`    // 返回连接失败（08006）的比例，计入熔断器与重连器，RetryMiddleware 会重试只读操作
+	SerializationRate float64       `yaml:"serialization_rate"` // 写操作返回序列化失败（40001）的比例，IsSerializationFailure 可识别
+	Methods           []string      `yaml:"methods"`            // 只对这些仓库方法注入，为空时对全部方法注入
+}
+
+// Enabled 是否配置了任一故障
+func (c ChaosConfig) Enabled() bool {
+	return c.LatencyRate > 0 || c.ConnErrorRate > 0 || c.SerializationRate > 0
+}
+
+// ProdProfile 正式环境的配置档名，该配置档不允许开启故障注入
+const ProdProfile = "prod"
+
+// RegisterChaos 按配置的比例在 gorm 处理器执行 SQL 前注入延迟或错误，注入错误时不执行语句。错误为真实的 *pgconn.PgError，
+// 在回调链中与数据库返回的错误一样经过熔断器、重连器的记录与 RetryMiddleware 的重试（每次重试重新抽样）；
+// Methods 按 ctx 中的仓库操作过滤，不经仓库方法的语句只在 Methods 为空时注入：
+//
+//	err := RegisterChaos(db, ChaosConfig{ConnErrorRate: 0.3})
+func RegisterChaos(db *gorm.DB, cfg ChaosConfig) error {
+	if cfg.Latency <= 0 {
+		cfg.Latency = 500 * time.Millisecond
+	}
+	// 误在正式环境开启时便于从日志中发现
+	log.Printf("已启用故障注入: 延迟 %.0f%%（最多 %s），连接失败 %.0f%%，序列化失败 %.0f%%",
+		cfg.LatencyRate*100, cfg.Latency, cfg.ConnErrorRate*100, cfg.SerializationRate*100)
+	for _, name := range allProcessors {
+		write := name == "create" || name == "update" || name == "delete"
+		inject := func(db *gorm.DB) {
+			if db.Error != nil || db.DryRun {
+				return
+			}
+			ctx := db.Statement.Context
+			op := operationFrom(ctx)
+			if len(cfg.Methods) > 0 && (op == nil || !slices.Contains(cfg.Methods, op.Method)) {
+				return
+			}
+			if hit(cfg.LatencyRate) {
+				select {
+				case <-time.After(rand.N(cfg.Latency)):
+				case <-ctx.Done():
+					db.AddError(ctx.Err())
+					return
+				}
+			}
+			if hit(cfg.ConnErrorRate) {
+				db.AddError(&pgconn.PgError{Severity: "FATAL", Code: "08006", Message: "chaos: 注入的连接失败"})
+				return
+			}
+			if (write || op != nil && op.Kind == OpWrite) && hit(cfg.SerializationRate) {
+				db.AddError(&pgconn.PgError{Severity: "ERROR", Code: "40001", Message: "chaos: 注入的序列化失败"})
+			}
+		}
+		// 紧挨着执行 SQL 的回调注册，熔断器在此之前放行、之后记录结果
+		if err := registerAround(callbackPoints(db, "gorm:"+name, name), callbackHook{"app:chaos", inject}, callbackHook{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
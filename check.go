@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// AddCheckConstraint 为表添加 CHECK 约束（已存在则跳过）
+// 固定条件也可以直接写在 gorm 标签中（`gorm:"check:age_range,age BETWEEN 0 AND 120"`），该方法用于已有表的增量迁移
+func (r *BaseRepository[T, ID]) AddCheckConstraint(name, expr string) error {
+	table, err := r.tableName()
+	if err != nil {
+		return err
+	}
+	return addCheckConstraint(r.db, table, name, expr)
+}
+
+// addCheckConstraint 先以 NOT VALID 添加（只短暂加锁，对新写入立即生效），再单独校验存量数据；
+// 存量数据不满足时返回错误，约束保留为 NOT VALID 状态，修复数据后重新执行即可完成校验
+func addCheckConstraint(db *gorm.DB, table, name, expr string) error {
+	var validated []bool
+	if err := db.Raw("SELECT convalidated FROM pg_constraint WHERE conname = ? AND conrelid = ?::regclass", name, table).
+		Scan(&validated).Error; err != nil {
+		return fmt.Errorf("查询约束 %s 失败: %w", name, err)
+	}
+	if len(validated) == 0 {
+		sql := fmt.Sprintf("ALTER TABLE ? ADD CONSTRAINT ? CHECK (%s) NOT VALID", expr)
+		if err := db.Exec(sql, clause.Table{Name: table}, clause.Column{Name: name}).Error; err != nil {
+			return fmt.Errorf("表 %s 添加检查约束 %s 失败: %w", table, name, err)
+		}
+	} else if validated[0] {
+		return nil
+	}
+	if err := db.Exec("ALTER TABLE ? VALIDATE CONSTRAINT ?", clause.Table{Name: table}, clause.Column{Name: name}).Error; err != nil {
+		return fmt.Errorf("表 %s 的存量数据不满足检查约束 %s: %w", table, name, err)
+	}
+	log.Printf("表 %s 添加检查约束 %s 成功!", table, name)
+	return nil
+}
+
+// MigrateValidateChecks 按模型的 validate 标签生成数据库 CHECK 约束，使数据库与应用校验保持一致：
+// 数值字段的 min/max/gte/lte/gt/lt 生成范围条件，字符串字段的 min/max/len 生成 char_length 条件，oneof 生成 IN 条件；
+// 约束名为 chk_<table>_<column>，已存在的跳过；存量数据不满足时（如校验规则收紧前写入的数据）约束保留为 NOT VALID，
+// 只对新写入生效，记录日志而不中断迁移，修复数据后再次迁移即完成校验
+//
+//	Age int `validate:"min=0,max=120"`  ->  CONSTRAINT chk_users_age CHECK ("age" BETWEEN 0 AND 120)
+func MigrateValidateChecks(ctx context.Context, db *gorm.DB, models ...any) error {
+	db = db.WithContext(ctx)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("解析模型 %T 失败: %w", model, err)
+		}
+		_, base, ok := strings.Cut(stmt.Table, ".")
+		if !ok {
+			base = stmt.Table
+		}
+		for _, f := range stmt.Schema.Fields {
+			expr := validateCheckExpr(f)
+			if expr == "" {
+				continue
+			}
+			name := "chk_" + base + "_" + f.DBName
+			if err := addCheckConstraint(db, stmt.Table, name, expr); err != nil {
+				if !isCheckViolation(err) {
+					return err
+				}
+				log.Printf("约束 %s 暂为 NOT VALID: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isCheckViolation 是否违反检查约束（未开启 TranslateError 时为 pgconn.PgError 23514）
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, gorm.ErrCheckConstraintViolated) || errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
+
+// validateCheckExpr 将字段的 validate 标签转换为 CHECK 条件，无可转换的规则时返回空
+func validateCheckExpr(f *schema.Field) string {
+	tag := f.Tag.Get("validate")
+	if tag == "" || f.DBName == "" {
+		return ""
+	}
+	col := pgx.Identifier{f.DBName}.Sanitize()
+	kind := f.IndirectFieldType.Kind()
+	isString := kind == reflect.String
+	isNumber := kind >= reflect.Int && kind <= reflect.Float64
+	if isString {
+		col = "char_length(" + col + ")"
+	}
+
+	var conds []string
+	var lower, upper string
+	for rule := range strings.SplitSeq(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "oneof" && (isString || isNumber) {
+			values := strings.Fields(param)
+			for i, v := range values {
+				if isString {
+					values[i] = quoteLiteral(v)
+				} else if _, err := strconv.ParseFloat(v, 64); err != nil {
+					return ""
+				}
+			}
+			conds = append(conds, pgx.Identifier{f.DBName}.Sanitize()+" IN ("+strings.Join(values, ", ")+")")
+			continue
+		}
+		if !isString && !isNumber {
+			continue
+		}
+		if _, err := strconv.ParseFloat(param, 64); err != nil {
+			continue
+		}
+		switch name {
+		case "min", "gte":
+			lower = param
+		case "max", "lte":
+			upper = param
+		case "len":
+			lower, upper = param, param
+		case "gt":
+			conds = append(conds, col+" > "+param)
+		case "lt":
+			conds = append(conds, col+" < "+param)
+		}
+	}
+	switch {
+	case lower != "" && upper != "":
+		conds = append(conds, col+" BETWEEN "+lower+" AND "+upper)
+	case lower != "":
+		conds = append(conds, col+" >= "+lower)
+	case upper != "":
+		conds = append(conds, col+" <= "+upper)
+	}
+	return strings.Join(conds, " AND ")
+}
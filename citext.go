@@ -0,0 +1,42 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CIText 大小写不敏感的文本，对应 citext 扩展类型：比较、唯一索引与查询条件均忽略大小写，读出时保留写入时的大小写
+type CIText string
+
+func (CIText) GormDataType() string { return "citext" }
+
+func (s CIText) Value() (driver.Value, error) { return string(s), nil }
+
+func (s *CIText) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = CIText(v)
+	case []byte:
+		*s = CIText(v)
+	default:
+		return fmt.Errorf("无法将 %T 扫描为 CIText", src)
+	}
+	return nil
+}
+
+func (s CIText) String() string { return string(s) }
+
+// EqualFold 按 citext 的语义比较（忽略大小写），供内存实现与数据库保持一致
+func (s CIText) EqualFold(other CIText) bool {
+	return strings.EqualFold(string(s), string(other))
+}
+
+// EnsureCIText 确保已安装 citext 扩展，需在迁移含 CIText 列的表之前调用
+func EnsureCIText(db *gorm.DB) error {
+	return ensureExtension(db, "citext")
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+// DSNEnv 命令行默认读取的数据库连接串环境变量，未设置时按配置文件连接
+const DSNEnv = "DATABASE_DSN"
+
+// cliFlags 全局命令行参数
+type cliFlags struct {
+	dsn      string
+	logLevel string
+	config   string
+	profile  string
+
+	loaded *AppConfig // withDB 加载的配置，指定 --dsn 时为 nil
+}
+
+// newRootCmd 命令行入口：不带子命令时运行 CRUD 演示
+//
+//	go run . migrate up
+//	go run . seed demo_users
+//	go run . user list --limit 20
+//	go run . --config config.yaml --profile staging migrate up
+//	DATABASE_DSN="host=localhost user=postgres dbname=app sslmode=disable" go run . health
+func newRootCmd() *cobra.Command {
+	flags := &cliFlags{}
+	root := &cobra.Command{
+		Use:          "postgresql-test",
+		Short:        "GORM PostgreSQL 仓库演示与数据库管理工具",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.loadConfig()
+			if err != nil {
+				return err
+			}
+			runDemo(cmd.Context(), cfg)
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&flags.dsn, "dsn", os.Getenv(DSNEnv), "数据库连接串，默认读取 "+DSNEnv)
+	root.PersistentFlags().StringVar(&flags.logLevel, "log-level", "", "SQL 日志级别: silent/error/warn/info，默认取配置文件的 logging.level")
+	root.PersistentFlags().StringVar(&flags.config, "config", envOr(ConfigEnv, DefaultConfigFile), "配置文件路径，默认读取 "+ConfigEnv)
+	root.PersistentFlags().StringVar(&flags.profile, "profile", "", "配置档，默认读取 "+ProfileEnv+"，未设置时为 "+DefaultProfile)
+
+	root.AddCommand(
+		newMigrateCmd(flags),
+		newSeedCmd(flags),
+		newUserCmd(flags),
+		newExportCmd(flags),
+		newHealthCmd(flags),
+		newServeCmd(flags),
+	)
+	return root
+}
+
+// loadConfig 按 --config 与 --profile 加载配置，并按配置档开启或关闭清空、删除表的保护
+func (f *cliFlags) loadConfig() (*AppConfig, error) {
+	cfg, err := LoadConfig(f.config, f.profile)
+	if err != nil {
+		return nil, err
+	}
+	SetAllowDestructive(cfg.AllowDestructive)
+	return cfg, nil
+}
+
+// withDB 连接数据库执行 fn，结束后优雅关闭；指定 --dsn 时不读取配置文件中的数据库配置
+func (f *cliFlags) withDB(ctx context.Context, fn func(db *gorm.DB) error) error {
+	var (
+		dsnOrCfg any = f.dsn
+		logging  LoggingConfig
+	)
+	if f.dsn == "" {
+		cfg, err := f.loadConfig()
+		if err != nil {
+			return err
+		}
+		dsnOrCfg, logging, f.loaded = &cfg.Database, cfg.Logging, cfg
+	}
+	l, err := logging.Logger(f.logLevel)
+	if err != nil {
+		return err
+	}
+	db, err := NewPostgresDB(dsnOrCfg, WithLogger(l), WithReconnect(ReconnectConfig{}))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = Shutdown(shutdownCtx)
+	}()
+	return fn(db)
+}
+
+func newMigrateCmd(flags *cliFlags) *cobra.Command {
+	cmd := &cobra.Command{Use: "migrate", Short: "管理表结构"}
+
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "按依赖顺序创建或更新全部已注册模型的表结构",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				if err := MigrateAll(cmd.Context(), db); err != nil {
+					return fmt.Errorf("迁移失败: %w", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), "迁移完成")
+				return nil
+			})
+		},
+	}
+
+	var confirmed bool
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "按依赖逆序删除全部表及枚举类型（数据不可恢复）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !confirmed {
+				return fmt.Errorf("该操作会删除全部数据，确认请加 --yes")
+			}
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				if err := DropAll(cmd.Context(), db); err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), "已删除全部表")
+				return nil
+			})
+		},
+	}
+	down.Flags().BoolVar(&confirmed, "yes", false, "确认删除")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "查看各模型的表与缺失列",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				models, err := RegisteredModels(db)
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "TABLE\tEXISTS\tMISSING COLUMNS")
+				for _, model := range models {
+					table, err := parseTableName(db, model)
+					if err != nil {
+						return err
+					}
+					missing, err := missingColumns(db, model)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(w, "%s\t%t\t%v\n", table, db.Migrator().HasTable(model), missing)
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	check := &cobra.Command{
+		Use:   "check",
+		Short: "比较表结构与模型定义（列类型、可空性、索引），有差异时返回非零状态",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				models, err := RegisteredModels(db)
+				if err != nil {
+					return err
+				}
+				drifts, err := ValidateSchema(cmd.Context(), db, models...)
+				if err != nil {
+					return err
+				}
+				if len(drifts) > 0 {
+					return &SchemaDriftError{Drifts: drifts}
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), "表结构与模型一致")
+				return nil
+			})
+		},
+	}
+
+	cmd.AddCommand(up, down, status, check)
+	return cmd
+}
+
+// missingColumns 模型中存在但表中缺失的列
+func missingColumns(db *gorm.DB, model any) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	var missing []string
+	if !db.Migrator().HasTable(model) {
+		return missing, nil
+	}
+	for _, name := range stmt.Schema.DBNames {
+		if !db.Migrator().HasColumn(model, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+func newSeedCmd(flags *cliFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "seed [names...]",
+		Short: "执行种子集（不指定时执行全部，已执行过的跳过）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				seeder := NewSeeder(db)
+				RegisterUserSeeds(seeder)
+				return seeder.Seed(cmd.Context(), args...)
+			})
+		},
+	}
+}
+
+func newUserCmd(flags *cliFlags) *cobra.Command {
+	cmd := &cobra.Command{Use: "user", Short: "管理用户"}
+
+	var user User
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "创建用户",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				if err := NewUserRepository(db).Create(cmd.Context(), &user); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "已创建用户 ID=%d\n", user.ID)
+				return nil
+			})
+		},
+	}
+	create.Flags().StringVar(&user.Name, "name", "", "姓名")
+	create.Flags().StringVar((*string)(&user.Email), "email", "", "邮箱")
+	create.Flags().IntVar(&user.Age, "age", 0, "年龄")
+	_ = create.MarkFlagRequired("name")
+	_ = create.MarkFlagRequired("email")
+
+	var offset, limit int
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "分页列出用户",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				page, err := NewUserRepository(db).List(cmd.Context(), offset, limit, OrderBy("id"))
+				if err != nil {
+					return err
+				}
+				return printUsers(cmd.OutOrStdout(), page.Items, page.Total)
+			})
+		},
+	}
+	list.Flags().IntVar(&offset, "offset", 0, "跳过的行数")
+	list.Flags().IntVar(&limit, "limit", 20, "返回的行数")
+
+	del := &cobra.Command{
+		Use:   "delete <id>",
+		Short: "软删除用户",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 0)
+			if err != nil {
+				return fmt.Errorf("无效的用户ID: %s", args[0])
+			}
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				return NewUserRepository(db).Delete(cmd.Context(), uint(id))
+			})
+		},
+	}
+
+	cmd.AddCommand(create, list, del)
+	return cmd
+}
+
+func printUsers(out io.Writer, users []*User, total int64) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tAGE\tSTATUS\tCREATED AT")
+	for _, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", u.ID, u.Name, u.Email, u.Age, u.Status, u.CreatedAt.Format(time.DateTime))
+	}
+	fmt.Fprintf(w, "共 %d 个用户\n", total)
+	return w.Flush()
+}
+
+func newExportCmd(flags *cliFlags) *cobra.Command {
+	var format, output string
+	var includeDeleted bool
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "导出用户（csv 或 jsonl）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				repo := NewBaseRepository[User, uint](db)
+				switch format {
+				case "csv":
+					if includeDeleted {
+						return fmt.Errorf("csv 导出不支持 --include-deleted，请使用 jsonl")
+					}
+					return repo.ExportCSV(cmd.Context(), w, nil)
+				case "jsonl":
+					_, err := repo.ExportJSONL(cmd.Context(), w, JSONLExportOptions{IncludeDeleted: includeDeleted})
+					return err
+				default:
+					return fmt.Errorf("不支持的导出格式: %s", format)
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "csv", "导出格式: csv/jsonl")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "输出文件，- 表示标准输出")
+	cmd.Flags().BoolVar(&includeDeleted, "include-deleted", false, "同时导出已软删除的用户")
+	return cmd
+}
+
+func newHealthCmd(flags *cliFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "health",
+		Short: "检查数据库连通性与连接池状态",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Second)
+				defer cancel()
+
+				start := time.Now()
+				info, err := ServerInfo(ctx, db)
+				if err != nil {
+					return fmt.Errorf("数据库不可用: %w", err)
+				}
+				sqlDB, err := db.DB()
+				if err != nil {
+					return err
+				}
+				stats := sqlDB.Stats()
+				out := cmd.OutOrStdout()
+				fmt.Fprintf(out, "状态: ok（%s）\n", time.Since(start).Round(time.Millisecond))
+				fmt.Fprintf(out, "版本: %s\n", info.Version)
+				fmt.Fprintf(out, "连接: 打开 %d，使用中 %d，空闲 %d，服务端上限 %d\n",
+					stats.OpenConnections, stats.InUse, stats.Idle, info.MaxConnections)
+				return nil
+			})
+		},
+	}
+}
+
+func newServeCmd(flags *cliFlags) *cobra.Command {
+	var addr, grpcAddr, settingsChannel, adminToken, schemaCheck string
+	var jobWorkers int
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "启动用户资源的 REST 与 GraphQL 服务（可选同时启动 gRPC 服务）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.withDB(cmd.Context(), func(db *gorm.DB) error {
+				models, err := RegisteredModels(db)
+				if err != nil {
+					return err
+				}
+				if err := CheckSchema(cmd.Context(), db, SchemaCheckMode(schemaCheck), models...); err != nil {
+					return err
+				}
+				repo := NewUserRepository(db)
+				if flags.loaded != nil && len(flags.loaded.RateLimits) > 0 {
+					repo.(*userRepository).Use(RateLimitMiddleware(flags.loaded.RateLimits))
+				}
+				if flags.loaded != nil && flags.loaded.Chaos.Enabled() {
+					if err := RegisterChaos(db, flags.loaded.Chaos); err != nil {
+						return err
+					}
+				}
+				errCh := make(chan error, 2)
+
+				// 日志级别、慢 SQL 阈值与连接池大小随配置文件或设置通知热加载
+				if flags.dsn == "" {
+					go WatchConfig(cmd.Context(), db, flags.config, flags.profile, flags.logLevel, 0)
+					go ReloadOnSIGHUP(cmd.Context(), db, flags.config, flags.profile, flags.logLevel)
+				}
+				if replicaRouter != nil && flags.loaded != nil {
+					go NewReplicaLagMonitor(replicaRouter, ReplicaLagConfig{MaxLag: flags.loaded.Database.ReplicaMaxLag}).Run(cmd.Context())
+				}
+				if jobWorkers > 0 {
+					q, err := NewJobQueue(db, JobQueueConfig{Workers: jobWorkers})
+					if err != nil {
+						return err
+					}
+					go q.Run(cmd.Context())
+				}
+				if settingsChannel != "" {
+					go func() {
+						if err := ListenSettings(cmd.Context(), db, settingsChannel); err != nil {
+							log.Printf("监听设置通知失败: %v", err)
+						}
+					}()
+				}
+
+				engine := NewServer(repo)
+				RegisterGraphQL(engine, db)
+				if adminToken != "" {
+					RegisterAdminRoutes(engine, db, adminToken)
+				}
+				srv := &http.Server{Addr: addr, Handler: engine}
+				go func() { errCh <- srv.ListenAndServe() }()
+				fmt.Fprintf(cmd.OutOrStdout(), "HTTP 监听 %s\n", addr)
+
+				var grpcSrv *grpc.Server
+				if grpcAddr != "" {
+					lis, err := net.Listen("tcp", grpcAddr)
+					if err != nil {
+						return err
+					}
+					grpcSrv = NewGRPCServer(repo, 0)
+					go func() { errCh <- grpcSrv.Serve(lis) }()
+					fmt.Fprintf(cmd.OutOrStdout(), "gRPC 监听 %s\n", grpcAddr)
+				}
+
+				select {
+				case err := <-errCh:
+					return err
+				case <-cmd.Context().Done():
+				}
+				// 先停止接收新请求并等待处理中的请求结束，再关闭数据库
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if grpcSrv != nil {
+					stopped := make(chan struct{})
+					go func() { grpcSrv.GracefulStop(); close(stopped) }()
+					select {
+					case <-stopped:
+					case <-ctx.Done():
+						grpcSrv.Stop()
+					}
+				}
+				if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return err
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", ":8080", "HTTP 监听地址")
+	cmd.Flags().StringVar(&grpcAddr, "grpc-addr", "", "gRPC 监听地址，为空时不启动")
+	cmd.Flags().StringVar(&schemaCheck, "schema-check", string(SchemaCheckWarn), "启动时表结构与模型不一致的处理: off/warn/fail")
+	cmd.Flags().StringVar(&adminToken, "admin-token", os.Getenv(AdminTokenEnv), "运维接口 /admin 的访问令牌，为空时不注册，默认读取 "+AdminTokenEnv)
+	cmd.Flags().StringVar(&settingsChannel, "settings-channel", "", "接收运行时设置的 LISTEN 频道，为空时不监听")
+	cmd.Flags().IntVar(&jobWorkers, "job-workers", 2, "同时执行的异步查询作业数，0 表示本实例不执行作业")
+	return cmd
+}
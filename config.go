@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	// ConfigEnv 配置文件路径的环境变量，未设置时使用 DefaultConfigFile
+	ConfigEnv = "APP_CONFIG"
+	// ProfileEnv 配置档的环境变量，未设置时使用 DefaultProfile
+	ProfileEnv = "APP_PROFILE"
+
+	DefaultConfigFile = "config.yaml"
+	DefaultProfile    = "dev"
+)
+
+// AppConfig 一个配置档解析后的完整配置
+type AppConfig struct {
+	Profile  string         `yaml:"-"`
+	Database PostgresConfig `yaml:"database"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Chaos    ChaosConfig    `yaml:"chaos"` // 故障注入，只在测试、预发配置档中配置
+
+	// RateLimits 导出、统计等开销大的操作按类别限流（serve 命令生效），见 RateLimitMiddleware
+	RateLimits map[MethodClass]RateLimit `yaml:"rate_limits"`
+
+	// AllowDestructive 允许 TruncateTable/DropTable 不传 Unsafe 时执行，只应在开发、测试配置档开启
+	AllowDestructive bool `yaml:"allow_destructive"`
+}
+
+// LoggingConfig SQL 日志配置
+type LoggingConfig struct {
+	Level                     string        `yaml:"level"`                         // silent/error/warn/info，为空时为 warn
+	SlowThreshold             time.Duration `yaml:"slow_threshold"`                // 慢 SQL 阈值，0 表示 200ms
+	Colorful                  bool          `yaml:"colorful"`                      // 终端彩色输出
+	IgnoreRecordNotFoundError bool          `yaml:"ignore_record_not_found_error"` // 不记录 ErrRecordNotFound
+}
+
+// Logger 按配置创建可热加载的 gorm logger，level 非空时覆盖配置的级别
+func (c LoggingConfig) Logger(level string) (*ReloadableLogger, error) {
+	if level == "" {
+		level = c.Level
+	}
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	slow := c.SlowThreshold
+	if slow <= 0 {
+		slow = 200 * time.Millisecond
+	}
+	return NewReloadableLogger(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold:             slow,
+		LogLevel:                  l,
+		Colorful:                  c.Colorful,
+		IgnoreRecordNotFoundError: c.IgnoreRecordNotFoundError,
+	}), nil
+}
+
+func parseLogLevel(level string) (logger.LogLevel, error) {
+	switch level {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "", "warn":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	}
+	return 0, fmt.Errorf("未知的日志级别: %s", level)
+}
+
+func logLevelName(level logger.LogLevel) string {
+	switch level {
+	case logger.Silent:
+		return "silent"
+	case logger.Error:
+		return "error"
+	case logger.Warn:
+		return "warn"
+	}
+	return "info"
+}
+
+// configFile 配置文件结构：defaults 为各配置档共用的值，profiles 中的同名字段覆盖它
+//
+//	defaults:
+//	  database: {port: 5432, sslmode: disable}
+//	profiles:
+//	  dev:
+//	    database: {host: localhost, password: postgres}
+//	  prod:
+//	    database: {host: db.internal, password: "${PG_PASSWORD}"}
+type configFile struct {
+	Defaults yaml.Node            `yaml:"defaults"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// LoadConfig 读取 path 中的 profile 配置档，profile 为空时依次取 APP_PROFILE 与 dev。
+// 解析后字符串字段中的 ${VAR} 替换为环境变量的值（未设置时为空），密码等敏感信息不必写进文件；
+// 只替换字段值中显式的 ${VAR}，不会展开 $VAR 形式，也不会改动注释与其他字段中的 $（如密码中的 $）。再以环境变量覆盖单个字段：
+// database 下的字段对应 PG_<键名大写>（如 PG_HOST、PG_STATEMENT_TIMEOUT），logging 下的对应 LOG_<键名大写>，
+// 列表以逗号分隔
+func LoadConfig(path, profile string) (*AppConfig, error) {
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	node, ok := file.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(file.Profiles))
+		for name := range file.Profiles {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("配置文件 %s 中没有配置档 %q，可用: %s", path, profile, strings.Join(names, ", "))
+	}
+
+	cfg := &AppConfig{Profile: profile}
+	// 先解码 defaults 再解码配置档，配置档中未出现的字段保留默认值
+	for _, n := range []*yaml.Node{&file.Defaults, &node} {
+		if n.IsZero() {
+			continue
+		}
+		if err := n.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("解析配置档 %s 失败: %w", profile, err)
+		}
+	}
+	expandEnvRefs(reflect.ValueOf(cfg).Elem())
+	if err := applyEnvOverrides("PG_", &cfg.Database); err != nil {
+		return nil, err
+	}
+	if err := applyEnvOverrides("CHAOS_", &cfg.Chaos); err != nil {
+		return nil, err
+	}
+	if err := applyEnvOverrides("LOG_", &cfg.Logging); err != nil {
+		return nil, err
+	}
+	if profile == ProdProfile && cfg.Chaos.Enabled() {
+		return nil, fmt.Errorf("配置档 %s 不允许开启故障注入（chaos 段或 CHAOS_* 环境变量）", profile)
+	}
+	return cfg, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envRef 配置值中的环境变量引用 ${VAR}
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvRefs 将 v 中（含嵌套的结构体、指针与字符串切片）字符串字段的 ${VAR} 替换为环境变量的值
+func expandEnvRefs(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			expandEnvRefs(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandEnvRefs(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandEnvRefs(v.Index(i))
+		}
+	case reflect.String:
+		v.SetString(envRef.ReplaceAllStringFunc(v.String(), func(ref string) string {
+			return os.Getenv(envRef.FindStringSubmatch(ref)[1])
+		}))
+	}
+}
+
+// applyEnvOverrides 以 prefix+yaml 键名（大写）的环境变量覆盖 cfg 的字段
+func applyEnvOverrides(prefix string, cfg any) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		env := prefix + strings.ToUpper(key)
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setFromString(v.Field(i), raw); err != nil {
+			return fmt.Errorf("环境变量 %s 的值无效: %w", env, err)
+		}
+	}
+	return nil
+}
+
+func setFromString(f reflect.Value, raw string) error {
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(raw)
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case f.CanInt():
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case f.CanFloat():
+		x, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(x)
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		f.Set(reflect.ValueOf(items).Convert(f.Type()))
+	default:
+		return errors.New("不支持的字段类型 " + f.Type().String())
+	}
+	return nil
+}
+
+// envOr 环境变量 key 的值，未设置或为空时返回 fallback
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
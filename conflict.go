@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ConflictPolicy CreateResolving 遇到唯一键重复时的处理方式
+type ConflictPolicy int
+
+const (
+	ConflictReport  ConflictPolicy = iota // 返回 *ConflictError，说明占用该键的行
+	ConflictRestore                       // 占用该键的行已软删除时恢复它并以新值覆盖，否则同 ConflictReport
+)
+
+// ConflictError 唯一键冲突，Unwrap 为原始的唯一约束错误，isUniqueViolation 等判断仍然成立
+type ConflictError[T any] struct {
+	Constraint string   // 冲突的约束名，开启 TranslateError 时为空
+	Columns    []string // 冲突的列
+	Existing   *T       // 占用该键的行（含已软删除的行），未能查到时为 nil
+	Deleted    bool     // Existing 是否已软删除
+	Err        error
+}
+
+func (e *ConflictError[T]) Error() string {
+	state := "已存在"
+	if e.Deleted {
+		state = "已存在（已删除）"
+	}
+	return fmt.Sprintf("%s 相同的记录%s: %v", strings.Join(e.Columns, ", "), state, e.Err)
+}
+
+func (e *ConflictError[T]) Unwrap() error { return e.Err }
+
+// CreateResolving 创建实体，唯一键重复时按 policy 处理，返回是否恢复了已软删除的行。注册流程中可以据此
+// 区分"邮箱已被使用"与"恢复已注销的账号"：
+//
+//	restored, err := repo.CreateResolving(ctx, user, ConflictRestore)
+//	var conflict *ConflictError[User]
+//	if errors.As(err, &conflict) { ... conflict.Existing ... }
+//
+// ConflictRestore 在插入前查找唯一索引列相同且已软删除的行，找到时保留其主键与创建时间，其余字段以 entity 覆盖，
+// 并把 entity 刷新为恢复后的行；唯一索引为 MigrateSoftDeleteUniques 建立的部分索引时，插入本身不会与已删除的行冲突，
+// 因此恢复必须在插入前判断
+func (r *BaseRepository[T, ID]) CreateResolving(ctx context.Context, entity *T, policy ConflictPolicy) (restored bool, err error) {
+	return invoke(ctx, r, "CreateResolving", OpWrite, func(ctx context.Context) (restored bool, err error) { return r.createResolving(ctx, entity, policy) }, entity, policy)
+}
+
+func (r *BaseRepository[T, ID]) createResolving(ctx context.Context, entity *T, policy ConflictPolicy) (restored bool, err error) {
+	s, err := r.modelSchema()
+	if err != nil {
+		return false, err
+	}
+	err = r.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if policy == ConflictRestore {
+			if restored, err = r.restoreDeleted(ctx, tx, s, entity); err != nil || restored {
+				return err
+			}
+		}
+		return tx.Create(entity).Error
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return false, r.conflictError(ctx, s, entity, err)
+		}
+		return false, err
+	}
+	if restored {
+		r.hooks.fire(ctx, &r.hooks.updated, entity)
+	} else {
+		r.hooks.fire(ctx, &r.hooks.created, entity)
+	}
+	return restored, nil
+}
+
+// restoreDeleted 按模型的唯一索引查找与 entity 重复的已删除行，找到时恢复并覆盖
+func (r *BaseRepository[T, ID]) restoreDeleted(ctx context.Context, tx *gorm.DB, s *schema.Schema, entity *T) (bool, error) {
+	deletedAt := softDeleteField(s)
+	if deletedAt == nil || len(s.PrimaryFields) != 1 {
+		return false, nil
+	}
+	pk := s.PrimaryFields[0]
+	for _, columns := range uniqueColumnSets(s) {
+		where, ok := conflictConditions(ctx, s, entity, columns)
+		if !ok {
+			continue
+		}
+		var existing T
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(where).Where(clause.Neq{Column: clause.Column{Name: deletedAt.DBName}, Value: nil}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: deletedAt.DBName}, Desc: true}).
+			Take(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		id, _ := pk.ValueOf(ctx, reflect.ValueOf(&existing).Elem())
+		if err := pk.Set(ctx, reflect.ValueOf(entity).Elem(), id); err != nil {
+			return false, err
+		}
+		omit := []string{pk.DBName}
+		for _, f := range s.Fields {
+			if f.AutoCreateTime > 0 && f.DBName != "" {
+				omit = append(omit, f.DBName)
+				continue
+			}
+			// 与 Create 一致，零值使用 default 标签的值
+			if _, zero := f.ValueOf(ctx, reflect.ValueOf(entity).Elem()); zero && f.DefaultValueInterface != nil {
+				if err := f.Set(ctx, reflect.ValueOf(entity).Elem(), f.DefaultValueInterface); err != nil {
+					return false, err
+				}
+			}
+		}
+		// DeletedAt 为零值，Select("*") 会一并把 deleted_at 置为 NULL
+		err = tx.Unscoped().Model(entity).Where(pkEq(id)).Select("*").Omit(omit...).Updates(entity).Error
+		if err != nil {
+			return false, fmt.Errorf("恢复已删除的记录失败: %w", err)
+		}
+		return true, tx.Take(entity, pkEq(id)).Error
+	}
+	return false, nil
+}
+
+// conflictError 查询占用该键的行，构造 ConflictError
+func (r *BaseRepository[T, ID]) conflictError(ctx context.Context, s *schema.Schema, entity *T, err error) error {
+	conflict := &ConflictError[T]{Err: err}
+	candidates := uniqueColumnSets(s)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName != "" {
+		conflict.Constraint = pgErr.ConstraintName
+		if columns := r.constraintColumns(ctx, pgErr.ConstraintName); len(columns) > 0 {
+			candidates = [][]string{columns}
+		}
+	}
+
+	db := r.session(ctx).Unscoped()
+	if f := softDeleteField(s); f != nil {
+		// 优先返回未删除的行
+		db = db.Order(clause.OrderBy{Expression: clause.Expr{SQL: "? IS NOT NULL", Vars: []any{clause.Column{Name: f.DBName}}}})
+	}
+	for _, columns := range candidates {
+		where, ok := conflictConditions(ctx, s, entity, columns)
+		if !ok {
+			continue
+		}
+		var existing T
+		res := db.Where(where).Limit(1).Find(&existing)
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		conflict.Columns, conflict.Existing = columns, &existing
+		conflict.Deleted = softDeleted(ctx, s, &existing)
+		break
+	}
+	if conflict.Columns == nil && len(candidates) > 0 {
+		conflict.Columns = candidates[0]
+	}
+	return conflict
+}
+
+// constraintColumns 唯一约束（索引）包含的列，表达式列被忽略
+func (r *BaseRepository[T, ID]) constraintColumns(ctx context.Context, name string) []string {
+	schemaName, _, err := splitTableName(r.db, new(T))
+	if err != nil {
+		return nil
+	}
+	var columns []string
+	r.session(ctx).Raw(`SELECT a.attname FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		WHERE c.relname = ? AND n.nspname = COALESCE(?, current_schema())
+		ORDER BY k.ord`, name, schemaName).Scan(&columns)
+	return columns
+}
+
+// uniqueColumnSets 模型上唯一索引的列，按索引名排序；含表达式的索引被跳过
+func uniqueColumnSets(s *schema.Schema) [][]string {
+	indexes := s.ParseIndexes()
+	names := make([]string, 0, len(indexes))
+	for name, idx := range indexes {
+		if idx.Class == "UNIQUE" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var sets [][]string
+	for _, name := range names {
+		var columns []string
+		for _, f := range indexes[name].Fields {
+			if f.Expression != "" || f.Field == nil {
+				columns = nil
+				break
+			}
+			columns = append(columns, f.DBName)
+		}
+		if len(columns) > 0 {
+			sets = append(sets, columns)
+		}
+	}
+	return sets
+}
+
+// conflictConditions entity 在 columns 上的等值条件；列不属于模型或取值为零值时返回 false
+func conflictConditions[T any](ctx context.Context, s *schema.Schema, entity *T, columns []string) (clause.AndConditions, bool) {
+	var where clause.AndConditions
+	for _, c := range columns {
+		f := s.LookUpField(c)
+		if f == nil {
+			return where, false
+		}
+		v, zero := f.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+		if zero {
+			return where, false
+		}
+		where.Exprs = append(where.Exprs, clause.Eq{Column: clause.Column{Name: f.DBName}, Value: v})
+	}
+	return where, true
+}
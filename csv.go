@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrTooManyImportErrors 导入时出错的行数超过 MaxErrors
+var ErrTooManyImportErrors = errors.New("导入出错行数超过上限，已中止")
+
+// csvTimeLayouts 导入时依次尝试的时间格式：RFC3339 与 COPY 导出的 PostgreSQL 默认格式
+var csvTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+}
+
+// CSVImportOptions CSV 导入选项
+type CSVImportOptions struct {
+	// Columns CSV 表头 -> 列名（或字段名），未映射的表头按列名/字段名匹配；映射为 "-" 的列被忽略
+	Columns map[string]string
+	// Copy 使用 COPY FROM 快速导入：整体成功或整体失败，不执行 gorm 钩子与 ID 生成，也不做逐行错误报告
+	Copy bool
+	// BatchSize 逐行导入时每批插入的行数，默认 500；一批失败时逐行重试以定位出错的行
+	BatchSize int
+	// MaxErrors 出错行数超过该值时中止导入，0 表示不限制
+	MaxErrors int
+}
+
+// ImportRowError 单行导入错误，Line 为 CSV 中的行号（从 1 开始，含表头）
+type ImportRowError struct {
+	Line int
+	Err  error
+}
+
+func (e ImportRowError) Error() string {
+	return fmt.Sprintf("第 %d 行: %v", e.Line, e.Err)
+}
+
+func (e ImportRowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Imported int64
+	Errors   []ImportRowError
+}
+
+// ExportCSV 将满足 spec 的实体导出为带表头的 CSV，列为模型的全部数据库列
+// 查询不含绑定参数且不在事务中时走 COPY TO STDOUT 快速路径，否则逐行读取后写出；整个导出占用一个 batch 类并发配额
+func (r *BaseRepository[T, ID]) ExportCSV(ctx context.Context, w io.Writer, spec Spec) error {
+	return r.run(ctx, "ExportCSV", OpRead, func(ctx context.Context) error { return r.exportCSV(ctx, w, spec) }, w, spec)
+}
+
+func (r *BaseRepository[T, ID]) exportCSV(ctx context.Context, w io.Writer, spec Spec) error {
+	s, err := r.modelSchema()
+	if err != nil {
+		return err
+	}
+	ctx, release, err := holdSlot(ctx, r.db, PriorityBatch)
+	if err != nil {
+		return err
+	}
+	defer release()
+	columns := s.DBNames
+
+	var entities []*T
+	dry := r.db.Session(&gorm.Session{DryRun: true}).WithContext(ctx)
+	stmt := newQueryOptions(spec).apply(dry.Model(new(T))).Select(columns).Find(&entities).Statement
+	if stmt.Error != nil {
+		return stmt.Error
+	}
+	// COPY 不支持绑定参数；事务中的 COPY 需在同一连接上执行，而快速路径会另取一条连接
+	if len(stmt.Vars) == 0 && !inTransaction(r.db) {
+		return withPgConn(ctx, r.db, func(conn *pgx.Conn) error {
+			sql := "COPY (" + stmt.SQL.String() + ") TO STDOUT WITH (FORMAT csv, HEADER true)"
+			if _, err := conn.PgConn().CopyTo(ctx, w, sql); err != nil {
+				return fmt.Errorf("表 %s 导出 CSV 失败: %w", s.Table, err)
+			}
+			return nil
+		})
+	}
+
+	rows, err := newQueryOptions(spec).apply(r.session(ctx).Model(new(T))).Select(columns).Rows()
+	if err != nil {
+		return fmt.Errorf("表 %s 导出 CSV 失败: %w", s.Table, err)
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = formatCSVValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportCSV 从带表头的 CSV 导入实体
+// 默认逐行解析并分批插入，解析或插入失败的行记录在 ImportResult.Errors 中，其余行照常导入；
+// opts.Copy 为 true 时走 COPY FROM STDIN 快速路径
+func (r *BaseRepository[T, ID]) ImportCSV(ctx context.Context, src io.Reader, opts CSVImportOptions) (*ImportResult, error) {
+	return invoke(ctx, r, "ImportCSV", OpWrite, func(ctx context.Context) (*ImportResult, error) { return r.importCSV(ctx, src, opts) }, src, opts)
+}
+
+func (r *BaseRepository[T, ID]) importCSV(ctx context.Context, src io.Reader, opts CSVImportOptions) (*ImportResult, error) {
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(src)
+	header, err := readCSVHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]*schema.Field, len(header))
+	for i, h := range header {
+		name := h
+		if mapped, ok := opts.Columns[h]; ok {
+			name = mapped
+		}
+		if name == "-" {
+			continue
+		}
+		f := s.LookUpField(name)
+		if f == nil || f.DBName == "" {
+			return nil, fmt.Errorf("CSV 列 %q 在表 %s 中不存在", h, s.Table)
+		}
+		fields[i] = f
+	}
+
+	if opts.Copy {
+		return r.copyCSV(ctx, br, s, fields)
+	}
+
+	imp := newBatchImporter[T](r.session(ctx), opts.BatchSize, opts.MaxErrors)
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = len(header)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return imp.result, err
+			}
+			// 表头已被单独读取，ParseError 中的行号少一行，这里只保留列号与原因
+			if err := imp.fail(parseErr.Line+1, fmt.Errorf("第 %d 列: %w", parseErr.Column, parseErr.Err)); err != nil {
+				return imp.result, err
+			}
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		line++ // 表头已被单独读取
+
+		entity, err := newEntityFromCSV[T](ctx, fields, record)
+		if err != nil {
+			err = imp.fail(line, err)
+		} else {
+			err = imp.add(line, entity)
+		}
+		if err != nil {
+			return imp.result, err
+		}
+	}
+	return imp.result, imp.flush()
+}
+
+// batchImporter 分批插入导入的实体，一批失败时逐行重试以定位出错的行，其余行照常导入
+// 使用 Transaction 包裹每次插入：外层已有事务时为 SAVEPOINT，失败的插入不会破坏外层事务
+type batchImporter[T any] struct {
+	db        *gorm.DB
+	batchSize int
+	maxErrors int
+	result    *ImportResult
+	batch     []*T
+	lines     []int
+}
+
+func newBatchImporter[T any](db *gorm.DB, batchSize, maxErrors int) *batchImporter[T] {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &batchImporter[T]{db: db, batchSize: batchSize, maxErrors: maxErrors, result: &ImportResult{}}
+}
+
+// add 加入一行，批次满时插入
+func (imp *batchImporter[T]) add(line int, entity *T) error {
+	imp.batch = append(imp.batch, entity)
+	imp.lines = append(imp.lines, line)
+	if len(imp.batch) >= imp.batchSize {
+		return imp.flush()
+	}
+	return nil
+}
+
+// fail 记录一行错误，超过 maxErrors 时返回 ErrTooManyImportErrors
+func (imp *batchImporter[T]) fail(line int, err error) error {
+	imp.result.Errors = append(imp.result.Errors, ImportRowError{Line: line, Err: err})
+	if imp.maxErrors > 0 && len(imp.result.Errors) > imp.maxErrors {
+		return ErrTooManyImportErrors
+	}
+	return nil
+}
+
+// flush 插入当前批次
+func (imp *batchImporter[T]) flush() error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+	defer func() { imp.batch, imp.lines = imp.batch[:0], imp.lines[:0] }()
+
+	err := imp.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(imp.batch).Error
+	})
+	if err == nil {
+		imp.result.Imported += int64(len(imp.batch))
+		return nil
+	}
+	for i, entity := range imp.batch {
+		err := imp.db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(entity).Error
+		})
+		if err != nil {
+			if err := imp.fail(imp.lines[i], err); err != nil {
+				return err
+			}
+			continue
+		}
+		imp.result.Imported++
+	}
+	return nil
+}
+
+// copyCSV 通过 COPY FROM STDIN 导入表头之后的全部数据
+func (r *BaseRepository[T, ID]) copyCSV(ctx context.Context, src io.Reader, s *schema.Schema, fields []*schema.Field) (*ImportResult, error) {
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		if f == nil {
+			return nil, errors.New("COPY 导入不支持忽略列")
+		}
+		columns[i] = pgx.Identifier{f.DBName}.Sanitize()
+	}
+	table, err := r.tableName()
+	if err != nil {
+		return nil, err
+	}
+
+	var imported int64
+	err = withPgConn(ctx, r.db, func(conn *pgx.Conn) error {
+		sql := fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv)", quoteQualified(table), strings.Join(columns, ", "))
+		tag, err := conn.PgConn().CopyFrom(ctx, src, sql)
+		if err != nil {
+			return fmt.Errorf("表 %s COPY 导入失败: %w", s.Table, err)
+		}
+		imported = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ImportResult{Imported: imported}, nil
+}
+
+// readCSVHeader 只读取表头这一行，剩余内容留在 br 中（COPY 快速路径需要原样转发）
+func readCSVHeader(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return nil, fmt.Errorf("读取 CSV 表头失败: %w", err)
+	}
+	header, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 表头失败: %w", err)
+	}
+	return header, nil
+}
+
+// newEntityFromCSV 按表头对应的字段把一行 CSV 填充为实体，空字符串视为 NULL（保留零值/数据库默认值）
+func newEntityFromCSV[T any](ctx context.Context, fields []*schema.Field, record []string) (*T, error) {
+	entity := new(T)
+	rv := reflect.ValueOf(entity).Elem()
+	for i, f := range fields {
+		if f == nil || record[i] == "" {
+			continue
+		}
+		value, err := parseCSVValue(f, record[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Set(ctx, rv, value); err != nil {
+			return nil, fmt.Errorf("列 %s: %w", f.DBName, err)
+		}
+	}
+	return entity, nil
+}
+
+// parseCSVValue 预解析 gorm 不能从字符串正确转换的类型（时间、布尔）
+func parseCSVValue(f *schema.Field, s string) (any, error) {
+	switch f.DataType {
+	case schema.Time:
+		for _, layout := range csvTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("列 %s: 无法解析时间 %q", f.DBName, s)
+	case schema.Bool:
+		b, err := strconv.ParseBool(s) // 兼容 COPY 导出的 t/f
+		if err != nil {
+			return nil, fmt.Errorf("列 %s: 无法解析布尔值 %q", f.DBName, s)
+		}
+		return b, nil
+	}
+	return s, nil
+}
+
+// formatCSVValue 格式化逐行导出的值，NULL 导出为空字符串
+func formatCSVValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 只含格式错误的行，不会真正插入，DryRun 会话即可
+func TestImportCSVParseError(t *testing.T) {
+	repo := NewBaseRepository[User, uint](dryRunDB(t))
+	src := strings.NewReader("name,email,age\n" +
+		"bo\"b,bob@example.com,25\n" +
+		"carol,carol@example.com\n")
+	res, err := repo.ImportCSV(context.Background(), src, CSVImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		line int
+		err  error
+	}{{2, csv.ErrBareQuote}, {3, csv.ErrFieldCount}}
+	if res.Imported != 0 || len(res.Errors) != len(want) {
+		t.Fatalf("导入 %d 行，出错行 %v", res.Imported, res.Errors)
+	}
+	for i, w := range want {
+		if got := res.Errors[i]; got.Line != w.line || !errors.Is(got.Err, w.err) {
+			t.Errorf("出错行为第 %d 行（%v），期望第 %d 行的 %v", got.Line, got.Err, w.line, w.err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// With 为查询添加公用表表达式（CTE），之后可在 Where 等条件中按名称引用；
+// query 可以是 SQL 字符串（配合 args）、*gorm.DB 子查询或 clause.Expression，name 可带列名，如 "org_tree(id, depth)"
+//
+//	repo.Find(ctx, With("adults", "SELECT id FROM users WHERE age >= ?", 18),
+//		Where("id IN (SELECT id FROM adults)"))
+func With(name string, query any, args ...any) QueryOption {
+	return withCTE(cte{name: name, query: query, args: args})
+}
+
+// WithRecursive 递归 CTE，query 为 "初始查询 UNION ALL 引用自身的递归查询"，用于组织架构、评论楼层等树形数据：
+//
+//	repo.Find(ctx, WithRecursive("org_tree(id)", `
+//		SELECT id FROM employees WHERE id = ?
+//		UNION ALL
+//		SELECT e.id FROM employees e JOIN org_tree t ON e.manager_id = t.id`, rootID),
+//		Where("id IN (SELECT id FROM org_tree)"))
+func WithRecursive(name string, query any, args ...any) QueryOption {
+	return withCTE(cte{name: name, query: query, args: args, recursive: true})
+}
+
+func withCTE(c cte) QueryOption {
+	return func(o *queryOptions) {
+		o.filters = append(o.filters, func(db *gorm.DB) *gorm.DB {
+			// 语句类型要到执行时才确定，三种语句的首个子句都挂上 WITH，只有实际生成的那个会输出
+			return db.Clauses(withClause{"SELECT", c}, withClause{"UPDATE", c}, withClause{"DELETE", c})
+		})
+	}
+}
+
+type cte struct {
+	name      string
+	query     any
+	args      []any
+	recursive bool
+}
+
+// ctes 一条语句中的全部 CTE，任一为递归时整体使用 WITH RECURSIVE
+type ctes []cte
+
+func (cs ctes) Build(builder clause.Builder) {
+	builder.WriteString("WITH ")
+	for _, c := range cs {
+		if c.recursive {
+			builder.WriteString("RECURSIVE ")
+			break
+		}
+	}
+	for i, c := range cs {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		name, columns, _ := strings.Cut(c.name, "(")
+		builder.WriteQuoted(clause.Table{Name: strings.TrimSpace(name)})
+		if columns != "" {
+			builder.WriteByte('(')
+			for j, col := range strings.Split(strings.TrimSuffix(strings.TrimSpace(columns), ")"), ",") {
+				if j > 0 {
+					builder.WriteString(", ")
+				}
+				builder.WriteQuoted(clause.Column{Name: strings.TrimSpace(col)})
+			}
+			builder.WriteByte(')')
+		}
+		builder.WriteString(" AS (")
+		switch q := c.query.(type) {
+		case string:
+			clause.Expr{SQL: q, Vars: c.args}.Build(builder)
+		case clause.Expression:
+			q.Build(builder)
+		default:
+			builder.AddVar(builder, q)
+		}
+		builder.WriteByte(')')
+	}
+}
+
+// withClause 以 BeforeExpression 的形式挂在 SELECT/UPDATE/DELETE 子句之前，不影响 gorm 的子句顺序
+type withClause struct {
+	stmt string
+	cte  cte
+}
+
+func (w withClause) Name() string { return w.stmt }
+
+func (w withClause) Build(clause.Builder) {}
+
+func (w withClause) MergeClause(c *clause.Clause) {
+	existing, _ := c.BeforeExpression.(ctes)
+	c.BeforeExpression = append(existing[:len(existing):len(existing)], w.cte)
+}
@@ -0,0 +1,143 @@
+// Package dbtest 数据库集成测试的辅助函数：测试库连接串、事务回滚隔离等，只应在 _test.go 中引用，
+// 避免 testing 包被编译进业务程序
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// DSNEnv 集成测试使用的数据库连接串环境变量，如
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres password=postgres dbname=app_test sslmode=disable search_path=postgresql_test"
+const DSNEnv = "TEST_DATABASE_DSN"
+
+// DSN 返回测试库连接串；未设置 TEST_DATABASE_DSN 时跳过测试
+func DSN(t testing.TB) string {
+	t.Helper()
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skipf("未设置 %s，跳过数据库集成测试", DSNEnv)
+	}
+	return dsn
+}
+
+// WithRollback 在 db 上开启事务执行 fn，测试结束时回滚，多个用例共享同一个测试库也互不影响，无需清表
+// fn 内的嵌套事务会变为 SAVEPOINT；建表等 DDL 同样会被回滚
+func WithRollback(t testing.TB, db *gorm.DB, fn func(tx *gorm.DB)) {
+	t.Helper()
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("开启测试事务失败: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		if err := tx.Rollback().Error; err != nil {
+			t.Errorf("回滚测试事务失败: %v", err)
+		}
+	})
+	fn(tx)
+}
+
+// SchemaOptions NewSchema 打开连接与建表的方式
+type SchemaOptions struct {
+	Open       func(dsn string) (*gorm.DB, error)           // 以 search_path 指向新 schema 的 dsn 打开连接
+	Migrate    func(ctx context.Context, db *gorm.DB) error // 在新 schema 中建表
+	Extensions []string                                     // 建 schema 前安装到 public 的扩展（如 citext），各 worker 共用
+}
+
+// NewSchema 在 dsn 指向的库中创建唯一命名的 schema（test_<进程号>_<随机数>），以 search_path 指向它打开新连接并执行 Migrate，
+// 返回的 drop 删除该 schema 并关闭连接。每个 go test 进程（-p N 时的每个 worker）各用一个 schema，可在同一个库上并行执行。
+//
+// search_path 为 "<schema>, public"，未指定 schema 的 CREATE EXTENSION 会把扩展装进 worker 的 schema，
+// drop 时随之删除，其他 worker 的列类型也就失效了；因此 Extensions 先以 SCHEMA public 安装，迁移中的同名扩展检查直接通过
+func NewSchema(ctx context.Context, dsn string, opts SchemaOptions) (db *gorm.DB, drop func() error, err error) {
+	name := fmt.Sprintf("test_%d_%08x", os.Getpid(), rand.Uint32())
+	db, err = opts.Open(WithSearchPath(dsn, name+",public"))
+	if err != nil {
+		return nil, nil, err
+	}
+	closeDB := func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	if err := installExtensions(ctx, db, opts.Extensions); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	quoted := pgx.Identifier{name}.Sanitize()
+	drop = func() error {
+		defer closeDB()
+		if err := db.Exec("DROP SCHEMA IF EXISTS " + quoted + " CASCADE").Error; err != nil {
+			return fmt.Errorf("删除测试 schema %s 失败: %w", name, err)
+		}
+		return nil
+	}
+	if err := db.WithContext(ctx).Exec("CREATE SCHEMA " + quoted).Error; err != nil {
+		closeDB()
+		return nil, nil, fmt.Errorf("创建测试 schema %s 失败: %w", name, err)
+	}
+	if opts.Migrate != nil {
+		if err := opts.Migrate(ctx, db); err != nil {
+			drop()
+			return nil, nil, err
+		}
+	}
+	return db, drop, nil
+}
+
+// installExtensions 在 public 中安装扩展；多个 worker 同时启动时以 advisory lock 串行，避免 CREATE EXTENSION 的唯一约束冲突
+func installExtensions(ctx context.Context, db *gorm.DB, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('dbtest:extensions'))").Error; err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := tx.Exec("CREATE EXTENSION IF NOT EXISTS " + pgx.Identifier{name}.Sanitize() + " SCHEMA public").Error; err != nil {
+				return fmt.Errorf("安装扩展 %s 失败: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Schema 为当前测试创建独立的 schema 并迁移，测试结束时删除；与 t.Parallel 一起使用时各用例互不影响，
+// 但每次都要执行迁移，用例较多时优先在 TestMain 中每个进程调用一次 NewSchema
+func Schema(t testing.TB, opts SchemaOptions) *gorm.DB {
+	t.Helper()
+	db, drop, err := NewSchema(context.Background(), DSN(t), opts)
+	if err != nil {
+		t.Fatalf("创建测试 schema 失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := drop(); err != nil {
+			t.Error(err)
+		}
+	})
+	return db
+}
+
+// WithSearchPath 在 URL 或 key=value 形式的 DSN 中设置 search_path，覆盖原有的值
+func WithSearchPath(dsn, searchPath string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", searchPath)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	// key=value 形式中后出现的同名参数生效，值以单引号包围并转义
+	return dsn + " search_path='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(searchPath) + "'"
+}
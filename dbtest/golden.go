@@ -0,0 +1,86 @@
+package dbtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// UpdateGoldenEnv 设置为 1 时 AssertGoldenSQL 用本次生成的 SQL 覆盖 golden 文件
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// goldenDir golden 文件目录，相对于被测包的目录
+const goldenDir = "testdata/golden"
+
+// sqlRecorder 收集 DryRun 会话生成的 SQL
+type sqlRecorder struct {
+	mu    sync.Mutex
+	stmts []string
+}
+
+func (r *sqlRecorder) record(db *gorm.DB) {
+	if sql := db.Statement.SQL.String(); sql != "" {
+		r.mu.Lock()
+		r.stmts = append(r.stmts, sql)
+		r.mu.Unlock()
+	}
+}
+
+// RecordSQL 在 db 上执行 fn，返回其生成的全部 SQL（保留 $n 占位符）。db 应为新建的 DryRun 会话（gorm.Config{DryRun: true}），
+// 每次调用各用一个：记录回调注册在 db 的回调链上。占位符不展开为实际参数，避免时间戳等易变参数导致 golden 文件不稳定
+func RecordSQL(t testing.TB, db *gorm.DB, fn func(db *gorm.DB)) []string {
+	t.Helper()
+	if !db.DryRun {
+		t.Fatal("RecordSQL 需要 DryRun 会话")
+	}
+
+	rec := &sqlRecorder{}
+	cb := db.Callback()
+	for _, p := range []interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}{
+		cb.Create().After("*"), cb.Query().After("*"), cb.Update().After("*"),
+		cb.Delete().After("*"), cb.Row().After("*"), cb.Raw().After("*"),
+	} {
+		if err := p.Register("dbtest:record_sql", rec.record); err != nil {
+			t.Fatalf("注册 SQL 记录回调失败: %v", err)
+		}
+	}
+
+	fn(db)
+	return rec.stmts
+}
+
+// AssertGoldenSQL 比较 fn 生成的 SQL 与 testdata/golden/<name>.sql，不一致时报告差异
+// 用于发现查询回归（如意外的 SELECT *、条件或排序丢失）；有意的修改用 UPDATE_GOLDEN=1 go test 更新 golden 文件
+//
+//	dbtest.AssertGoldenSQL(t, dryRunDB(t), "user_get_by_id", func(db *gorm.DB) {
+//		_, _ = NewUserRepository(db).GetByID(context.Background(), 1)
+//	})
+func AssertGoldenSQL(t testing.TB, db *gorm.DB, name string, fn func(db *gorm.DB)) {
+	t.Helper()
+	got := strings.Join(RecordSQL(t, db, fn), ";\n") + ";\n"
+	path := filepath.Join(goldenDir, name+".sql")
+
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败（首次运行请设置 %s=1 生成）: %v", UpdateGoldenEnv, err)
+	}
+	if got != string(want) {
+		t.Errorf("%s 生成的 SQL 与 golden 文件不一致\n--- 期望 (%s)\n%s--- 实际\n%s", name, path, want, got)
+	}
+}
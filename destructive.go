@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"gorm.io/gorm/clause"
+)
+
+// ErrDestructiveDisabled 未开启 SetAllowDestructive 且未传入 Unsafe 时，TruncateTable/DropTable 拒绝执行
+var ErrDestructiveDisabled = errors.New("当前环境禁止清空或删除表，仅在开发、测试环境开启 allow_destructive 或显式传入 Unsafe()")
+
+// allowDestructive 由配置档的 allow_destructive 设置，默认关闭，生产环境不应开启
+var allowDestructive atomic.Bool
+
+// SetAllowDestructive 允许 TruncateTable/DropTable 在不传 Unsafe 时执行，用于开发环境与集成测试
+func SetAllowDestructive(allow bool) {
+	allowDestructive.Store(allow)
+}
+
+// DestructiveOption TruncateTable/DropTable 的选项
+type DestructiveOption func(*destructiveOptions)
+
+type destructiveOptions struct {
+	unsafe  bool
+	cascade bool
+}
+
+// Unsafe 忽略 SetAllowDestructive 的设置强制执行，调用方需自行确认不是生产库
+func Unsafe() DestructiveOption {
+	return func(o *destructiveOptions) {
+		o.unsafe = true
+	}
+}
+
+// Cascade 同时清空（TRUNCATE ... CASCADE）或删除（DROP ... CASCADE）通过外键引用本表的对象
+func Cascade() DestructiveOption {
+	return func(o *destructiveOptions) {
+		o.cascade = true
+	}
+}
+
+func newDestructiveOptions(opts []DestructiveOption) (destructiveOptions, error) {
+	var o destructiveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.unsafe && !allowDestructive.Load() {
+		return o, ErrDestructiveDisabled
+	}
+	return o, nil
+}
+
+// TruncateTable 清空表并重置自增序列（含软删除的行），不触发仓库事件回调
+//
+//	SetAllowDestructive(true)
+//	err := repo.TruncateTable(ctx, Cascade())
+func (r *BaseRepository[T, ID]) TruncateTable(ctx context.Context, opts ...DestructiveOption) error {
+	return r.run(ctx, "TruncateTable", OpWrite, func(ctx context.Context) error {
+		o, err := newDestructiveOptions(opts)
+		if err != nil {
+			return err
+		}
+		table, err := r.tableName()
+		if err != nil {
+			return err
+		}
+		sql := "TRUNCATE TABLE ? RESTART IDENTITY"
+		if o.cascade {
+			sql += " CASCADE"
+		}
+		if err := r.session(ctx).Exec(sql, clause.Table{Name: table}).Error; err != nil {
+			return fmt.Errorf("清空表 %s 失败: %w", table, err)
+		}
+		log.Printf("表 %s 已清空", table)
+		return nil
+	})
+}
+
+// DropTable 删除表（数据不可恢复），表不存在时不报错；枚举类型等附属对象不会删除，整库重置应使用 DropAll
+func (r *BaseRepository[T, ID]) DropTable(ctx context.Context, opts ...DestructiveOption) error {
+	return r.run(ctx, "DropTable", OpWrite, func(ctx context.Context) error {
+		o, err := newDestructiveOptions(opts)
+		if err != nil {
+			return err
+		}
+		table, err := r.tableName()
+		if err != nil {
+			return err
+		}
+		sql := "DROP TABLE IF EXISTS ?"
+		if o.cascade {
+			sql += " CASCADE"
+		}
+		if err := r.session(ctx).Exec(sql, clause.Table{Name: table}).Error; err != nil {
+			return fmt.Errorf("删除表 %s 失败: %w", table, err)
+		}
+		log.Printf("表 %s 已删除", table)
+		return nil
+	})
+}
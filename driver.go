@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// PoolerMode 数据库前置连接池（如 PgBouncer）的池化模式
+type PoolerMode string
+
+const (
+	PoolerNone        PoolerMode = ""            // 直连数据库
+	PoolerSession     PoolerMode = "session"     // 会话池化，行为与直连一致
+	PoolerTransaction PoolerMode = "transaction" // 事务池化，每个事务可能落在不同的服务端连接上
+)
+
+// ErrUnsupportedWithPooler 事务池化模式下不支持依赖会话状态的功能（LISTEN、会话级 advisory lock 等）
+var ErrUnsupportedWithPooler = errors.New("事务池化模式下不支持该操作")
+
+var (
+	// pgxPool 原生连接池，仅在 UsePgxPool 模式下非空
+	pgxPool *pgxpool.Pool
+	// activePoolerMode 当前连接的池化模式
+	activePoolerMode PoolerMode
+)
+
+// newDialector 根据配置创建 gorm 方言，连接由 openConnPool 打开；UsePgxPool 时的 pgxpool 记入全局 pgxPool
+func newDialector(ctx context.Context, dsn string, cfg *PostgresConfig, tunnel *ssh.Client) (gorm.Dialector, error) {
+	activePoolerMode = cfg.PoolerMode
+	sqlDB, pool, err := openConnPool(ctx, dsn, cfg, tunnel)
+	if err != nil {
+		return nil, err
+	}
+	if pool != nil {
+		pgxPool = pool
+	}
+	return postgres.New(postgres.Config{Conn: sqlDB}), nil
+}
+
+// openConnPool 按配置打开连接，主库与副本共用：
+// 默认通过 pgx stdlib 以 database/sql 方式连接；UsePgxPool 时由 pgxpool 管理连接（一并返回，需由调用方关闭），
+// database/sql 只作为 gorm 所需的适配层
+//
+// 事务池化模式下服务端预编译语句无法跨事务复用，改用简单协议并禁用语句缓存；tunnel 非空时经该 SSH 隧道建立连接
+func openConnPool(ctx context.Context, dsn string, cfg *PostgresConfig, tunnel *ssh.Client) (*sql.DB, *pgxpool.Pool, error) {
+	simpleProtocol := cfg.PoolerMode == PoolerTransaction
+	settings := cfg.sessionSettings()
+	if len(settings) > 0 && simpleProtocol {
+		return nil, nil, fmt.Errorf("%w: 会话参数 %v 会泄漏给共用服务端连接的其他客户端，请改用 ALTER ROLE ... SET 配置",
+			ErrUnsupportedWithPooler, slices.Sorted(maps.Keys(settings)))
+	}
+
+	if !cfg.UsePgxPool {
+		if cfg.StatementCacheCapacity > 0 && !simpleProtocol {
+			dsn += fmt.Sprintf(" statement_cache_capacity=%d", cfg.StatementCacheCapacity)
+		}
+		connCfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("解析连接配置失败: %w", err)
+		}
+		cfg.applyConnConfig(connCfg, tunnel)
+		if simpleProtocol {
+			connCfg.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+		}
+		var opts []stdlib.OptionOpenDB
+		if len(settings) > 0 {
+			opts = append(opts, stdlib.OptionAfterConnect(applySessionSettings(settings)))
+		}
+		return stdlib.OpenDB(*connCfg, opts...), nil, nil
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 pgxpool 配置失败: %w", err)
+	}
+	if cfg.MaxOpenConns > 0 {
+		poolCfg.MaxConns = int32(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		poolCfg.MinConns = int32(min(cfg.MaxIdleConns, cfg.MaxOpenConns))
+	}
+	if cfg.MaxLifetime > 0 {
+		poolCfg.MaxConnLifetime = time.Duration(cfg.MaxLifetime) * time.Second
+	}
+	if simpleProtocol {
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	} else if cfg.StatementCacheCapacity > 0 {
+		poolCfg.ConnConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+	}
+	cfg.applyConnConfig(poolCfg.ConnConfig, tunnel)
+	if len(settings) > 0 {
+		poolCfg.AfterConnect = applySessionSettings(settings)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建 pgxpool 失败: %w", err)
+	}
+	return stdlib.OpenDBFromPool(pool), pool, nil
+}
+
+// configurePool 设置 database/sql 连接池参数：UsePgxPool 时连接由 pgxpool 管理，database/sql 不保留空闲连接，用完即归还 pgxpool；
+// 否则未配置（如仅传入 DSN）的项保留 database/sql 的默认值
+func (cfg *PostgresConfig) configurePool(sqlDB *sql.DB) {
+	if cfg.UsePgxPool {
+		sqlDB.SetMaxIdleConns(0)
+		return
+	}
+	if cfg.MaxIdleConns > 0 {
+		setMaxIdleConns(sqlDB, cfg.MaxIdleConns)
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(time.Duration(cfg.MaxLifetime) * time.Second)
+	}
+}
+
+// sessionSettings 需要在每条新连接上设置的会话参数，未配置的不设置（沿用服务端/角色的默认值）
+func (cfg *PostgresConfig) sessionSettings() map[string]string {
+	settings := map[string]string{}
+	durations := map[string]time.Duration{
+		"statement_timeout":                   cfg.StatementTimeout,
+		"lock_timeout":                        cfg.LockTimeout,
+		"idle_in_transaction_session_timeout": cfg.IdleInTransactionTimeout,
+	}
+	for name, d := range durations {
+		if d > 0 {
+			settings[name] = fmt.Sprintf("%dms", d.Milliseconds())
+		}
+	}
+	if cfg.WorkMem != "" {
+		settings["work_mem"] = cfg.WorkMem
+	}
+	return settings
+}
+
+// applyConnConfig application_name 作为启动参数发送，连接建立时即可在 pg_stat_activity 中看到，事务池化模式下同样可用；
+// tunnel 非空时经跳板机建立连接，主机名也交由跳板机解析
+func (cfg *PostgresConfig) applyConnConfig(c *pgx.ConnConfig, tunnel *ssh.Client) {
+	if cfg.ApplicationName != "" {
+		c.RuntimeParams["application_name"] = cfg.ApplicationName
+	}
+	if tunnel != nil {
+		c.DialFunc = tunnel.DialContext
+		c.LookupFunc = func(_ context.Context, host string) ([]string, error) { return []string{host}, nil }
+	}
+}
+
+// applySessionSettings 返回连接建立后的钩子，以一条 set_config 查询设置全部会话参数；
+// 参数值非法（如 work_mem 写错单位）时连接建立失败，启动时的 Ping 即可发现
+func applySessionSettings(settings map[string]string) func(context.Context, *pgx.Conn) error {
+	names := slices.Sorted(maps.Keys(settings))
+	exprs := make([]string, len(names))
+	args := make([]any, 0, 2*len(names))
+	for i, name := range names {
+		exprs[i] = fmt.Sprintf("set_config($%d, $%d, false)", 2*i+1, 2*i+2)
+		args = append(args, name, settings[name])
+	}
+	sql := "SELECT " + strings.Join(exprs, ", ")
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		if _, err := conn.Exec(ctx, sql, args...); err != nil {
+			return fmt.Errorf("设置会话参数失败: %w", err)
+		}
+		return nil
+	}
+}
+
+// PgxPoolStats 返回 pgxpool 连接池指标，未启用 UsePgxPool 时返回 nil
+func PgxPoolStats() *pgxpool.Stat {
+	if pgxPool == nil {
+		return nil
+	}
+	return pgxPool.Stat()
+}
+
+// withPgConn 从连接池独占一条连接，以原生 pgx 连接执行 fn（COPY、LISTEN 等 database/sql 不支持的功能）
+func withPgConn(ctx context.Context, db *gorm.DB, fn func(conn *pgx.Conn) error) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("需要 pgx 驱动，当前驱动连接类型: %T", driverConn)
+		}
+		return fn(c.Conn())
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"postgresql-test/dbtest"
+)
+
+// BenchmarkDriver 比较各连接方式下同一条查询的耗时：database/sql 适配 pgx（预编译语句缓存）、pgxpool 与简单协议（事务池化模式，不缓存语句）
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres password=postgres sslmode=disable" go test -run '^$' -bench BenchmarkDriver -count 6
+func BenchmarkDriver(b *testing.B) {
+	dsn := dbtest.DSN(b)
+	user := benchUsers(1)[0]
+	// 与 openBenchDriver 打开的连接使用同一个 search_path
+	db := benchDB(b)
+	if err := NewUserRepository(db).Create(context.Background(), user); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Unscoped().Delete(user) })
+
+	for _, c := range []struct {
+		name string
+		cfg  PostgresConfig
+	}{
+		{"stdlib", PostgresConfig{}},
+		{"stdlib/cache=16", PostgresConfig{StatementCacheCapacity: 16}},
+		{"pgxpool", PostgresConfig{UsePgxPool: true}},
+		{"simple_protocol", PostgresConfig{PoolerMode: PoolerTransaction}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			db := openBenchDriver(b, dsn, &c.cfg)
+			repo := NewUserRepository(db)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetByID(context.Background(), user.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// openBenchDriver 以 cfg 的驱动设置打开独立的连接，基准结束时关闭，不替换全局 DB
+func openBenchDriver(b *testing.B, dsn string, cfg *PostgresConfig) *gorm.DB {
+	b.Helper()
+	dialector, err := newDialector(context.Background(), dsn, cfg, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	db, err := OpenWithDialector(dialector,
+		WithGormConfig(&gorm.Config{SkipDefaultTransaction: true}),
+		WithLogger(logger.Default.LogMode(logger.Silent)),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		if cfg.UsePgxPool && pgxPool != nil {
+			pgxPool.Close()
+			pgxPool = nil
+		}
+		activePoolerMode = PoolerNone
+	})
+	return db
+}
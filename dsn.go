@@ -0,0 +1,157 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DatabaseURLEnv Heroku、Render 等平台提供连接地址的环境变量，配置文件中以 url: ${DATABASE_URL} 引用
+const DatabaseURLEnv = "DATABASE_URL"
+
+// ParseDSN 解析 postgres:// 或 postgresql:// 形式的连接地址（可包含以逗号分隔的多个节点），查询参数支持 sslmode、
+// application_name、target_session_attrs 以及 host/port/user/password/dbname（Unix socket 地址写作 postgres:///app?host=/var/run/postgresql）。
+// 不认识的参数返回错误，避免静默丢弃 target_session_attrs 之类影响连接行为的设置
+func ParseDSN(rawURL string) (*PostgresConfig, error) {
+	rawURL, hosts := splitURLHosts(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析连接地址失败: %w", redactURLError(err, rawURL))
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, fmt.Errorf("不支持的连接地址协议: %q", u.Scheme)
+	}
+
+	cfg := &PostgresConfig{Host: u.Hostname(), DBName: strings.TrimPrefix(u.Path, "/")}
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	if hosts != nil {
+		// 多节点地址 postgres://h1:5432,h2/app，每个节点保留自己的端口，未写端口的使用默认端口
+		cfg.Host, cfg.Hosts = "", hosts
+	} else if p := u.Port(); p != "" {
+		if cfg.Port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("连接地址中的端口无效: %s", p)
+		}
+	}
+	for key, values := range u.Query() {
+		v := values[len(values)-1]
+		switch key {
+		case "sslmode":
+			cfg.SSLMode = v
+		case "application_name":
+			cfg.ApplicationName = v
+		case "host":
+			if strings.Contains(v, ",") {
+				cfg.Host, cfg.Hosts = "", strings.Split(v, ",")
+			} else {
+				cfg.Host = v
+			}
+		case "target_session_attrs":
+			cfg.TargetSessionAttrs = v
+		case "user":
+			cfg.User = v
+		case "password":
+			cfg.Password = v
+		case "dbname":
+			cfg.DBName = v
+		case "port":
+			if cfg.Port, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("连接地址中的端口无效: %s", v)
+			}
+		default:
+			return nil, fmt.Errorf("连接地址中的参数 %s 不受支持", key)
+		}
+	}
+	return cfg, nil
+}
+
+// splitURLHosts 取出地址中以逗号分隔的多个节点，并以单个占位主机替换：
+// 节点的端口不一致（如 h1:5432,h2）时 url.Parse 会把第一个冒号之后的内容都当作端口而报错。单节点时 hosts 为 nil
+func splitURLHosts(rawURL string) (string, []string) {
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return rawURL, nil
+	}
+	end := len(rest)
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		end = i
+	}
+	authority := rest[:end]
+	userinfo, hostList := "", authority
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		userinfo, hostList = authority[:at+1], authority[at+1:]
+	}
+	if !strings.Contains(hostList, ",") {
+		return rawURL, nil
+	}
+	return scheme + "://" + userinfo + "multi-host" + rest[end:], strings.Split(hostList, ",")
+}
+
+// MergeDSN 以 rawURL 为基础，override 中的非零字段覆盖地址中的值，例如地址来自平台、连接池大小来自配置：
+//
+//	cfg, err := MergeDSN(os.Getenv(DatabaseURLEnv), &PostgresConfig{MaxOpenConns: 20, SSLMode: "require"})
+func MergeDSN(rawURL string, override *PostgresConfig) (*PostgresConfig, error) {
+	cfg, err := ParseDSN(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if override == nil {
+		return cfg, nil
+	}
+	dst, src := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(override).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if f := src.Field(i); !f.IsZero() && src.Type().Field(i).Name != "URL" {
+			dst.Field(i).Set(f)
+		}
+	}
+	return cfg, nil
+}
+
+// redactURLError url.Parse 的错误信息包含完整地址，去掉其中的密码
+func redactURLError(err error, rawURL string) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if at := strings.LastIndex(rest, "@"); ok && at >= 0 {
+		if user, _, hasPass := strings.Cut(rest[:at], ":"); hasPass {
+			urlErr.URL = scheme + "://" + user + ":xxxxx" + rest[at:]
+		}
+	}
+	return urlErr
+}
+
+// hostPorts DSN 中的 host 与 port，多节点时均以逗号分隔且一一对应
+func (cfg *PostgresConfig) hostPorts() (hosts, ports string) {
+	if len(cfg.Hosts) == 0 {
+		if cfg.Port > 0 {
+			ports = strconv.Itoa(cfg.Port)
+		}
+		return cfg.Host, ports
+	}
+	port := strconv.Itoa(cmp.Or(cfg.Port, 5432))
+	hs, ps := make([]string, len(cfg.Hosts)), make([]string, len(cfg.Hosts))
+	for i, h := range cfg.Hosts {
+		hs[i], ps[i] = h, port
+		if host, p, err := net.SplitHostPort(h); err == nil {
+			hs[i], ps[i] = host, p
+		}
+	}
+	return strings.Join(hs, ","), strings.Join(ps, ",")
+}
+
+// targetSessionAttrs 多节点时默认只连接可写的主库，否则按顺序连接到的可能是备库
+func (cfg *PostgresConfig) targetSessionAttrs() string {
+	if cfg.TargetSessionAttrs == "" && len(cfg.Hosts) > 1 {
+		return "read-write"
+	}
+	return cfg.TargetSessionAttrs
+}
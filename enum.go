@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnumType 字符串枚举类型约定：Go 中以 string 为底层类型，并声明对应的 PostgreSQL ENUM 类型名和合法取值
+//
+//	type UserStatus string
+//	func (UserStatus) EnumTypeName() string { return "user_status" }
+//	func (UserStatus) EnumValues() []string { return []string{"active", "disabled"} }
+type EnumType interface {
+	~string
+	EnumTypeName() string
+	EnumValues() []string
+}
+
+// ValidateEnum 校验枚举值是否合法
+func ValidateEnum[E EnumType](v E) error {
+	if !slices.Contains(v.EnumValues(), string(v)) {
+		return fmt.Errorf("无效的 %s 取值: %q", v.EnumTypeName(), string(v))
+	}
+	return nil
+}
+
+// EnumDriverValue 校验后返回数据库取值，供枚举类型的 Value 方法复用，保证写入时校验
+func EnumDriverValue[E EnumType](v E) (driver.Value, error) {
+	if err := ValidateEnum(v); err != nil {
+		return nil, err
+	}
+	return string(v), nil
+}
+
+// ScanEnum 从数据库读取枚举值，供枚举类型的 Scan 方法复用
+func ScanEnum[E EnumType](dst *E, src any) error {
+	switch v := src.(type) {
+	case string:
+		*dst = E(v)
+	case []byte:
+		*dst = E(v)
+	case nil:
+		*dst = ""
+	default:
+		return fmt.Errorf("不支持的枚举源数据: %T", src)
+	}
+	return nil
+}
+
+// EnsureEnum 根据 Go 枚举类型创建 PostgreSQL ENUM 类型；已存在时补充缺失的取值
+func EnsureEnum[E EnumType](db *gorm.DB) error {
+	var zero E
+	return CreateEnumType(db, zero.EnumTypeName(), zero.EnumValues()...)
+}
+
+// CreateEnumType 创建 ENUM 类型；已存在时通过 ALTER TYPE ... ADD VALUE 追加缺失的取值
+func CreateEnumType(db *gorm.DB, name string, values ...string) error {
+	labels, exists, err := EnumLabels(db, name)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = quoteLiteral(v)
+		}
+		sql := fmt.Sprintf("CREATE TYPE ? AS ENUM (%s)", strings.Join(quoted, ", "))
+		if err := db.Exec(sql, clause.Table{Name: name}).Error; err != nil {
+			return fmt.Errorf("创建枚举类型 %s 失败: %w", name, err)
+		}
+		log.Printf("枚举类型 %s 创建成功!", name)
+		return nil
+	}
+
+	for _, v := range values {
+		if slices.Contains(labels, v) {
+			continue
+		}
+		if err := AddEnumValue(db, name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddEnumValue 为 ENUM 类型追加取值（PostgreSQL 12 以下不能在事务中执行）
+func AddEnumValue(db *gorm.DB, name, value string) error {
+	sql := "ALTER TYPE ? ADD VALUE IF NOT EXISTS " + quoteLiteral(value)
+	if err := db.Exec(sql, clause.Table{Name: name}).Error; err != nil {
+		return fmt.Errorf("枚举类型 %s 添加取值 %q 失败: %w", name, value, err)
+	}
+	log.Printf("枚举类型 %s 添加取值 %q 成功!", name, value)
+	return nil
+}
+
+// RenameEnumValue 重命名 ENUM 类型的取值（PostgreSQL 10+）
+func RenameEnumValue(db *gorm.DB, name, oldValue, newValue string) error {
+	sql := fmt.Sprintf("ALTER TYPE ? RENAME VALUE %s TO %s", quoteLiteral(oldValue), quoteLiteral(newValue))
+	if err := db.Exec(sql, clause.Table{Name: name}).Error; err != nil {
+		return fmt.Errorf("枚举类型 %s 重命名取值 %q 失败: %w", name, oldValue, err)
+	}
+	return nil
+}
+
+// DropEnumType 删除 ENUM 类型（仍被列引用时会失败）
+func DropEnumType(db *gorm.DB, name string) error {
+	if err := db.Exec("DROP TYPE IF EXISTS ?", clause.Table{Name: name}).Error; err != nil {
+		return fmt.Errorf("删除枚举类型 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// EnumLabels 查询 ENUM 类型当前的取值（按定义顺序），类型不存在时 exists 为 false
+func EnumLabels(db *gorm.DB, name string) (labels []string, exists bool, err error) {
+	if err := db.Raw("SELECT to_regtype(?) IS NOT NULL", name).Scan(&exists).Error; err != nil {
+		return nil, false, fmt.Errorf("查询枚举类型 %s 失败: %w", name, err)
+	}
+	if !exists {
+		return nil, false, nil
+	}
+
+	err = db.Raw("SELECT enumlabel FROM pg_enum WHERE enumtypid = ?::regtype ORDER BY enumsortorder", name).
+		Scan(&labels).Error
+	if err != nil {
+		return nil, true, fmt.Errorf("查询枚举类型 %s 取值失败: %w", name, err)
+	}
+	return labels, true, nil
+}
+
+// quoteLiteral 将字符串转义为 SQL 字面量，用于不支持绑定参数的 DDL 语句
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// errExplainRollback 使 ExplainAnalyze 所在的事务回滚
+var errExplainRollback = errors.New("回滚 EXPLAIN ANALYZE 的事务")
+
+// Explain 返回 Find(ctx, spec...) 将执行的查询计划，用于检查索引使用情况
+func (r *BaseRepository[T, ID]) Explain(ctx context.Context, spec Spec) (string, error) {
+	return invoke(ctx, r, "Explain", OpRead, func(ctx context.Context) (string, error) {
+		return r.explain(ctx, spec, false)
+	}, spec)
+}
+
+// ExplainAnalyze 实际执行查询并返回带耗时与缓冲区统计的查询计划；只接受 SELECT，
+// 并在随后回滚的事务中执行，语句中调用的函数即使有写操作也不会提交
+func (r *BaseRepository[T, ID]) ExplainAnalyze(ctx context.Context, spec Spec) (string, error) {
+	return invoke(ctx, r, "ExplainAnalyze", OpRead, func(ctx context.Context) (string, error) {
+		return r.explain(ctx, spec, true)
+	}, spec)
+}
+
+func (r *BaseRepository[T, ID]) explain(ctx context.Context, spec Spec, analyze bool) (string, error) {
+	ctx, cancel := withDefaultTimeout(ctx, DefaultRawTimeout)
+	defer cancel()
+
+	// DryRun 只生成 SQL 不执行，保证与 Find 的语句完全一致
+	var entities []*T
+	dry := r.db.Session(&gorm.Session{DryRun: true}).WithContext(ctx)
+	stmt := newQueryOptions(spec).apply(dry).Find(&entities).Statement
+	if stmt.Error != nil {
+		return "", stmt.Error
+	}
+	query := stmt.SQL.String()
+	if !analyze {
+		return explainPlan(r.session(ctx), "EXPLAIN "+query, stmt.Vars)
+	}
+
+	if !isSelect(query) {
+		return "", fmt.Errorf("EXPLAIN ANALYZE 只支持 SELECT 语句: %s", query)
+	}
+	var plan string
+	err := Transaction(ctx, r.db, func(tx *gorm.DB) error {
+		var err error
+		if plan, err = explainPlan(tx, "EXPLAIN (ANALYZE, BUFFERS) "+query, stmt.Vars); err != nil {
+			return err
+		}
+		return errExplainRollback
+	})
+	if !errors.Is(err, errExplainRollback) {
+		return "", err
+	}
+	return plan, nil
+}
+
+// explainPlan 经 gorm 的回调执行 EXPLAIN 语句，返回逐行拼接的查询计划
+func explainPlan(db *gorm.DB, sql string, vars []any) (string, error) {
+	rows, err := db.Raw(sql, vars...).Rows()
+	if err != nil {
+		return "", fmt.Errorf("执行 EXPLAIN 失败: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// isSelect 跳过开头的空白与 /* */ 注释后，语句是否为 SELECT
+func isSelect(sql string) bool {
+	for {
+		sql = strings.TrimSpace(sql)
+		if !strings.HasPrefix(sql, "/*") {
+			break
+		}
+		_, rest, ok := strings.Cut(sql, "*/")
+		if !ok {
+			return false
+		}
+		sql = rest
+	}
+	return len(sql) >= 6 && strings.EqualFold(sql[:6], "SELECT")
+}
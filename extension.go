@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrExtensionUnavailable 服务器上没有安装该扩展的文件（需要安装对应的操作系统包，如 postgresql-contrib、postgis）
+	ErrExtensionUnavailable = errors.New("扩展在服务器上不可用")
+	// ErrExtensionPrivilege 当前角色无权创建扩展
+	ErrExtensionPrivilege = errors.New("当前角色无权创建扩展")
+	// ErrExtensionNotPreloaded 扩展需要通过 shared_preload_libraries 预加载
+	ErrExtensionNotPreloaded = errors.New("扩展未在 shared_preload_libraries 中预加载")
+)
+
+// preloadedExtensions 需要在 shared_preload_libraries 中预加载才能工作的扩展
+var preloadedExtensions = []string{"pg_stat_statements", "timescaledb", "pg_cron"}
+
+// EnsureExtensions 确保扩展已安装（已安装的跳过），在启动或迁移时调用，
+// 缺少扩展文件、权限不足或未预加载时返回带处理建议的错误，而不是在之后的查询中失败
+//
+//	err := EnsureExtensions(ctx, db, "pg_trgm", "pgcrypto", "uuid-ossp", "pg_stat_statements")
+func EnsureExtensions(ctx context.Context, db *gorm.DB, names ...string) error {
+	db = db.WithContext(ctx)
+	for _, name := range names {
+		if err := ensureExtension(db, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureExtension(db *gorm.DB, name string) error {
+	var ext struct {
+		Available bool
+		Installed bool
+	}
+	err := db.Raw(`SELECT count(*) > 0 AS available, bool_or(installed_version IS NOT NULL) IS TRUE AS installed
+		FROM pg_available_extensions WHERE name = ?`, name).Scan(&ext).Error
+	if err != nil {
+		return fmt.Errorf("查询扩展 %s 失败: %w", name, err)
+	}
+	if !ext.Available {
+		return fmt.Errorf("%w: %s，请在数据库服务器上安装该扩展", ErrExtensionUnavailable, name)
+	}
+
+	if slices.Contains(preloadedExtensions, name) {
+		var libs string
+		if err := db.Raw("SELECT current_setting('shared_preload_libraries')").Scan(&libs).Error; err != nil {
+			return fmt.Errorf("查询 shared_preload_libraries 失败: %w", err)
+		}
+		if !slices.Contains(strings.Split(strings.ReplaceAll(libs, " ", ""), ","), name) {
+			return fmt.Errorf("%w: %s，请在 postgresql.conf 中添加后重启数据库", ErrExtensionNotPreloaded, name)
+		}
+	}
+	if ext.Installed {
+		return nil
+	}
+
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS ?", clause.Table{Name: name}).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42501" {
+			return fmt.Errorf("%w: %s，请由 DBA 执行 CREATE EXTENSION %q，或授予当前角色数据库的 CREATE 权限（可信扩展）: %v",
+				ErrExtensionPrivilege, name, name, err)
+		}
+		return fmt.Errorf("创建扩展 %s 失败: %w", name, err)
+	}
+	log.Printf("已启用扩展 %s", name)
+	return nil
+}
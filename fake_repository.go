@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var _ UserRepository = (*FakeUserRepository)(nil)
+
+// ErrFakeUnsupported 内存实现无法执行 SQL 条件（QueryOption），需要时请使用真实数据库或 sqlmock
+var ErrFakeUnsupported = errors.New("内存仓库不支持查询选项")
+
+// FakeUserRepository 基于 map 的 UserRepository 内存实现，供依赖该接口的服务做单元测试，无需 PostgreSQL
+// 行为与数据库保持一致：软删除的用户查不到，也不再占用邮箱（唯一索引为 deleted_at IS NULL 的部分索引），
+// 查不到时返回 gorm.ErrRecordNotFound，邮箱重复时返回 gorm.ErrDuplicatedKey
+type FakeUserRepository struct {
+	mu          sync.RWMutex
+	users       map[uint]*User
+	nextID      uint
+	idempotency map[string]uint // 幂等键 -> 首次创建的用户ID
+}
+
+// NewFakeUserRepository 创建内存用户仓库，可传入初始数据
+func NewFakeUserRepository(users ...*User) *FakeUserRepository {
+	r := &FakeUserRepository{users: make(map[uint]*User), nextID: 1, idempotency: make(map[string]uint)}
+	for _, u := range users {
+		if err := r.Create(context.Background(), u); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// CreateTable 内存实现无需建表
+func (r *FakeUserRepository) CreateTable(user *User) error {
+	return nil
+}
+
+// Create 创建用户，ID 为 0 时自动分配
+func (r *FakeUserRepository) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.create(user)
+}
+
+// BatchCreate 批量创建用户，任一用户失败时整体不写入
+func (r *FakeUserRepository) BatchCreate(ctx context.Context, users []*User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot, nextID := r.clone(), r.nextID
+	for _, u := range users {
+		if err := r.create(u); err != nil {
+			r.users, r.nextID = snapshot, nextID
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateIdempotent 以幂等键创建用户，重复的键返回首次创建的用户；该用户已删除时返回 ErrIdempotentEntityGone
+func (r *FakeUserRepository) CreateIdempotent(ctx context.Context, key string, user *User) (*User, bool, error) {
+	if key == "" {
+		return nil, false, errors.New("幂等键不能为空")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.idempotency[key]; ok {
+		u, ok := r.users[id]
+		if !ok || u.DeletedAt.Valid {
+			return nil, false, ErrIdempotentEntityGone
+		}
+		return copyUser(u), false, nil
+	}
+	if err := r.create(user); err != nil {
+		return nil, false, err
+	}
+	r.idempotency[key] = user.ID
+	return user, true, nil
+}
+
+// GetByID 根据ID查询用户，opts 被忽略（User 没有关联需要预加载）
+func (r *FakeUserRepository) GetByID(ctx context.Context, id uint, opts ...QueryOption) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.users[id]
+	if !ok || u.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return copyUser(u), nil
+}
+
+// GetByEmail 根据邮箱查询用户
+func (r *FakeUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.users {
+		if u.Email.EqualFold(CIText(email)) && !u.DeletedAt.Valid {
+			return copyUser(u), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Update 保存用户全部字段，与 Save 一致：ID 为 0 或不存在时创建
+func (r *FakeUserRepository) Update(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.users[user.ID]
+	if user.ID == 0 || !ok {
+		return r.create(user)
+	}
+	if r.emailTaken(user.Email, user.ID) {
+		return gorm.ErrDuplicatedKey
+	}
+	if err := user.BeforeUpdate(nil); err != nil {
+		return err
+	}
+	user.CreatedAt = existing.CreatedAt
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// Delete 软删除用户
+func (r *FakeUserRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok && !u.DeletedAt.Valid {
+		u.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+// ListAll 查询所有未删除的用户，按ID排序
+func (r *FakeUserRepository) ListAll(ctx context.Context) ([]*User, error) {
+	return r.filter(func(*User) bool { return true }), nil
+}
+
+// Find 仅支持不带查询选项的调用
+func (r *FakeUserRepository) Find(ctx context.Context, opts ...QueryOption) ([]*User, error) {
+	if len(opts) > 0 {
+		return nil, ErrFakeUnsupported
+	}
+	return r.ListAll(ctx)
+}
+
+// List 分页查询，仅支持不带查询选项的调用，分页参数按 DefaultPageLimits 修正
+func (r *FakeUserRepository) List(ctx context.Context, offset, limit int, opts ...QueryOption) (*Page[User], error) {
+	if len(opts) > 0 {
+		return nil, ErrFakeUnsupported
+	}
+	offset, limit, err := DefaultPageLimits.normalize(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	users, _ := r.ListAll(ctx)
+	total := int64(len(users))
+	start, end := min(offset, len(users)), min(offset+limit, len(users))
+	return newOffsetPage(users[start:end], total, offset, limit), nil
+}
+
+// ListByCursor 按ID游标分页，仅支持不带查询选项的调用，游标格式与数据库实现一致
+func (r *FakeUserRepository) ListByCursor(ctx context.Context, cursor string, limit int, opts ...QueryOption) (*Page[User], error) {
+	if len(opts) > 0 {
+		return nil, ErrFakeUnsupported
+	}
+	_, limit, err := DefaultPageLimits.normalize(0, limit)
+	if err != nil {
+		return nil, err
+	}
+	var after uint
+	if cursor != "" {
+		id, err := decodePageCursor[uint](cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = id
+	}
+	users, _ := r.ListAll(ctx)
+	page := &Page[User]{Total: int64(len(users)), Cursor: cursor, Limit: limit}
+	page.PageCount = int((page.Total + int64(limit) - 1) / int64(limit))
+	users = slices.DeleteFunc(users, func(u *User) bool { return u.ID <= after })
+	if len(users) > limit {
+		users, page.HasNext = users[:limit], true
+		page.NextCursor = encodePageCursor(users[limit-1].ID)
+	}
+	page.Items = users
+	return page, nil
+}
+
+// Count 未删除的用户总数
+func (r *FakeUserRepository) Count(ctx context.Context) (int64, error) {
+	users, _ := r.ListAll(ctx)
+	return int64(len(users)), nil
+}
+
+// GetUserByAge 查询年龄大于 minAge 的用户
+func (r *FakeUserRepository) GetUserByAge(ctx context.Context, minAge int) ([]*User, error) {
+	return r.filter(func(u *User) bool { return u.Age > minAge }), nil
+}
+
+// GetUsersByAgeRange 查询年龄在 [minAge, maxAge] 内的用户
+func (r *FakeUserRepository) GetUsersByAgeRange(ctx context.Context, minAge, maxAge int) ([]*User, error) {
+	return r.filter(func(u *User) bool { return u.Age >= minAge && u.Age <= maxAge }), nil
+}
+
+// GetUsersCreatedBetween 查询创建时间在 [from, to) 内的用户，按创建时间排序
+func (r *FakeUserRepository) GetUsersCreatedBetween(ctx context.Context, from, to time.Time) ([]*User, error) {
+	users := r.filter(func(u *User) bool { return !u.CreatedAt.Before(from) && u.CreatedAt.Before(to) })
+	slices.SortStableFunc(users, func(a, b *User) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return users, nil
+}
+
+// Stats 在内存中按与数据库相同的规则汇总用户统计
+func (r *FakeUserRepository) Stats(ctx context.Context) (*UserStats, error) {
+	users, _ := r.ListAll(ctx)
+	stats := &UserStats{Total: int64(len(users))}
+	if len(users) == 0 {
+		return stats, nil
+	}
+
+	ages := make([]int, len(users))
+	byStatus := map[UserStatus]int64{}
+	byAge := map[int]int64{}
+	byDay := map[time.Time]int64{}
+	since := PartitionDaily.partitionStart(time.Now()).AddDate(0, 0, 1-signupDays)
+	for i, u := range users {
+		ages[i] = u.Age
+		stats.AvgAge += float64(u.Age) / float64(len(users))
+		byStatus[u.Status]++
+		byAge[u.Age/ageBucketWidth*ageBucketWidth]++
+		if !u.CreatedAt.Before(since) {
+			byDay[PartitionDaily.partitionStart(u.CreatedAt)]++
+		}
+	}
+	slices.Sort(ages)
+	if n := len(ages); n%2 == 1 {
+		stats.MedianAge = float64(ages[n/2])
+	} else {
+		stats.MedianAge = float64(ages[n/2-1]+ages[n/2]) / 2
+	}
+
+	for _, status := range slices.Sorted(maps.Keys(byStatus)) {
+		stats.ByStatus = append(stats.ByStatus, StatusCount{Status: status, Count: byStatus[status]})
+	}
+	for _, from := range slices.Sorted(maps.Keys(byAge)) {
+		stats.AgeBuckets = append(stats.AgeBuckets, AgeBucket{From: from, To: from + ageBucketWidth - 1, Count: byAge[from]})
+	}
+	for _, day := range slices.SortedFunc(maps.Keys(byDay), time.Time.Compare) {
+		stats.SignupsPerDay = append(stats.SignupsPerDay, Bucket{Start: day, Count: byDay[day]})
+	}
+	return stats, nil
+}
+
+// create 写入新用户，调用方需持有写锁
+func (r *FakeUserRepository) create(user *User) error {
+	if user.ID != 0 {
+		if _, ok := r.users[user.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	if r.emailTaken(user.Email, 0) {
+		return gorm.ErrDuplicatedKey
+	}
+	if err := user.BeforeCreate(nil); err != nil {
+		return err
+	}
+	if user.Status == "" {
+		user.Status = UserStatusActive
+	}
+	if user.ID == 0 {
+		user.ID = r.nextID
+	}
+	r.nextID = max(r.nextID, user.ID+1)
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// emailTaken 邮箱是否已被其他未删除的用户占用，与 citext 部分唯一索引一样忽略大小写
+func (r *FakeUserRepository) emailTaken(email CIText, exceptID uint) bool {
+	for id, u := range r.users {
+		if id != exceptID && !u.DeletedAt.Valid && u.Email.EqualFold(email) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter 返回满足条件的未删除用户副本，按ID排序
+func (r *FakeUserRepository) filter(match func(*User) bool) []*User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var users []*User
+	for _, u := range r.users {
+		if !u.DeletedAt.Valid && match(u) {
+			users = append(users, copyUser(u))
+		}
+	}
+	slices.SortFunc(users, func(a, b *User) int { return int(a.ID) - int(b.ID) })
+	return users
+}
+
+func (r *FakeUserRepository) clone() map[uint]*User {
+	users := make(map[uint]*User, len(r.users))
+	for id, u := range r.users {
+		users[id] = copyUser(u)
+	}
+	return users
+}
+
+func copyUser(u *User) *User {
+	c := *u
+	return &c
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// FixtureRefPrefix 夹具中以此开头的字符串值引用其他夹具：@users.alice 取主键，@users.alice.email 取指定列
+const FixtureRefPrefix = "@"
+
+// Fixtures LoadFixtures 插入的实体，表名（不含 schema）-> 标签 -> 实体指针（如 *User）
+type Fixtures map[string]map[string]any
+
+// Get 返回表 table 中标签为 label 的实体，不存在时返回 nil
+func (f Fixtures) Get(table, label string) any {
+	return f[table][label]
+}
+
+// Fixture 按类型取出夹具实体，不存在或类型不符时返回 nil
+//
+//	alice := Fixture[User](fixtures, "users", "alice")
+func Fixture[T any](f Fixtures, table, label string) *T {
+	v, _ := f.Get(table, label).(*T)
+	return v
+}
+
+type fixtureRow struct {
+	label  string
+	fields map[string]yaml.Node
+}
+
+// LoadFixtures 读取 dir 下的 <表名>.yaml/.yml/.json 夹具文件，按注册模型的依赖顺序在一个事务中插入，
+// 同一文件内按书写顺序插入。文件内容为 标签 -> 字段（列名或 Go 字段名）-> 值：
+//
+//	# fixtures/users.yaml
+//	alice:
+//	  name: Alice
+//	  email: alice@example.com
+//	  age: 30
+//
+// 其他夹具可以 manager_id: "@users.alice" 引用 alice 插入后的主键，被引用的表须先于引用方插入
+// （belongs-to 关系会自动推断，其他情况在 RegisterModel 的 DependsOn 中声明）
+func LoadFixtures(ctx context.Context, db *gorm.DB, dir string) (Fixtures, error) {
+	files, err := readFixtureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := orderedModels(db)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := make(Fixtures, len(files))
+	schemas := make(map[string]*schema.Schema, len(files))
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range ordered {
+			stmt := &gorm.Statement{DB: tx}
+			if err := stmt.Parse(m.model); err != nil {
+				return fmt.Errorf("解析模型 %T 失败: %w", m.model, err)
+			}
+			// 夹具文件名不含 schema，postgresql_test.users 对应 users.yaml
+			table := stmt.Table
+			if _, t, ok := strings.Cut(table, "."); ok {
+				table = t
+			}
+			rows, ok := files[table]
+			if !ok {
+				continue
+			}
+			delete(files, table)
+			fixtures[table], schemas[table] = make(map[string]any, len(rows)), stmt.Schema
+			for _, row := range rows {
+				entity, err := buildFixture(ctx, stmt.Schema, row, fixtures, schemas)
+				if err != nil {
+					return fmt.Errorf("夹具 %s.%s: %w", table, row.label, err)
+				}
+				if err := tx.Create(entity).Error; err != nil {
+					return fmt.Errorf("插入夹具 %s.%s 失败: %w", table, row.label, err)
+				}
+				fixtures[table][row.label] = entity
+			}
+		}
+		for table := range files {
+			return fmt.Errorf("夹具文件 %s 对应的模型未注册", table)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// readFixtureFiles 读取夹具目录，返回 表名 -> 按书写顺序排列的夹具行
+func readFixtureFiles(dir string) (map[string][]fixtureRow, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取夹具目录失败: %w", err)
+	}
+	files := make(map[string][]fixtureRow)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+		table := strings.TrimSuffix(e.Name(), ext)
+		if _, ok := files[table]; ok {
+			return nil, fmt.Errorf("表 %s 有多个夹具文件", table)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取夹具文件失败: %w", err)
+		}
+		// JSON 是 YAML 的子集，统一按 YAML 解析以保留标签的书写顺序
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析夹具文件 %s 失败: %w", e.Name(), err)
+		}
+		rows, err := fixtureRows(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("夹具文件 %s 格式错误: %w", e.Name(), err)
+		}
+		files[table] = rows
+	}
+	return files, nil
+}
+
+func fixtureRows(doc *yaml.Node) ([]fixtureRow, error) {
+	if doc.Kind == 0 {
+		return nil, nil
+	}
+	root := doc
+	if root.Kind == yaml.DocumentNode {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("顶层应为 标签 -> 字段 的映射")
+	}
+	rows := make([]fixtureRow, 0, len(root.Content)/2)
+	for i := 0; i < len(root.Content); i += 2 {
+		label, body := root.Content[i].Value, root.Content[i+1]
+		if body.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("夹具 %s 应为 字段 -> 值 的映射", label)
+		}
+		fields := make(map[string]yaml.Node, len(body.Content)/2)
+		for j := 0; j < len(body.Content); j += 2 {
+			fields[body.Content[j].Value] = *body.Content[j+1]
+		}
+		rows = append(rows, fixtureRow{label: label, fields: fields})
+	}
+	return rows, nil
+}
+
+// buildFixture 创建模型实例并填充字段，引用在此时解析为已插入夹具的值
+func buildFixture(ctx context.Context, s *schema.Schema, row fixtureRow, fixtures Fixtures, schemas map[string]*schema.Schema) (any, error) {
+	rv := reflect.New(s.ModelType)
+	for name, node := range row.fields {
+		f := s.LookUpField(name)
+		if f == nil {
+			return nil, fmt.Errorf("表 %s 没有字段 %s", s.Table, name)
+		}
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("解析字段 %s 失败: %w", name, err)
+		}
+		if ref, ok := value.(string); ok && strings.HasPrefix(ref, FixtureRefPrefix) {
+			resolved, err := resolveFixtureRef(ctx, ref, fixtures, schemas)
+			if err != nil {
+				return nil, err
+			}
+			value = resolved
+		}
+		if err := f.Set(ctx, rv.Elem(), value); err != nil {
+			return nil, fmt.Errorf("设置字段 %s 失败: %w", name, err)
+		}
+	}
+	return rv.Interface(), nil
+}
+
+// resolveFixtureRef 解析 @表名.标签[.列名]
+func resolveFixtureRef(ctx context.Context, ref string, fixtures Fixtures, schemas map[string]*schema.Schema) (any, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, FixtureRefPrefix), ".")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("引用 %s 格式错误，应为 @表名.标签 或 @表名.标签.列名", ref)
+	}
+	entity := fixtures.Get(parts[0], parts[1])
+	if entity == nil {
+		return nil, fmt.Errorf("引用的夹具 %s 不存在或尚未插入", ref)
+	}
+	s := schemas[parts[0]]
+	f := s.PrioritizedPrimaryField
+	if len(parts) == 3 {
+		f = s.LookUpField(parts[2])
+	}
+	if f == nil {
+		return nil, fmt.Errorf("引用 %s 的目标字段不存在", ref)
+	}
+	v, _ := f.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	return v, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 生成列：带 generated 标签的字段对应 GENERATED ALWAYS AS (<expr>) STORED 列，由数据库计算，
+// 写操作自动跳过这些字段，需要最新值时使用 CreateReturning/UpdateReturning 回填
+//
+//	type Account struct {
+//		ID         uint
+//		Email      string
+//		EmailLower string `gorm:"size:100;uniqueIndex" generated:"lower(email)"`
+//	}
+
+// generatedFields 模型中带 generated 标签的字段
+func generatedFields(s *schema.Schema) []*schema.Field {
+	var fields []*schema.Field
+	for _, f := range s.Fields {
+		if f.DBName != "" && f.Tag.Get("generated") != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// RegisterGeneratedColumnCallback 注册创建/更新前回调，把生成列加入 Omit，数据库不允许写入生成列
+func RegisterGeneratedColumnCallback(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("app:omit_generated", omitGenerated); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("app:omit_generated", omitGenerated)
+}
+
+func omitGenerated(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	for _, f := range generatedFields(db.Statement.Schema) {
+		if !slices.Contains(db.Statement.Omits, f.DBName) {
+			db.Statement.Omits = append(db.Statement.Omits, f.DBName)
+		}
+	}
+}
+
+// MigrateGeneratedColumns 在 AutoMigrate 之后调用，确保生成列存在且为生成列：
+// 缺失的列直接添加；AutoMigrate 已按普通列创建的，删除后重新添加（值可重新计算，不丢数据），并补建其上的索引
+// 已是生成列的不做修改，修改表达式需要手工迁移
+func MigrateGeneratedColumns(ctx context.Context, db *gorm.DB, models ...any) error {
+	db = db.WithContext(ctx)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("解析模型 %T 失败: %w", model, err)
+		}
+		fields := generatedFields(stmt.Schema)
+		if len(fields) > 0 {
+			if err := requireFeature(db, FeatureGeneratedColumns); err != nil {
+				return err
+			}
+		}
+		for _, f := range fields {
+			if err := migrateGeneratedColumn(db, model, stmt, f); err != nil {
+				return fmt.Errorf("迁移生成列 %s.%s 失败: %w", stmt.Table, f.DBName, err)
+			}
+		}
+	}
+	return nil
+}
+
+func migrateGeneratedColumn(db *gorm.DB, model any, stmt *gorm.Statement, f *schema.Field) error {
+	schemaName, table, err := splitTableName(db, model)
+	if err != nil {
+		return err
+	}
+	var isGenerated string
+	err = db.Raw(`SELECT is_generated FROM information_schema.columns
+		WHERE table_schema = COALESCE(?, current_schema()) AND table_name = ? AND column_name = ?`,
+		schemaName, table, f.DBName).Scan(&isGenerated).Error
+	if err != nil {
+		return err
+	}
+	if isGenerated == "ALWAYS" {
+		return nil
+	}
+
+	add := fmt.Sprintf("ALTER TABLE ? ADD COLUMN ? %s GENERATED ALWAYS AS (%s) STORED",
+		db.Dialector.DataTypeOf(f), f.Tag.Get("generated"))
+	return db.Transaction(func(tx *gorm.DB) error {
+		if isGenerated == "NEVER" {
+			if err := tx.Migrator().DropColumn(model, f.DBName); err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec(add, clause.Table{Name: stmt.Table}, clause.Column{Name: f.DBName}).Error; err != nil {
+			return err
+		}
+		// 删除列时其上的索引一并被删除
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			covers := slices.ContainsFunc(idx.Fields, func(o schema.IndexOption) bool { return o.Field == f })
+			if covers && !tx.Migrator().HasIndex(model, idx.Name) {
+				if err := tx.Migrator().CreateIndex(model, idx.Name); err != nil {
+					return err
+				}
+			}
+		}
+		log.Printf("生成列 %s.%s 已就绪", stmt.Table, f.DBName)
+		return nil
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SRIDWGS84 WGS 84 经纬度坐标系
+const SRIDWGS84 = 4326
+
+// GeoPoint 地理坐标点，对应 PostGIS geography(Point,4326)：距离以米计算，适合经纬度数据
+// 需要空间索引时在标签中声明 GiST 索引，KNN 排序与 ST_DWithin 都会使用该索引：
+//
+//	type Store struct {
+//		ID       uint
+//		Location GeoPoint `gorm:"not null;index:,type:gist"`
+//	}
+type GeoPoint struct {
+	Lng float64
+	Lat float64
+}
+
+func (GeoPoint) GormDataType() string { return "geography(Point,4326)" }
+
+// Value 以 EWKT 写入，如 SRID=4326;POINT(116.39 39.91)
+func (p GeoPoint) Value() (driver.Value, error) {
+	return Point{X: p.Lng, Y: p.Lat, SRID: SRIDWGS84}.ewkt(), nil
+}
+
+func (p *GeoPoint) Scan(src any) error {
+	var pt Point
+	if err := pt.Scan(src); err != nil {
+		return err
+	}
+	*p = GeoPoint{Lng: pt.X, Lat: pt.Y}
+	return nil
+}
+
+func (p GeoPoint) String() string { return fmt.Sprintf("POINT(%g %g)", p.Lng, p.Lat) }
+
+// Point 平面坐标点，对应 PostGIS geometry(Point)：距离以坐标系单位计算，适合投影坐标或不需要球面距离的场景
+// SRID 为 0 表示未指定坐标系
+type Point struct {
+	X    float64
+	Y    float64
+	SRID int
+}
+
+func (Point) GormDataType() string { return "geometry(Point)" }
+
+func (p Point) Value() (driver.Value, error) { return p.ewkt(), nil }
+
+func (p Point) ewkt() string {
+	wkt := "POINT(" + strconv.FormatFloat(p.X, 'f', -1, 64) + " " + strconv.FormatFloat(p.Y, 'f', -1, 64) + ")"
+	if p.SRID != 0 {
+		return "SRID=" + strconv.Itoa(p.SRID) + ";" + wkt
+	}
+	return wkt
+}
+
+// Scan 解析 PostGIS 以十六进制 EWKB 文本返回的点
+func (p *Point) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*p = Point{}
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("无法将 %T 扫描为 Point", src)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("解析 EWKB 失败: %w", err)
+	}
+	pt, err := parseEWKBPoint(b)
+	if err != nil {
+		return err
+	}
+	*p = pt
+	return nil
+}
+
+const (
+	ewkbSRIDFlag = 0x20000000
+	wkbPoint     = 1
+)
+
+// parseEWKBPoint 解析 EWKB 格式的点：字节序(1) + 类型(4) + [SRID(4)] + X(8) + Y(8) [+ Z/M]
+func parseEWKBPoint(b []byte) (Point, error) {
+	if len(b) < 5 {
+		return Point{}, errors.New("EWKB 数据过短")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if b[0] == 0 {
+		order = binary.BigEndian
+	}
+	typ := order.Uint32(b[1:5])
+	b = b[5:]
+	if typ&0x0fffffff != wkbPoint {
+		return Point{}, fmt.Errorf("不支持的几何类型 %d，仅支持 POINT", typ&0x0fffffff)
+	}
+	var pt Point
+	if typ&ewkbSRIDFlag != 0 {
+		if len(b) < 4 {
+			return Point{}, errors.New("EWKB 数据过短")
+		}
+		pt.SRID = int(order.Uint32(b[:4]))
+		b = b[4:]
+	}
+	if len(b) < 16 {
+		return Point{}, errors.New("EWKB 数据过短")
+	}
+	pt.X = math.Float64frombits(order.Uint64(b[:8]))
+	pt.Y = math.Float64frombits(order.Uint64(b[8:16]))
+	return pt, nil
+}
+
+// EnsurePostGIS 确保已安装 postgis 扩展，需在迁移含空间列的表之前调用
+func EnsurePostGIS(ctx context.Context, db *gorm.DB) error {
+	return EnsureExtensions(ctx, db, "postgis")
+}
+
+// STDWithin 距离条件：column 与 p 的距离不超过 meters 米，可使用 GiST 索引
+//
+//	stores, err := repo.Find(ctx, Where(STDWithin("location", here, 500)))
+func STDWithin(column string, p GeoPoint, meters float64) clause.Expr {
+	return clause.Expr{SQL: "ST_DWithin(?, ?::geography, ?)", Vars: []any{clause.Column{Name: column}, p, meters}}
+}
+
+// STDistance 距离表达式（米），可用于 Select 投影
+func STDistance(column string, p GeoPoint) clause.Expr {
+	return clause.Expr{SQL: "ST_Distance(?, ?::geography)", Vars: []any{clause.Column{Name: column}, p}}
+}
+
+// OrderByDistance 按与 p 的距离由近到远排序，使用 <-> 运算符，有 GiST 索引时为 KNN 索引扫描
+func OrderByDistance(column string, p GeoPoint) QueryOption {
+	return OrderBy(clause.OrderBy{Expression: clause.Expr{
+		SQL: "? <-> ?::geography", Vars: []any{clause.Column{Name: column}, p}, WithoutParentheses: true,
+	}})
+}
+
+// Nearest 查询距离 p 最近的 k 个实体（KNN），opts 可附加过滤条件，如 STDWithin 限定搜索半径
+func Nearest[T any, ID comparable](ctx context.Context, r *BaseRepository[T, ID], column string, p GeoPoint, k int, opts ...QueryOption) ([]*T, error) {
+	return invoke(ctx, r, "Nearest", OpRead, func(ctx context.Context) ([]*T, error) {
+		var entities []*T
+		opts := append(opts, OrderByDistance(column, p))
+		err := newQueryOptions(opts).apply(r.session(ctx)).Limit(k).Find(&entities).Error
+		return entities, err
+	}, column, p, k, opts)
+}
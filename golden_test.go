@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"postgresql-test/dbtest"
+)
+
+// dryRunDB 不连接数据库的 DryRun 会话，注册与 NewPostgresDB 相同的回调，只生成 SQL
+func dryRunDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := OpenWithDialector(
+		postgres.New(postgres.Config{DSN: "host=localhost"}),
+		WithGormConfig(&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true}),
+	)
+	if err != nil {
+		t.Fatalf("创建 DryRun 会话失败: %v", err)
+	}
+	return db
+}
+
+func TestGoldenSQL(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		fn   func(db *gorm.DB)
+	}{
+		{"user_get_by_id", func(db *gorm.DB) {
+			_, _ = NewUserRepository(db).GetByID(ctx, 1)
+		}},
+		{"user_get_by_email", func(db *gorm.DB) {
+			_, _ = NewUserRepository(db).GetByEmail(ctx, "alice@example.com")
+		}},
+		{"user_create", func(db *gorm.DB) {
+			_ = NewUserRepository(db).Create(ctx, &User{Name: "alice", Email: "alice@example.com", Age: 30})
+		}},
+		{"user_delete", func(db *gorm.DB) {
+			_ = NewUserRepository(db).Delete(ctx, 1)
+		}},
+		{"user_find_batch", func(db *gorm.DB) {
+			_, _ = NewUserRepository(db).Find(ctx, Where("id > ?", 100), OrderBy("id"), Limit(500))
+		}},
+		{"user_created_between", func(db *gorm.DB) {
+			_, _ = NewUserRepository(db).GetUsersCreatedBetween(ctx, day, day.AddDate(0, 1, 0))
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dbtest.AssertGoldenSQL(t, dryRunDB(t), c.name, c.fn)
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+
+	"postgresql-test/graph"
+	"postgresql-test/graph/model"
+)
+
+// cursorPrefix Relay 游标编码前缀，游标为 base64("user:<id>")
+const cursorPrefix = "user:"
+
+// graphResolver graph.ResolverRoot 的实现：写操作经 UserRepository，按ID读取经请求内的 Loader 合并
+type graphResolver struct {
+	repo UserRepository
+	base *BaseRepository[User, uint]
+}
+
+// RegisterGraphQL 注册 POST /graphql 与 GraphQL Playground（GET /playground）
+// 路由需挂载 Loader 容器（NewServer 已为每个请求挂载），同一请求内的多个 user 查询才会合并
+func RegisterGraphQL(r gin.IRouter, db *gorm.DB) {
+	res := &graphResolver{repo: NewUserRepository(db), base: NewBaseRepository[User, uint](db)}
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: res}))
+	r.POST("/graphql", gin.WrapH(srv))
+	r.GET("/playground", gin.WrapH(playground.Handler("User GraphQL", "/graphql")))
+}
+
+func (r *graphResolver) Query() graph.QueryResolver       { return (*queryResolver)(r) }
+func (r *graphResolver) Mutation() graph.MutationResolver { return (*mutationResolver)(r) }
+
+type queryResolver graphResolver
+
+// User 按ID查询用户，不存在时返回 null
+func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
+	uid, err := parseGraphID(id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := LoaderFor(ctx, r.base).Load(ctx, uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userToGraph(user), nil
+}
+
+// UsersByIds 批量按ID查询，结果与 ids 一一对应，不存在的位置为 null
+func (r *queryResolver) UsersByIds(ctx context.Context, ids []string) ([]*model.User, error) {
+	uids := make([]uint, len(ids))
+	for i, id := range ids {
+		uid, err := parseGraphID(id)
+		if err != nil {
+			return nil, err
+		}
+		uids[i] = uid
+	}
+	users, errs := LoaderFor(ctx, r.base).LoadMany(ctx, uids)
+	out := make([]*model.User, len(users))
+	for i, u := range users {
+		if errs[i] != nil && !errors.Is(errs[i], gorm.ErrRecordNotFound) {
+			return nil, errs[i]
+		}
+		if u != nil {
+			out[i] = userToGraph(u)
+		}
+	}
+	return out, nil
+}
+
+// Users 按ID升序的游标分页
+func (r *queryResolver) Users(ctx context.Context, first *int, after *string, filter *model.UserFilter) (*model.UserConnection, error) {
+	limit := defaultPageSize
+	if first != nil {
+		if *first < 0 {
+			return nil, fmt.Errorf("first 不能为负数")
+		}
+		if *first > maxPageSize {
+			return nil, fmt.Errorf("first 不能超过 %d", maxPageSize)
+		}
+		limit = *first
+	}
+	var afterID uint
+	if after != nil && *after != "" {
+		id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterID = id
+	}
+
+	filters := userFilterOptions(filter)
+	var total int64
+	if err := r.base.Pluck(ctx, "COUNT(*)", &total, filters...); err != nil {
+		return nil, err
+	}
+	// 总数只在上面统计一次；这里按游标取数，多取一行用于判断是否还有下一页
+	opts := append(slices.Clone(filters), Where("id > ?", afterID), OrderBy("id"), Limit(limit+1))
+	users, err := r.repo.Find(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hasNext := len(users) > limit
+	users = users[:min(len(users), limit)]
+
+	conn := &model.UserConnection{
+		Edges:      make([]*model.UserEdge, len(users)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNext, HasPreviousPage: afterID > 0},
+		TotalCount: int(total),
+	}
+	for i, u := range users {
+		conn.Edges[i] = &model.UserEdge{Cursor: encodeCursor(u.ID), Node: userToGraph(u)}
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+type mutationResolver graphResolver
+
+// CreateUser 创建用户，status 未指定时为 active
+func (r *mutationResolver) CreateUser(ctx context.Context, input model.CreateUserInput) (*model.User, error) {
+	in := UserInput{Name: input.Name, Email: input.Email, Age: input.Age, Status: userStatusFromGraph(input.Status)}
+	if err := binding.Validator.ValidateStruct(&in); err != nil {
+		return nil, err
+	}
+	user := &User{Name: in.Name, Email: CIText(in.Email), Age: in.Age, Status: in.Status}
+	if user.Status == "" {
+		user.Status = UserStatusActive
+	}
+	if err := r.repo.Create(ctx, user); err != nil {
+		return nil, graphError(err)
+	}
+	return userToGraph(user), nil
+}
+
+// UpdateUser 部分更新用户，只修改 input 中给出的字段
+func (r *mutationResolver) UpdateUser(ctx context.Context, id string, input model.UpdateUserInput) (*model.User, error) {
+	uid, err := parseGraphID(id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.repo.GetByID(ctx, uid)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	in := UserInput{Name: user.Name, Email: string(user.Email), Age: user.Age, Status: user.Status}
+	if input.Name != nil {
+		in.Name = *input.Name
+	}
+	if input.Email != nil {
+		in.Email = *input.Email
+	}
+	if input.Age != nil {
+		in.Age = *input.Age
+	}
+	if input.Status != nil {
+		in.Status = userStatusFromGraph(input.Status)
+	}
+	if err := binding.Validator.ValidateStruct(&in); err != nil {
+		return nil, err
+	}
+	user.Name, user.Email, user.Age, user.Status = in.Name, CIText(in.Email), in.Age, in.Status
+	if err := r.repo.Update(ctx, user); err != nil {
+		return nil, graphError(err)
+	}
+	// 同一请求内后续的 user 查询需要读到更新后的数据
+	LoaderFor(ctx, r.base).Clear(uid)
+	return userToGraph(user), nil
+}
+
+// DeleteUser 软删除用户，用户不存在时返回 false
+func (r *mutationResolver) DeleteUser(ctx context.Context, id string) (bool, error) {
+	uid, err := parseGraphID(id)
+	if err != nil {
+		return false, err
+	}
+	if _, err := r.repo.GetByID(ctx, uid); errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := r.repo.Delete(ctx, uid); err != nil {
+		return false, err
+	}
+	LoaderFor(ctx, r.base).Clear(uid)
+	return true, nil
+}
+
+func userFilterOptions(f *model.UserFilter) []QueryOption {
+	if f == nil {
+		return nil
+	}
+	var opts []QueryOption
+	if f.Name != nil && *f.Name != "" {
+		opts = append(opts, Where("name ILIKE ?", "%"+escapeLike(*f.Name)+"%"))
+	}
+	if st := userStatusFromGraph(f.Status); st != "" {
+		opts = append(opts, Where("status = ?", st))
+	}
+	if f.MinAge != nil {
+		opts = append(opts, Where("age >= ?", *f.MinAge))
+	}
+	if f.MaxAge != nil {
+		opts = append(opts, Where("age <= ?", *f.MaxAge))
+	}
+	return opts
+}
+
+// graphError 唯一约束冲突转换为可读的错误，其余原样返回
+func graphError(err error) error {
+	if isUniqueViolation(err) {
+		return errors.New("邮箱已被使用")
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("用户不存在")
+	}
+	return err
+}
+
+func parseGraphID(id string) (uint, error) {
+	n, err := strconv.ParseUint(id, 10, 0)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("无效的用户ID: %s", id)
+	}
+	return uint(n), nil
+}
+
+func encodeCursor(id uint) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("无效的游标: %s", cursor)
+	}
+	return parseGraphID(strings.TrimPrefix(string(raw), cursorPrefix))
+}
+
+func userToGraph(u *User) *model.User {
+	return &model.User{
+		ID:        strconv.FormatUint(uint64(u.ID), 10),
+		Name:      u.Name,
+		Email:     string(u.Email),
+		Age:       u.Age,
+		Status:    model.UserStatus(strings.ToUpper(string(u.Status))),
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+func userStatusFromGraph(s *model.UserStatus) UserStatus {
+	if s == nil {
+		return ""
+	}
+	return UserStatus(strings.ToLower(string(*s)))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	userv1 "postgresql-test/proto/user/v1"
+)
+
+const (
+	// DefaultRPCTimeout 客户端未设置截止时间时，服务端为每个调用设置的超时
+	DefaultRPCTimeout = 30 * time.Second
+	// defaultStreamBatch ListUsers 未指定 batch_size 时每批读取的行数
+	defaultStreamBatch = 100
+	// maxStreamBatch ListUsers 每批最多读取的行数
+	maxStreamBatch = 1000
+)
+
+var _ userv1.UserServiceServer = (*UserServer)(nil)
+
+// UserServer UserService 的 gRPC 实现，委托给 UserRepository
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+	repo    UserRepository
+	timeout time.Duration // 客户端未设置截止时间时，流式调用中每次查询的超时
+}
+
+// NewUserServer 创建用户 gRPC 服务
+func NewUserServer(repo UserRepository) *UserServer {
+	return &UserServer{repo: repo, timeout: DefaultRPCTimeout}
+}
+
+// NewGRPCServer 创建注册了 UserService 的 gRPC 服务，拦截器将调用的截止时间传递给仓库：
+// 客户端设置了 deadline 时沿用，否则一元调用使用 timeout（<=0 时为 DefaultRPCTimeout）；
+// 流式调用的时长与数据量有关，不设整体超时，由处理函数对每次查询应用 timeout。
+// 客户端取消或超时后仓库中的查询随 ctx 一同取消
+func NewGRPCServer(repo UserRepository, timeout time.Duration, opts ...grpc.ServerOption) *grpc.Server {
+	if timeout <= 0 {
+		timeout = DefaultRPCTimeout
+	}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryDeadlineInterceptor(timeout)),
+		grpc.ChainStreamInterceptor(streamLoaderInterceptor),
+	)
+	srv := grpc.NewServer(opts...)
+	userv1.RegisterUserServiceServer(srv, &UserServer{repo: repo, timeout: timeout})
+	return srv
+}
+
+// CreateUser 创建用户
+func (s *UserServer) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.User, error) {
+	in := UserInput{Name: req.GetName(), Email: req.GetEmail(), Age: int(req.GetAge()), Status: userStatusFromProto(req.GetStatus())}
+	if err := binding.Validator.ValidateStruct(&in); err != nil {
+		return nil, grpcError(err)
+	}
+	user := &User{Name: in.Name, Email: CIText(in.Email), Age: in.Age, Status: in.Status}
+	if user.Status == "" {
+		user.Status = UserStatusActive
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, grpcError(err)
+	}
+	return userToProto(user), nil
+}
+
+// GetUser 根据ID查询用户
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	user, err := s.repo.GetByID(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return userToProto(user), nil
+}
+
+// UpdateUser 全量更新用户，status 未指定时保持不变
+func (s *UserServer) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.User, error) {
+	in := UserInput{Name: req.GetName(), Email: req.GetEmail(), Age: int(req.GetAge()), Status: userStatusFromProto(req.GetStatus())}
+	if err := binding.Validator.ValidateStruct(&in); err != nil {
+		return nil, grpcError(err)
+	}
+	user, err := s.repo.GetByID(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	user.Name, user.Email, user.Age = in.Name, CIText(in.Email), in.Age
+	if in.Status != "" {
+		user.Status = in.Status
+	}
+	if err := s.repo.Update(ctx, user); err != nil {
+		return nil, grpcError(err)
+	}
+	return userToProto(user), nil
+}
+
+// DeleteUser 软删除用户
+func (s *UserServer) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*emptypb.Empty, error) {
+	if _, err := s.repo.GetByID(ctx, uint(req.GetId())); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.repo.Delete(ctx, uint(req.GetId())); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListUsers 按 ID 游标分批读取用户并逐个推送，内存占用与总行数无关；只按游标取下一批，不计算总数
+func (s *UserServer) ListUsers(req *userv1.ListUsersRequest, stream userv1.UserService_ListUsersServer) error {
+	batch := int(req.GetBatchSize())
+	if batch <= 0 {
+		batch = defaultStreamBatch
+	}
+	batch = min(batch, maxStreamBatch)
+
+	var filters []QueryOption
+	if st := userStatusFromProto(req.GetStatus()); st != "" {
+		filters = append(filters, Where("status = ?", st))
+	}
+	if req.GetMinAge() > 0 {
+		filters = append(filters, Where("age >= ?", req.GetMinAge()))
+	}
+
+	var after uint
+	for {
+		opts := append(slices.Clone(filters), Where("id > ?", after), OrderBy("id"), Limit(batch))
+		ctx, cancel := withDefaultDeadline(stream.Context(), s.timeout)
+		users, err := s.repo.Find(ctx, opts...)
+		cancel()
+		if err != nil {
+			return grpcError(err)
+		}
+		for _, u := range users {
+			if err := stream.Send(userToProto(u)); err != nil {
+				return err
+			}
+			after = u.ID
+		}
+		if len(users) < batch {
+			return nil
+		}
+	}
+}
+
+// unaryDeadlineInterceptor 为没有截止时间的调用设置默认超时
+func unaryDeadlineInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withDefaultDeadline(ctx, timeout)
+		defer cancel()
+		return handler(WithLoaders(ctx), req)
+	}
+}
+
+// streamLoaderInterceptor 流式调用版本，通过包装 ServerStream 替换其 Context；截止时间沿用客户端的设置
+func streamLoaderInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: WithLoaders(ss.Context())})
+}
+
+func withDefaultDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+// grpcError 将仓库错误映射为 gRPC 状态码
+func grpcError(err error) error {
+	var verrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &verrs):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "用户不存在")
+	case isUniqueViolation(err):
+		return status.Error(codes.AlreadyExists, "邮箱已被使用")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, ErrShuttingDown), errors.Is(err, ErrCircuitOpen):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func userToProto(u *User) *userv1.User {
+	return &userv1.User{
+		Id:        uint64(u.ID),
+		Name:      u.Name,
+		Email:     string(u.Email),
+		Age:       int32(u.Age),
+		Status:    userStatusToProto(u.Status),
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+}
+
+func userStatusToProto(s UserStatus) userv1.UserStatus {
+	switch s {
+	case UserStatusActive:
+		return userv1.UserStatus_USER_STATUS_ACTIVE
+	case UserStatusDisabled:
+		return userv1.UserStatus_USER_STATUS_DISABLED
+	default:
+		return userv1.UserStatus_USER_STATUS_UNSPECIFIED
+	}
+}
+
+func userStatusFromProto(s userv1.UserStatus) UserStatus {
+	switch s {
+	case userv1.UserStatus_USER_STATUS_ACTIVE:
+		return UserStatusActive
+	case userv1.UserStatus_USER_STATUS_DISABLED:
+		return UserStatusDisabled
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// HistorySuffix 历史表名后缀：users 的历史表为 users_history
+const HistorySuffix = "_history"
+
+// HistoryVersion 历史表中的一行：实体在 [ValidFrom, ValidTo) 期间的版本
+type HistoryVersion[T any] struct {
+	Entity    T          `gorm:"embedded"`
+	HistoryID int64      `gorm:"column:history_id"`
+	ValidFrom *time.Time `gorm:"column:valid_from"` // 为空表示从记录创建起（无创建时间列时无法确定）
+	ValidTo   time.Time  `gorm:"column:valid_to"`
+	Operation string     `gorm:"column:operation"` // UPDATE 或 DELETE
+}
+
+// EnableHistory 创建历史表与触发器：每次 UPDATE/DELETE 前的旧版本写入 <table>_history（已存在则更新触发器函数）
+// 版本时间为事务开始时间 now()，同一事务内的多次修改产生时长为 0 的中间版本
+// 表结构变更后需重新调用，新增列需同时添加到历史表
+func (r *BaseRepository[T, ID]) EnableHistory(ctx context.Context) error {
+	s, err := r.modelSchema()
+	if err != nil {
+		return err
+	}
+	if len(s.PrimaryFields) == 0 {
+		return fmt.Errorf("表 %s 没有主键", s.Table)
+	}
+	table, err := r.tableName()
+	if err != nil {
+		return err
+	}
+	history := table + HistorySuffix
+	_, base, ok := strings.Cut(table, ".")
+	if !ok {
+		base = table
+	}
+
+	cols := quoteColumns(s.DBNames)
+	olds := make([]string, len(cols))
+	for i, c := range cols {
+		olds[i] = "OLD." + c
+	}
+	var pkCond, pkCols []string
+	for _, f := range s.PrimaryFields {
+		c := pgx.Identifier{f.DBName}.Sanitize()
+		pkCols = append(pkCols, c)
+		pkCond = append(pkCond, "h."+c+" = OLD."+c)
+	}
+	// 旧版本的起始时间为上一个版本的结束时间，首个版本为创建时间
+	created := "NULL::timestamptz"
+	if f := createdAtField(s); f != nil {
+		created = "OLD." + pgx.Identifier{f.DBName}.Sanitize()
+	}
+	fn := quoteQualified(table + "_history_fn")
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS, history_id bigserial PRIMARY KEY,
+			valid_from timestamptz, valid_to timestamptz NOT NULL DEFAULT now(), operation text NOT NULL)`,
+			quoteQualified(history), quoteQualified(table)),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s, valid_to)",
+			pgx.Identifier{base + "_history_pk_idx"}.Sanitize(), quoteQualified(history), strings.Join(pkCols, ", ")),
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS trigger LANGUAGE plpgsql AS $fn$
+BEGIN
+	INSERT INTO %s (%s, valid_from, valid_to, operation)
+	VALUES (%s, COALESCE((SELECT max(h.valid_to) FROM %s h WHERE %s), %s), now(), TG_OP);
+	RETURN NULL;
+END
+$fn$`, fn, quoteQualified(history), strings.Join(cols, ", "), strings.Join(olds, ", "),
+			quoteQualified(history), strings.Join(pkCond, " AND "), created),
+		fmt.Sprintf("CREATE OR REPLACE TRIGGER %s AFTER UPDATE ON %s FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION %s()",
+			pgx.Identifier{base + "_history_update"}.Sanitize(), quoteQualified(table), fn),
+		fmt.Sprintf("CREATE OR REPLACE TRIGGER %s AFTER DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s()",
+			pgx.Identifier{base + "_history_delete"}.Sanitize(), quoteQualified(table), fn),
+	}
+	if err := requireFeature(r.db, FeatureCreateOrReplaceTrigger); err != nil {
+		return err
+	}
+	return sessionDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		for _, sql := range stmts {
+			if err := tx.Exec(sql).Error; err != nil {
+				return fmt.Errorf("创建表 %s 的历史跟踪失败: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetAsOf 查询实体在 at 时刻的版本，当时不存在（尚未创建、已删除或已软删除）时返回 gorm.ErrRecordNotFound
+// 需要先调用 EnableHistory，启用之前的修改没有历史记录
+//
+//	user, err := repo.GetAsOf(ctx, 42, time.Now().AddDate(0, 0, -7))
+func (r *BaseRepository[T, ID]) GetAsOf(ctx context.Context, id ID, at time.Time) (*T, error) {
+	return invoke(ctx, r, "GetAsOf", OpRead, func(ctx context.Context) (*T, error) { return r.getAsOf(ctx, id, at) }, id, at)
+}
+
+func (r *BaseRepository[T, ID]) getAsOf(ctx context.Context, id ID, at time.Time) (*T, error) {
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("表 %s 没有单列主键", s.Table)
+	}
+	table, err := r.tableName()
+	if err != nil {
+		return nil, err
+	}
+	db := r.session(ctx)
+	pk := clause.Eq{Column: clause.Column{Name: s.PrioritizedPrimaryField.DBName}, Value: id}
+
+	// at 之后的第一次修改之前的旧版本即 at 时刻的版本
+	var versions []HistoryVersion[T]
+	err = db.Table(table+HistorySuffix).Unscoped().Where(pk).Where("valid_to > ?", at).
+		Order("valid_to, history_id").Limit(1).Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询历史版本失败: %w", err)
+	}
+	if len(versions) > 0 {
+		v := versions[0]
+		if v.ValidFrom != nil && v.ValidFrom.After(at) || softDeleted(ctx, s, &v.Entity) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &v.Entity, nil
+	}
+
+	// at 之后没有修改过，当前版本即 at 时刻的版本（软删除的行被默认条件排除）
+	entity := new(T)
+	if err := db.Where(pk).Take(entity).Error; err != nil {
+		return nil, err
+	}
+	if f := createdAtField(s); f != nil {
+		if v, _ := f.ValueOf(ctx, reflect.ValueOf(entity).Elem()); v != nil {
+			if created, ok := v.(time.Time); ok && created.After(at) {
+				return nil, gorm.ErrRecordNotFound
+			}
+		}
+	}
+	return entity, nil
+}
+
+// History 按时间顺序返回实体的全部历史版本（不含当前版本）
+func (r *BaseRepository[T, ID]) History(ctx context.Context, id ID) ([]*HistoryVersion[T], error) {
+	return invoke(ctx, r, "History", OpRead, func(ctx context.Context) ([]*HistoryVersion[T], error) { return r.history(ctx, id) }, id)
+}
+
+func (r *BaseRepository[T, ID]) history(ctx context.Context, id ID) ([]*HistoryVersion[T], error) {
+	table, err := r.tableName()
+	if err != nil {
+		return nil, err
+	}
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, errors.New("历史查询需要单列主键")
+	}
+	var versions []*HistoryVersion[T]
+	err = r.session(ctx).Table(table + HistorySuffix).Unscoped().
+		Where(clause.Eq{Column: clause.Column{Name: s.PrioritizedPrimaryField.DBName}, Value: id}).
+		Order("valid_to, history_id").Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询历史版本失败: %w", err)
+	}
+	return versions, nil
+}
+
+// createdAtField 自动填充创建时间的字段（如 CreatedAt），没有时返回 nil
+func createdAtField(s *schema.Schema) *schema.Field {
+	for _, f := range s.Fields {
+		if f.AutoCreateTime > 0 && f.DBName != "" {
+			return f
+		}
+	}
+	return nil
+}
+
+// softDeleteField 类型为 gorm.DeletedAt 的软删除字段，没有时返回 nil
+func softDeleteField(s *schema.Schema) *schema.Field {
+	for _, f := range s.Fields {
+		if f.FieldType == reflect.TypeOf(gorm.DeletedAt{}) && f.DBName != "" {
+			return f
+		}
+	}
+	return nil
+}
+
+// softDeleted 实体是否已被软删除
+func softDeleted[T any](ctx context.Context, s *schema.Schema, entity *T) bool {
+	f := softDeleteField(s)
+	if f == nil {
+		return false
+	}
+	_, zero := f.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	return !zero
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// EntityHook 仓库写操作成功后的回调
+type EntityHook[T any] func(ctx context.Context, entity *T)
+
+// repoHooks 仓库级事件回调，与模型的 BeforeCreate/BeforeUpdate 不同，
+// 由应用代码在装配仓库时注册（缓存失效、指标、通知等副作用），无需修改模型
+type repoHooks[T any] struct {
+	mu      sync.RWMutex
+	created []EntityHook[T]
+	updated []EntityHook[T]
+	deleted []EntityHook[T]
+}
+
+// OnCreated 注册创建成功后的回调，BatchCreate 对每个实体各调用一次
+// 回调在写操作所在的 goroutine 中同步执行；仓库运行在事务中时回调先于提交执行，事务可能随后回滚
+func (r *BaseRepository[T, ID]) OnCreated(fn EntityHook[T]) {
+	r.hooks.add(&r.hooks.created, fn)
+}
+
+// OnUpdated 注册更新成功后的回调
+func (r *BaseRepository[T, ID]) OnUpdated(fn EntityHook[T]) {
+	r.hooks.add(&r.hooks.updated, fn)
+}
+
+// OnDeleted 注册删除成功（实际删除了行）后的回调；按ID删除时实体只填充了主键
+func (r *BaseRepository[T, ID]) OnDeleted(fn EntityHook[T]) {
+	r.hooks.add(&r.hooks.deleted, fn)
+}
+
+func (h *repoHooks[T]) add(list *[]EntityHook[T], fn EntityHook[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*list = append(*list, fn)
+}
+
+// fire 依次执行回调，回调列表在持锁时复制，回调内可以继续注册
+func (h *repoHooks[T]) fire(ctx context.Context, list *[]EntityHook[T], entities ...*T) {
+	h.mu.RLock()
+	hooks := *list
+	h.mu.RUnlock()
+	for _, fn := range hooks {
+		for _, e := range entities {
+			fn(ctx, e)
+		}
+	}
+}
+
+// keyEntity 构造只填充了主键的实体，供删除回调使用
+func (r *BaseRepository[T, ID]) keyEntity(ctx context.Context, key map[string]any) *T {
+	entity := new(T)
+	s, err := r.modelSchema()
+	if err != nil {
+		return entity
+	}
+	rv := reflect.ValueOf(entity).Elem()
+	for _, f := range s.PrimaryFields {
+		if v, ok := key[f.DBName]; ok {
+			_ = f.Set(ctx, rv, v)
+		}
+	}
+	return entity
+}
+
+// idEntity 构造只填充了单列主键的实体
+func (r *BaseRepository[T, ID]) idEntity(ctx context.Context, id ID) *T {
+	s, err := r.modelSchema()
+	if err != nil || s.PrioritizedPrimaryField == nil {
+		return new(T)
+	}
+	return r.keyEntity(ctx, map[string]any{s.PrioritizedPrimaryField.DBName: id})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIdempotentEntityGone 幂等键对应的实体已被删除，无法返回首次创建的结果
+var ErrIdempotentEntityGone = errors.New("幂等键对应的实体已不存在")
+
+// IdempotencyKey 幂等键记录：键首次使用时在 Scope（表名）中创建的实体主键
+type IdempotencyKey struct {
+	Scope     string          `gorm:"primaryKey;size:100"`
+	Key       string          `gorm:"primaryKey;size:255"`
+	EntityKey json.RawMessage `gorm:"type:jsonb;not null"` // 主键列名 -> 值
+	CreatedAt time.Time       `gorm:"index"`
+}
+
+func init() {
+	RegisterModel(&IdempotencyKey{}, ModelOptions{})
+}
+
+// CreateIdempotent 以幂等键创建实体：键首次使用时创建 entity 并返回它，created 为 true；
+// 重复的键不再创建，返回首次创建的行（从数据库重新读取），created 为 false。用于 API 因网络超时重试时避免重复创建：
+//
+//	user, created, err := repo.CreateIdempotent(ctx, r.Header.Get("Idempotency-Key"), &input)
+//
+// 键的登记与实体的创建在同一事务中，创建失败时键不会被占用；同一键的并发请求在唯一约束上等待先到者提交。
+// 键按表隔离，不校验重复请求的内容是否与首次相同；过期的键由 PurgeIdempotencyKeys 清理
+func (r *BaseRepository[T, ID]) CreateIdempotent(ctx context.Context, key string, entity *T) (*T, bool, error) {
+	type result struct {
+		entity  *T
+		created bool
+	}
+	res, err := invoke(ctx, r, "CreateIdempotent", OpWrite, func(ctx context.Context) (result, error) {
+		if key == "" {
+			return result{}, errors.New("幂等键不能为空")
+		}
+		s, err := r.modelSchema()
+		if err != nil {
+			return result{}, err
+		}
+		if len(s.PrimaryFields) == 0 {
+			return result{}, fmt.Errorf("表 %s 没有主键，无法记录幂等键", s.Table)
+		}
+
+		var existing *T
+		err = r.session(ctx).Transaction(func(tx *gorm.DB) error {
+			record := IdempotencyKey{Scope: s.Table, Key: key, EntityKey: json.RawMessage("{}"), CreatedAt: time.Now()}
+			claim := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+			if claim.Error != nil {
+				return fmt.Errorf("登记幂等键失败: %w", claim.Error)
+			}
+			if claim.RowsAffected == 0 {
+				found, err := r.idempotentEntity(ctx, tx, s.Table, key)
+				existing = found
+				return err
+			}
+
+			if err := tx.Create(entity).Error; err != nil {
+				return err
+			}
+			pk := make(map[string]any, len(s.PrimaryFields))
+			for _, f := range s.PrimaryFields {
+				pk[f.DBName], _ = f.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+			}
+			raw, err := json.Marshal(pk)
+			if err != nil {
+				return fmt.Errorf("编码主键失败: %w", err)
+			}
+			return tx.Model(&record).Update("entity_key", json.RawMessage(raw)).Error
+		})
+		if err != nil {
+			return result{}, err
+		}
+		if existing != nil {
+			return result{entity: existing}, nil
+		}
+		r.hooks.fire(ctx, &r.hooks.created, entity)
+		return result{entity: entity, created: true}, nil
+	}, key, entity)
+	return res.entity, res.created, err
+}
+
+// idempotentEntity 按幂等键记录的主键读取首次创建的实体
+func (r *BaseRepository[T, ID]) idempotentEntity(ctx context.Context, tx *gorm.DB, scope, key string) (*T, error) {
+	var record IdempotencyKey
+	if err := tx.Where("scope = ? AND key = ?", scope, key).Take(&record).Error; err != nil {
+		return nil, fmt.Errorf("读取幂等键失败: %w", err)
+	}
+	// json.Number 转为字符串，由 gorm 按字段类型解析，避免整数主键经 float64 丢失精度
+	var pk map[string]any
+	dec := json.NewDecoder(bytes.NewReader(record.EntityKey))
+	dec.UseNumber()
+	if err := dec.Decode(&pk); err != nil {
+		return nil, fmt.Errorf("解析幂等键 %s 的主键失败: %w", key, err)
+	}
+	for k, v := range pk {
+		if n, ok := v.(json.Number); ok {
+			pk[k] = n.String()
+		}
+	}
+
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	where, ok := conflictConditions(ctx, s, r.keyEntity(ctx, pk), s.PrimaryFieldDBNames)
+	if !ok {
+		return nil, fmt.Errorf("幂等键 %s 记录的主键不完整", key)
+	}
+	var entity T
+	err = tx.Where(where).First(&entity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("表 %s 幂等键 %s: %w", scope, key, ErrIdempotentEntityGone)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// PurgeIdempotencyKeys 删除创建时间早于 olderThan 之前的幂等键，返回删除的行数；之后重复的键会再次创建实体
+func PurgeIdempotencyKeys(ctx context.Context, db *gorm.DB, olderThan time.Duration) (int64, error) {
+	res := sessionDB(ctx, db).Where("created_at < ?", time.Now().Add(-olderThan)).Delete(&IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// IDGenerator 主键生成器，生成的值会写入带 idgen 标签的字段
+type IDGenerator interface {
+	NextID() (any, error)
+}
+
+// IDGeneratorFunc 函数形式的 IDGenerator
+type IDGeneratorFunc func() (any, error)
+
+func (f IDGeneratorFunc) NextID() (any, error) { return f() }
+
+var (
+	idGeneratorsMu sync.RWMutex
+	idGenerators   = map[string]IDGenerator{
+		"ulid":      NewULIDGenerator(),
+		"sonyflake": NewSonyflake(defaultMachineID()),
+	}
+)
+
+// RegisterIDGenerator 注册（或覆盖）命名的主键生成器，模型通过 `idgen:"<name>"` 标签引用
+func RegisterIDGenerator(name string, gen IDGenerator) {
+	idGeneratorsMu.Lock()
+	defer idGeneratorsMu.Unlock()
+	idGenerators[name] = gen
+}
+
+func idGenerator(name string) (IDGenerator, bool) {
+	idGeneratorsMu.RLock()
+	defer idGeneratorsMu.RUnlock()
+	gen, ok := idGenerators[name]
+	return gen, ok
+}
+
+// RegisterIDGeneratorCallback 注册通用的创建前回调：为带 idgen 标签且为零值的字段生成 ID，
+// 模型无需再各自编写 BeforeCreate
+//
+//	type Event struct {
+//		ID string `gorm:"primaryKey;size:26" idgen:"ulid"`
+//	}
+func RegisterIDGeneratorCallback(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("app:id_generator", generateIDs)
+}
+
+func generateIDs(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	var fields []*schema.Field
+	for _, f := range db.Statement.Schema.Fields {
+		if f.Tag.Get("idgen") != "" {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	ctx := db.Statement.Context
+	fill := func(rv reflect.Value) error {
+		for _, f := range fields {
+			if _, isZero := f.ValueOf(ctx, rv); !isZero {
+				continue
+			}
+			name := f.Tag.Get("idgen")
+			gen, ok := idGenerator(name)
+			if !ok {
+				return fmt.Errorf("未注册的主键生成器: %s", name)
+			}
+			id, err := gen.NextID()
+			if err != nil {
+				return fmt.Errorf("主键生成器 %s 生成失败: %w", name, err)
+			}
+			if err := f.Set(ctx, rv, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 遇到第一个错误即停止，批量创建时不再为其余行生成 ID、重复记录同一错误
+	switch rv := db.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := fill(reflect.Indirect(rv.Index(i))); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if err := fill(rv); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator 生成 26 位 ULID 字符串（48 位毫秒时间戳 + 80 位随机数），同一毫秒内单调递增
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	lastMS  uint64
+	lastRnd [10]byte
+}
+
+// NewULIDGenerator 创建 ULID 生成器
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{}
+}
+
+func (g *ULIDGenerator) NextID() (any, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms == g.lastMS {
+		// 同一毫秒内随机部分加一，保证单调
+		i := len(g.lastRnd) - 1
+		for ; i >= 0; i-- {
+			g.lastRnd[i]++
+			if g.lastRnd[i] != 0 {
+				break
+			}
+		}
+		if i < 0 {
+			return nil, errors.New("ULID 同一毫秒内随机部分溢出")
+		}
+	} else {
+		if _, err := rand.Read(g.lastRnd[:]); err != nil {
+			return nil, err
+		}
+		g.lastMS = ms
+	}
+
+	var b [16]byte
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	copy(b[6:], g.lastRnd[:])
+	return encodeULID(b), nil
+}
+
+// encodeULID 将 128 位按 Crockford Base32 编码为 26 个字符
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// Sonyflake 风格的 63 位整型 ID：39 位时间（10ms 为单位）+ 8 位序列号 + 16 位机器号
+type Sonyflake struct {
+	mu        sync.Mutex
+	epoch     time.Time
+	machineID uint16
+	elapsed   int64
+	sequence  uint16
+}
+
+// NewSonyflake 创建 Sonyflake 生成器，多实例部署时 machineID 必须互不相同
+func NewSonyflake(machineID uint16) *Sonyflake {
+	return &Sonyflake{
+		epoch:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		machineID: machineID,
+	}
+}
+
+func (s *Sonyflake) NextID() (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Since(s.epoch).Milliseconds() / 10
+	if s.elapsed < now {
+		s.elapsed = now
+		s.sequence = 0
+	} else {
+		// 时钟回拨或同一时间片内：沿用上一个时间片并递增序列号，溢出时借用下一个时间片
+		s.sequence = (s.sequence + 1) & 0xff
+		if s.sequence == 0 {
+			s.elapsed++
+			time.Sleep(time.Duration(s.elapsed-now) * 10 * time.Millisecond)
+		}
+	}
+	if s.elapsed >= 1<<39 {
+		return nil, errors.New("sonyflake 时间位已耗尽")
+	}
+	return uint64(s.elapsed)<<24 | uint64(s.sequence)<<16 | uint64(s.machineID), nil
+}
+
+// defaultMachineID 取私有 IPv4 地址的低 16 位，获取失败时退化为进程号
+func defaultMachineID() uint16 {
+	addrs, err := net.InterfaceAddrs()
+	if err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.IsPrivate() {
+				if ip := ipnet.IP.To4(); ip != nil {
+					return uint16(ip[2])<<8 | uint16(ip[3])
+				}
+			}
+		}
+	}
+	return uint16(os.Getpid())
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IndexOptions 索引选项
+type IndexOptions struct {
+	Name   string // 索引名，为空时按 idx_<table>_<columns> 生成
+	Unique bool
+	Method string // 索引方法，如 btree、gin、gist、brin，为空时使用默认的 btree
+	Where  string // 部分索引条件，如 "deleted_at IS NULL"
+}
+
+// ErrInTransaction CONCURRENTLY 操作不能在事务中执行
+var ErrInTransaction = errors.New("CONCURRENTLY 索引操作不能在事务中执行")
+
+// EnsureIndex 确保模型表上存在指定索引：不存在时以 CONCURRENTLY 方式创建，不阻塞表的读写；
+// 若之前的并发创建失败留下了 INVALID 索引，则先删除再重建
+func EnsureIndex(ctx context.Context, db *gorm.DB, model any, columns []string, opts IndexOptions) error {
+	schemaName, table, err := splitTableName(db, model)
+	if err != nil {
+		return err
+	}
+	if opts.Name == "" {
+		opts.Name = defaultIndexName(table, columns)
+	}
+
+	var valid []bool
+	err = db.WithContext(ctx).Raw(`SELECT i.indisvalid FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = ? AND n.nspname = COALESCE(?, current_schema())`, opts.Name, schemaName).Scan(&valid).Error
+	if err != nil {
+		return fmt.Errorf("查询索引 %s 失败: %w", opts.Name, err)
+	}
+	if len(valid) > 0 {
+		if valid[0] {
+			return nil
+		}
+		log.Printf("索引 %s 处于 INVALID 状态，重新创建", opts.Name)
+		if err := DropIndexConcurrently(ctx, db, model, opts.Name); err != nil {
+			return err
+		}
+	}
+	return CreateIndexConcurrently(ctx, db, model, columns, opts)
+}
+
+// CreateIndexConcurrently 以 CREATE INDEX CONCURRENTLY 创建索引，columns 可以是列名或表达式（如 lower(email)）
+func CreateIndexConcurrently(ctx context.Context, db *gorm.DB, model any, columns []string, opts IndexOptions) error {
+	if len(columns) == 0 {
+		return errors.New("索引至少需要一列")
+	}
+	if inTransaction(db) {
+		return ErrInTransaction
+	}
+	fullName, err := parseTableName(db, model)
+	if err != nil {
+		return err
+	}
+	if opts.Name == "" {
+		_, table, _ := strings.Cut(fullName, ".")
+		if table == "" {
+			table = fullName
+		}
+		opts.Name = defaultIndexName(table, columns)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CREATE ")
+	if opts.Unique {
+		sb.WriteString("UNIQUE ")
+	}
+	sb.WriteString("INDEX CONCURRENTLY IF NOT EXISTS ? ON ? ")
+	if opts.Method != "" {
+		sb.WriteString("USING " + opts.Method + " ")
+	}
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = quoteColumn(db, c)
+	}
+	sb.WriteString("(" + strings.Join(cols, ", ") + ")")
+	if opts.Where != "" {
+		sb.WriteString(" WHERE " + opts.Where)
+	}
+
+	if err := db.WithContext(ctx).Exec(sb.String(), clause.Column{Name: opts.Name}, clause.Table{Name: fullName}).Error; err != nil {
+		return fmt.Errorf("并发创建索引 %s 失败: %w", opts.Name, err)
+	}
+	log.Printf("索引 %s 创建成功!", opts.Name)
+	return nil
+}
+
+// DropIndexConcurrently 以 DROP INDEX CONCURRENTLY 删除模型表所在 schema 中的索引
+func DropIndexConcurrently(ctx context.Context, db *gorm.DB, model any, name string) error {
+	if inTransaction(db) {
+		return ErrInTransaction
+	}
+	schemaName, _, err := splitTableName(db, model)
+	if err != nil {
+		return err
+	}
+	if schemaName != nil {
+		name = *schemaName + "." + name
+	}
+	if err := db.WithContext(ctx).Exec("DROP INDEX CONCURRENTLY IF EXISTS ?", clause.Table{Name: name}).Error; err != nil {
+		return fmt.Errorf("并发删除索引 %s 失败: %w", name, err)
+	}
+	log.Printf("索引 %s 删除成功!", name)
+	return nil
+}
+
+// inTransaction 判断 db 是否处于事务中
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+// parseTableName 解析模型对应的表名（可能带 schema 前缀）
+func parseTableName(db *gorm.DB, model any) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", fmt.Errorf("解析模型 %T 失败: %w", model, err)
+	}
+	return stmt.Table, nil
+}
+
+// splitTableName 拆分 schema 与表名，未指定 schema 时 schemaName 为 nil
+func splitTableName(db *gorm.DB, model any) (schemaName *string, table string, err error) {
+	full, err := parseTableName(db, model)
+	if err != nil {
+		return nil, "", err
+	}
+	if s, t, ok := strings.Cut(full, "."); ok {
+		return &s, t, nil
+	}
+	return nil, full, nil
+}
+
+func defaultIndexName(table string, columns []string) string {
+	parts := []string{"idx", table}
+	for _, c := range columns {
+		parts = append(parts, strings.Trim(strings.Map(func(r rune) rune {
+			if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, c), "_"))
+	}
+	return strings.Join(parts, "_")
+}
+
+// quoteColumn 普通列名加引号，表达式（含括号、空格等）原样保留
+func quoteColumn(db *gorm.DB, c string) string {
+	for _, r := range c {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return c
+		}
+	}
+	return db.Statement.Quote(c)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrJobNotFinished 作业尚未执行完成，没有结果可读
+var ErrJobNotFinished = errors.New("查询作业尚未完成")
+
+// JobID 查询作业编号
+type JobID int64
+
+// JobStatus 查询作业状态
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending" // 等待执行
+	JobRunning JobStatus = "running" // 执行中
+	JobDone    JobStatus = "done"    // 执行成功，Result 为结果
+	JobFailed  JobStatus = "failed"  // 执行失败，Error 为错误信息
+)
+
+// QuerySpec 提交的查询：Query 为 RegisterQuery 注册的名称，Params 以 JSON 保存，执行时原样传给处理函数
+type QuerySpec struct {
+	Query  string         `json:"query"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// QueryHandler 执行一个具名查询，返回值编码为 JSON 作为作业结果
+type QueryHandler func(ctx context.Context, db *gorm.DB, params map[string]any) (any, error)
+
+// queryHandlers RegisterQuery 注册的具名查询
+var queryHandlers struct {
+	mu       sync.RWMutex
+	handlers map[string]QueryHandler
+}
+
+// RegisterQuery 注册可异步执行的具名查询，一般在 init 中调用，同名覆盖。作业由任一实例的 JobQueue.Run 执行，
+// 因此提交的是名称与参数而不是 Spec（其中的条件是无法持久化的函数），所有实例需注册相同的查询
+//
+//	RegisterQuery("user_stats", func(ctx context.Context, db *gorm.DB, _ map[string]any) (any, error) {
+//		return NewUserRepository(db).Stats(ctx)
+//	})
+func RegisterQuery(name string, fn QueryHandler) {
+	queryHandlers.mu.Lock()
+	defer queryHandlers.mu.Unlock()
+	if queryHandlers.handlers == nil {
+		queryHandlers.handlers = make(map[string]QueryHandler)
+	}
+	queryHandlers.handlers[name] = fn
+}
+
+// RegisteredQueries 返回已注册的查询名称，按名称排序
+func RegisteredQueries() []string {
+	queryHandlers.mu.RLock()
+	defer queryHandlers.mu.RUnlock()
+	names := make([]string, 0, len(queryHandlers.handlers))
+	for name := range queryHandlers.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func queryHandler(name string) (QueryHandler, bool) {
+	queryHandlers.mu.RLock()
+	defer queryHandlers.mu.RUnlock()
+	fn, ok := queryHandlers.handlers[name]
+	return fn, ok
+}
+
+// QueryJob 查询作业，保存在 query_jobs 表中
+type QueryJob struct {
+	ID         JobID           `gorm:"primaryKey" json:"id"`
+	Query      string          `gorm:"size:100;not null" json:"query"`
+	Params     json.RawMessage `gorm:"type:jsonb;not null" json:"params"`
+	Status     JobStatus       `gorm:"size:20;not null;index" json:"status"`
+	Attempts   int             `gorm:"not null" json:"attempts"`
+	Result     json.RawMessage `gorm:"type:jsonb" json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+func init() {
+	RegisterModel(&QueryJob{}, ModelOptions{})
+	RegisterQuery("user_stats", func(ctx context.Context, db *gorm.DB, _ map[string]any) (any, error) {
+		return NewUserRepository(db).Stats(ctx)
+	})
+}
+
+// Decode 将执行成功的作业结果解码到 dest；作业未完成时返回 ErrJobNotFinished，失败时返回作业的错误
+func (j *QueryJob) Decode(dest any) error {
+	switch j.Status {
+	case JobDone:
+		return json.Unmarshal(j.Result, dest)
+	case JobFailed:
+		return fmt.Errorf("查询作业 %d 执行失败: %s", j.ID, j.Error)
+	default:
+		return fmt.Errorf("查询作业 %d（%s）: %w", j.ID, j.Status, ErrJobNotFinished)
+	}
+}
+
+// jobChannel 新作业的通知频道，执行中的 JobQueue 收到后立即领取，否则按轮询间隔领取
+const jobChannel = "query_jobs"
+
+// JobQueueConfig 作业队列配置，零值字段使用默认值
+type JobQueueConfig struct {
+	Workers      int           // 同时执行的作业数，默认 2
+	PollInterval time.Duration // 没有通知时的轮询间隔，默认 5s
+	Timeout      time.Duration // 单个作业的执行超时，默认 10m；执行中超过 2 倍 Timeout 的作业视为实例已退出，重新排队
+	MaxAttempts  int           // 因实例退出被重新排队的最多次数，超过后标记为失败，默认 3
+}
+
+// JobQueue 基于 PostgreSQL 表的查询作业队列：SubmitQuery 写入作业后立即返回，
+// Run 在后台以 FOR UPDATE SKIP LOCKED 领取并执行，多个实例可同时运行互不重复；调用方用 JobResult 轮询结果。
+// 用于耗时超过 HTTP 请求时限的报表：
+//
+//	q, err := NewJobQueue(db, JobQueueConfig{})
+//	go q.Run(ctx)
+//	id, err := q.SubmitQuery(ctx, QuerySpec{Query: "user_stats"})
+//	job, err := q.JobResult(ctx, id) // job.Status 为 done 后用 job.Decode(&stats) 读取
+type JobQueue struct {
+	db    *gorm.DB
+	cfg   JobQueueConfig
+	table string
+	wake  chan struct{}
+}
+
+// NewJobQueue 创建作业队列
+func NewJobQueue(db *gorm.DB, cfg JobQueueConfig) (*JobQueue, error) {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	table, err := parseTableName(db, &QueryJob{})
+	if err != nil {
+		return nil, err
+	}
+	return &JobQueue{db: db, cfg: cfg, table: quoteQualified(table), wake: make(chan struct{}, 1)}, nil
+}
+
+// SubmitQuery 提交查询作业，返回作业编号；查询未注册时返回错误
+func (q *JobQueue) SubmitQuery(ctx context.Context, spec QuerySpec) (JobID, error) {
+	if _, ok := queryHandler(spec.Query); !ok {
+		return 0, fmt.Errorf("查询 %s 未注册", spec.Query)
+	}
+	params, err := json.Marshal(spec.Params)
+	if err != nil {
+		return 0, fmt.Errorf("编码查询参数失败: %w", err)
+	}
+	if spec.Params == nil {
+		params = json.RawMessage("{}")
+	}
+	job := QueryJob{Query: spec.Query, Params: params, Status: JobPending}
+	if err := sessionDB(ctx, q.db).Create(&job).Error; err != nil {
+		return 0, fmt.Errorf("提交查询作业失败: %w", err)
+	}
+	// 通知失败不影响作业，执行方会在轮询时领取
+	if err := Notify(ctx, q.db, jobChannel, fmt.Sprint(job.ID)); err != nil {
+		log.Printf("发送查询作业 %d 的通知失败: %v", job.ID, err)
+	}
+	return job.ID, nil
+}
+
+// JobResult 查询作业的状态与结果，作业不存在时返回 gorm.ErrRecordNotFound
+func (q *JobQueue) JobResult(ctx context.Context, id JobID) (*QueryJob, error) {
+	var job QueryJob
+	if err := sessionDB(ctx, q.db).Take(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// PurgeJobs 删除结束时间早于 olderThan 之前的已完成、已失败作业，返回删除的行数
+func (q *JobQueue) PurgeJobs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	res := sessionDB(ctx, q.db).Where("status IN ? AND finished_at < ?", []JobStatus{JobDone, JobFailed}, time.Now().Add(-olderThan)).
+		Delete(&QueryJob{})
+	return res.RowsAffected, res.Error
+}
+
+// Run 启动 Workers 个执行协程领取并执行作业，直到 ctx 取消；事务池化模式下不监听通知，只按间隔轮询
+func (q *JobQueue) Run(ctx context.Context) {
+	if activePoolerMode != PoolerTransaction {
+		go func() {
+			err := Listen(ctx, q.db, jobChannel, func(string) {
+				select {
+				case q.wake <- struct{}{}:
+				default:
+				}
+			})
+			if err != nil {
+				log.Printf("监听查询作业通知失败，改为按 %s 轮询: %v", q.cfg.PollInterval, err)
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for range q.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work 连续领取作业直到队列为空，再等待通知或轮询间隔
+func (q *JobQueue) work(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			job, err := q.claim(ctx)
+			if err != nil {
+				log.Printf("领取查询作业失败: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			q.execute(ctx, job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim 领取一个等待中的作业（或执行实例已退出的作业），没有可领取的作业时返回 nil
+func (q *JobQueue) claim(ctx context.Context) (*QueryJob, error) {
+	stale := time.Now().Add(-2 * q.cfg.Timeout)
+	var jobs []QueryJob
+	// UPDATE ... RETURNING 以 Raw + Scan 执行，显式发往主库，不依赖读写分离对原生 SQL 的判断
+	err := sessionDB(ReadFromPrimary(ctx), q.db).Raw(fmt.Sprintf(`UPDATE %[1]s SET status = ?, started_at = now(), attempts = attempts + 1
+		WHERE id = (SELECT id FROM %[1]s WHERE status = ? OR (status = ? AND started_at < ?)
+			ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING *`, q.table), JobRunning, JobPending, JobRunning, stale).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// execute 执行作业并写回结果；结果写回不受调用方 ctx 取消影响，因实例停止而中断的作业重新排队
+func (q *JobQueue) execute(ctx context.Context, job *QueryJob) {
+	result, err := q.runHandler(ctx, job)
+	update := map[string]any{"finished_at": time.Now()}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		update = map[string]any{"status": JobPending, "started_at": nil, "attempts": gorm.Expr("attempts - 1")}
+	case err != nil:
+		update["status"], update["error"] = JobFailed, err.Error()
+		log.Printf("查询作业 %d（%s）执行失败: %v", job.ID, job.Query, err)
+	default:
+		update["status"], update["result"] = JobDone, result
+	}
+	err = q.db.WithContext(context.WithoutCancel(ctx)).Model(&QueryJob{}).
+		Where("id = ? AND status = ?", job.ID, JobRunning).Updates(update).Error
+	if err != nil {
+		log.Printf("写回查询作业 %d 的结果失败: %v", job.ID, err)
+	}
+}
+
+func (q *JobQueue) runHandler(ctx context.Context, job *QueryJob) (json.RawMessage, error) {
+	if job.Attempts > q.cfg.MaxAttempts {
+		return nil, fmt.Errorf("已尝试 %d 次仍未完成", job.Attempts-1)
+	}
+	fn, ok := queryHandler(job.Query)
+	if !ok {
+		return nil, fmt.Errorf("查询 %s 未在本实例注册", job.Query)
+	}
+	var params map[string]any
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return nil, fmt.Errorf("解析查询参数失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+	ctx = WithComment(ctx, fmt.Sprintf("job:%d", job.ID))
+	v, err := fn(ctx, q.db, params)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("编码查询结果失败: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Priority 操作的优先级类别，不同类别使用独立的并发配额
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // 在线请求（默认），如 GetByID
+	PriorityBatch                       // 导出、批量修复等后台任务
+)
+
+func (p Priority) String() string {
+	if p == PriorityBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// WithPriority 之后经仓库执行的操作按 p 类别占用并发配额
+//
+//	ctx = WithPriority(ctx, PriorityBatch)
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	o := sessionFrom(ctx)
+	o.priority = p
+	return context.WithValue(ctx, sessionKey{}, o)
+}
+
+// ConcurrencyLimiter 按优先级类别限制同时执行的语句数，避免批量任务占满连接池、饿死在线请求；
+// 作为 gorm 插件挂载，配额用尽时语句等待空位，ctx 取消则放弃等待并返回 ctx 的错误。
+// 配额总是在取得连接之前占用：事务中的语句已持有连接，不再单独占用（否则持有连接等配额的事务与持有配额等连接的语句会互相等待），
+// 经 Transaction 开启的事务在开始前整体占用一个配额
+//
+//	limiter := NewConcurrencyLimiter(map[Priority]int{PriorityInteractive: 80, PriorityBatch: 10})
+//	db, err := NewPostgresDB(cfg, WithPlugins(limiter))
+type ConcurrencyLimiter struct {
+	slots map[Priority]chan struct{}
+}
+
+// NewConcurrencyLimiter 创建并发限制器，未配置或配额为 0 的类别不限制
+func NewConcurrencyLimiter(limits map[Priority]int) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{slots: map[Priority]chan struct{}{}}
+	for p, n := range limits {
+		if n > 0 {
+			l.slots[p] = make(chan struct{}, n)
+		}
+	}
+	return l
+}
+
+const limiterName = "app:concurrency_limiter"
+
+func (l *ConcurrencyLimiter) Name() string { return limiterName }
+
+// Acquire 占用 p 类别的一个配额，返回的 release 必须调用
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, p Priority) (release func(), err error) {
+	slots, ok := l.slots[p]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待 %s 类并发配额失败: %w", p, ctx.Err())
+	}
+}
+
+// InUse p 类别当前占用的配额数
+func (l *ConcurrencyLimiter) InUse(p Priority) int {
+	return len(l.slots[p])
+}
+
+const limiterReleaseKey = "app:limiter_release"
+
+// Initialize 在所有 gorm 处理器前后占用/归还配额
+func (l *ConcurrencyLimiter) Initialize(db *gorm.DB) error {
+	release := func(db *gorm.DB) {
+		if v, ok := db.InstanceGet(limiterReleaseKey); ok {
+			v.(func())()
+		}
+	}
+	return registerAround(callbackPoints(db, "*"),
+		callbackHook{"app:limiter_acquire", l.acquireForStatement}, callbackHook{"app:limiter_release", release})
+}
+
+func (l *ConcurrencyLimiter) acquireForStatement(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	o := sessionFrom(db.Statement.Context)
+	if o.holdsSlot {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	release, err := l.Acquire(db.Statement.Context, o.priority)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(limiterReleaseKey, release)
+}
+
+// holdSlot 为一段较长的操作（如流式导出，Rows 在回调结束后才被读取）整体占用一个 p 类别配额，
+// 期间经返回的 ctx 执行的语句不再单独占用；db 未挂载 ConcurrencyLimiter 时直接返回
+func holdSlot(ctx context.Context, db *gorm.DB, p Priority) (context.Context, func(), error) {
+	l, ok := db.Config.Plugins[limiterName].(*ConcurrencyLimiter)
+	if !ok || sessionFrom(ctx).holdsSlot {
+		return ctx, func() {}, nil
+	}
+	release, err := l.Acquire(ctx, p)
+	if err != nil {
+		return ctx, nil, err
+	}
+	o := sessionFrom(ctx)
+	o.priority, o.holdsSlot = p, true
+	return context.WithValue(ctx, sessionKey{}, o), release, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// Listen 在连接池中独占一条连接执行 LISTEN channel，收到通知时调用 handler，直到 ctx 取消
+// handler 在监听协程中同步执行，耗时操作应自行异步处理；事务池化模式（PgBouncer）下不可用
+func Listen(ctx context.Context, db *gorm.DB, channel string, handler func(payload string)) error {
+	if activePoolerMode == PoolerTransaction {
+		return fmt.Errorf("LISTEN %s: %w", channel, ErrUnsupportedWithPooler)
+	}
+	return withPgConn(ctx, db, func(pgConn *pgx.Conn) error {
+		if _, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("LISTEN %s 失败: %w", channel, err)
+		}
+		log.Printf("开始监听通知频道 %s", channel)
+		defer func() {
+			// ctx 取消时 pgx 会关闭连接；连接仍可用时取消监听后再归还连接池
+			if !pgConn.IsClosed() {
+				_, _ = pgConn.Exec(context.Background(), "UNLISTEN *")
+			}
+		}()
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("等待频道 %s 通知失败: %w", channel, err)
+			}
+			handler(n.Payload)
+		}
+	})
+}
+
+// Notify 向 channel 发送通知（pg_notify 支持参数绑定，payload 无需转义）
+func Notify(ctx context.Context, db *gorm.DB, channel, payload string) error {
+	return db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultLoaderWait 批次收集窗口：窗口内的 Load 调用合并为一次查询
+	DefaultLoaderWait = 2 * time.Millisecond
+	// DefaultLoaderMaxBatch 单批最多合并的 ID 数，达到后立即发出查询
+	DefaultLoaderMaxBatch = 500
+)
+
+type loadersKey struct{}
+
+// loaderRegistry 单个请求内的全部 Loader，按仓库区分
+type loaderRegistry struct {
+	mu      sync.Mutex
+	loaders map[any]any
+}
+
+// WithLoaders 为请求上下文挂载 Loader 容器，之后同一请求内的 LoaderFor 返回同一个 Loader
+func WithLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaderRegistry{loaders: make(map[any]any)})
+}
+
+// LoaderMiddleware 为每个 HTTP 请求挂载 Loader 容器
+func LoaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(WithLoaders(req.Context())))
+	})
+}
+
+// LoaderFor 返回当前请求内 repo 对应的 Loader；ctx 未经 WithLoaders 挂载时返回一个不共享的新 Loader
+//
+//	ctx = WithLoaders(ctx)
+//	user, err := LoaderFor(ctx, repo).Load(ctx, id) // 并发调用合并为一次 WHERE id IN (...)
+func LoaderFor[T any, ID comparable](ctx context.Context, repo *BaseRepository[T, ID]) *Loader[T, ID] {
+	reg, ok := ctx.Value(loadersKey{}).(*loaderRegistry)
+	if !ok {
+		return NewLoader(repo, DefaultLoaderWait, DefaultLoaderMaxBatch)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if l, ok := reg.loaders[repo].(*Loader[T, ID]); ok {
+		return l
+	}
+	l := NewLoader(repo, DefaultLoaderWait, DefaultLoaderMaxBatch)
+	reg.loaders[repo] = l
+	return l
+}
+
+// Loader 合并并发的按ID查询，并在自身生命周期内（通常是一个请求）缓存结果
+// 查不到的ID返回 gorm.ErrRecordNotFound，与 GetByID 一致
+type Loader[T any, ID comparable] struct {
+	repo     *BaseRepository[T, ID]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	batch   *loaderBatch[T, ID]
+	results map[ID]*loaderResult[T]
+}
+
+type loaderResult[T any] struct {
+	done   chan struct{}
+	entity *T
+	err    error
+}
+
+type loaderBatch[T any, ID comparable] struct {
+	ctx     context.Context
+	ids     []ID
+	results []*loaderResult[T]
+	timer   *time.Timer
+}
+
+// NewLoader 创建 Loader，wait 为批次收集窗口，maxBatch 为单批最多ID数
+func NewLoader[T any, ID comparable](repo *BaseRepository[T, ID], wait time.Duration, maxBatch int) *Loader[T, ID] {
+	return &Loader[T, ID]{repo: repo, wait: wait, maxBatch: maxBatch, results: make(map[ID]*loaderResult[T])}
+}
+
+// Load 按ID加载实体；同一批次的查询使用首个调用者的 ctx
+func (l *Loader[T, ID]) Load(ctx context.Context, id ID) (*T, error) {
+	l.mu.Lock()
+	res, ok := l.results[id]
+	if !ok {
+		res = &loaderResult[T]{done: make(chan struct{})}
+		l.results[id] = res
+		l.enqueue(ctx, id, res)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.entity, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany 批量加载，返回结果与 ids 一一对应
+func (l *Loader[T, ID]) LoadMany(ctx context.Context, ids []ID) ([]*T, []error) {
+	entities := make([]*T, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entities[i], errs[i] = l.Load(ctx, id)
+		}()
+	}
+	wg.Wait()
+	return entities, errs
+}
+
+// Clear 清除某个ID的缓存结果（如请求内更新了该实体）
+func (l *Loader[T, ID]) Clear(id ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if res, ok := l.results[id]; ok {
+		select {
+		case <-res.done:
+			delete(l.results, id)
+		default: // 查询进行中，保留以免重复查询
+		}
+	}
+}
+
+// enqueue 把ID加入当前批次，调用方需持有锁
+func (l *Loader[T, ID]) enqueue(ctx context.Context, id ID, res *loaderResult[T]) {
+	if l.batch == nil {
+		b := &loaderBatch[T, ID]{ctx: context.WithoutCancel(ctx)}
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(b) })
+		l.batch = b
+	}
+	b := l.batch
+	b.ids = append(b.ids, id)
+	b.results = append(b.results, res)
+	if l.maxBatch > 0 && len(b.ids) >= l.maxBatch {
+		if b.timer.Stop() {
+			l.batch = nil
+			go l.fetch(b)
+		}
+	}
+}
+
+// dispatch 收集窗口到期，发出批次查询
+func (l *Loader[T, ID]) dispatch(b *loaderBatch[T, ID]) {
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+	l.fetch(b)
+}
+
+// fetch 用一次 WHERE id IN (...) 查询整个批次
+func (l *Loader[T, ID]) fetch(b *loaderBatch[T, ID]) {
+	values := make([]any, len(b.ids))
+	for i, id := range b.ids {
+		values[i] = id
+	}
+	var entities []*T
+	err := l.repo.db.WithContext(b.ctx).Where(clause.IN{Column: clause.PrimaryColumn, Values: values}).Find(&entities).Error
+
+	byID := make(map[ID]*T, len(entities))
+	for _, entity := range entities {
+		id, pkErr := l.repo.primaryKey(entity)
+		if pkErr != nil {
+			err = pkErr
+			break
+		}
+		byID[id] = entity
+	}
+
+	for i, id := range b.ids {
+		res := b.results[i]
+		switch {
+		case err != nil:
+			res.err = err
+		case byID[id] == nil:
+			res.err = gorm.ErrRecordNotFound
+		default:
+			res.entity = byID[id]
+		}
+		close(res.done)
+	}
+
+	// 失败的结果不缓存，下次 Load 重新查询
+	if err != nil {
+		l.mu.Lock()
+		for i, id := range b.ids {
+			if l.results[id] == b.results[i] {
+				delete(l.results, id)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"postgresql-test/dbtest"
+)
+
+var (
+	testDBOnce     sync.Once
+	testDBConn     *gorm.DB
+	testDBErr      error
+	dropTestSchema func() error
+)
+
+// testSchemaOptions 每个测试进程在独立的 schema 中执行 MigrateAll，users.email 依赖的 citext 装在 public 中
+var testSchemaOptions = dbtest.SchemaOptions{
+	Open:       func(dsn string) (*gorm.DB, error) { return NewPostgresDB(dsn) },
+	Migrate:    MigrateAll,
+	Extensions: []string{"citext"},
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if dropTestSchema != nil {
+		if err := dropTestSchema(); err != nil {
+			log.Print(err)
+		}
+	}
+	os.Exit(code)
+}
+
+// testDB 返回本进程独立 schema 中的测试数据库连接（首次调用时创建并迁移，TestMain 结束时删除），
+// go test -p N 的各个 worker 可共用同一个库；未设置 TEST_DATABASE_DSN 时跳过测试
+func testDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := dbtest.DSN(t)
+	testDBOnce.Do(func() {
+		testDBConn, dropTestSchema, testDBErr = dbtest.NewSchema(context.Background(), dsn, testSchemaOptions)
+	})
+	if testDBErr != nil {
+		t.Fatalf("连接测试数据库失败: %v", testDBErr)
+	}
+	return testDBConn
+}
+
+// withRollback 以事务内的 UserRepository 执行 fn，测试结束时回滚
+func withRollback(t testing.TB, fn func(repo UserRepository)) {
+	t.Helper()
+	dbtest.WithRollback(t, testDB(t), func(tx *gorm.DB) {
+		fn(NewUserRepository(tx))
+	})
+}
+
+// mustLoadFixtures 在 db（通常为 dbtest.WithRollback 的事务）中加载 dir 下的夹具，失败时终止测试
+func mustLoadFixtures(t testing.TB, db *gorm.DB, dir string) Fixtures {
+	t.Helper()
+	fixtures, err := LoadFixtures(context.Background(), db, dir)
+	if err != nil {
+		t.Fatalf("加载夹具失败: %v", err)
+	}
+	return fixtures
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// MaintenanceOp 表维护操作
+type MaintenanceOp string
+
+const (
+	OpAnalyze       MaintenanceOp = "ANALYZE"
+	OpVacuum        MaintenanceOp = "VACUUM"
+	OpVacuumAnalyze MaintenanceOp = "VACUUM (ANALYZE)"
+	OpReindex       MaintenanceOp = "REINDEX TABLE CONCURRENTLY" // PostgreSQL 12+
+)
+
+var (
+	// ErrMaintenanceRunning 同一张表的维护任务正在其他进程/协程中执行
+	ErrMaintenanceRunning = errors.New("表维护任务正在执行中")
+	// ErrUnmanagedTable 表不在维护范围内
+	ErrUnmanagedTable = errors.New("表不在维护范围内")
+)
+
+// BloatReport pgstattuple 报告的表膨胀情况
+type BloatReport struct {
+	Table            string  `json:"table"`
+	TableLen         int64   `json:"table_len"`
+	TupleCount       int64   `json:"tuple_count"`
+	DeadTupleCount   int64   `json:"dead_tuple_count"`
+	DeadTuplePercent float64 `json:"dead_tuple_percent"`
+	FreeSpace        int64   `json:"free_space"`
+	FreePercent      float64 `json:"free_percent"`
+}
+
+// Maintainer 管理一组表的 VACUUM/ANALYZE/REINDEX，通过 advisory lock 保证同一张表同时只有一个维护任务
+// 会话级 advisory lock 需要直连或会话池化，事务池化模式下 Run 会返回 ErrUnsupportedWithPooler
+type Maintainer struct {
+	db     *gorm.DB
+	tables []string
+}
+
+// NewMaintainer 创建表维护器，models 为需要维护的模型
+func NewMaintainer(db *gorm.DB, models ...any) (*Maintainer, error) {
+	m := &Maintainer{db: db}
+	for _, model := range models {
+		table, err := parseTableName(db, model)
+		if err != nil {
+			return nil, err
+		}
+		m.tables = append(m.tables, table)
+	}
+	return m, nil
+}
+
+// Tables 返回受管理的表
+func (m *Maintainer) Tables() []string {
+	return slices.Clone(m.tables)
+}
+
+// Run 对单张表执行维护操作；VACUUM 不能在事务中执行，因此在独占连接上直接执行
+func (m *Maintainer) Run(ctx context.Context, op MaintenanceOp, table string) error {
+	if !slices.Contains(m.tables, table) {
+		return fmt.Errorf("%w: %s", ErrUnmanagedTable, table)
+	}
+	if activePoolerMode == PoolerTransaction {
+		return ErrUnsupportedWithPooler
+	}
+	switch op {
+	case OpAnalyze, OpVacuum, OpVacuumAnalyze, OpReindex:
+	default:
+		return fmt.Errorf("不支持的维护操作: %s", op)
+	}
+
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	// advisory lock 为会话级锁，加锁、执行、解锁必须在同一条连接上
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockKey := "maintenance:" + table
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", lockKey).Scan(&locked); err != nil {
+		return fmt.Errorf("获取维护锁失败: %w", err)
+	}
+	if !locked {
+		return fmt.Errorf("%w: %s", ErrMaintenanceRunning, table)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", lockKey); err != nil {
+			log.Printf("释放维护锁 %s 失败: %v", lockKey, err)
+		}
+	}()
+
+	start := time.Now()
+	if _, err := conn.ExecContext(ctx, string(op)+" "+quoteQualified(table)); err != nil {
+		return fmt.Errorf("表 %s 执行 %s 失败: %w", table, op, err)
+	}
+	log.Printf("表 %s 执行 %s 完成，耗时 %s", table, op, time.Since(start))
+	return nil
+}
+
+// RunAll 对全部受管理的表执行维护操作，单表失败不影响其余表
+func (m *Maintainer) RunAll(ctx context.Context, op MaintenanceOp) error {
+	var errs []error
+	for _, table := range m.tables {
+		if err := m.Run(ctx, op, table); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Schedule 按固定间隔对全部表执行维护操作，直到 ctx 取消
+func (m *Maintainer) Schedule(ctx context.Context, op MaintenanceOp, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.RunAll(ctx, op); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// Bloat 通过 pgstattuple 扩展报告表膨胀情况（会全表扫描，大表慎用）
+func (m *Maintainer) Bloat(ctx context.Context, table string) (*BloatReport, error) {
+	if !slices.Contains(m.tables, table) {
+		return nil, fmt.Errorf("%w: %s", ErrUnmanagedTable, table)
+	}
+	report := &BloatReport{Table: table}
+	err := m.db.WithContext(ctx).Raw(`SELECT table_len, tuple_count, dead_tuple_count, dead_tuple_percent, free_space, free_percent
+		FROM pgstattuple(?::regclass)`, table).Scan(report).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询表 %s 膨胀情况失败（需要 pgstattuple 扩展）: %w", table, err)
+	}
+	return report, nil
+}
+
+// Handler 管理接口：
+//
+//	GET  /bloat?table=postgresql_test.users
+//	POST /run?op=ANALYZE&table=postgresql_test.users   （table 为空时维护全部表）
+func (m *Maintainer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /bloat", func(w http.ResponseWriter, req *http.Request) {
+		report, err := m.Bloat(req.Context(), req.URL.Query().Get("table"))
+		if err != nil {
+			http.Error(w, err.Error(), maintenanceStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+	mux.HandleFunc("POST /run", func(w http.ResponseWriter, req *http.Request) {
+		op := MaintenanceOp(req.URL.Query().Get("op"))
+		var err error
+		if table := req.URL.Query().Get("table"); table != "" {
+			err = m.Run(req.Context(), op, table)
+		} else {
+			err = m.RunAll(req.Context(), op)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), maintenanceStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func maintenanceStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnmanagedTable):
+		return http.StatusNotFound
+	case errors.Is(err, ErrMaintenanceRunning):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// quoteQualified 为可能带 schema 的表名加引号，如 postgresql_test.users -> "postgresql_test"."users"
+func quoteQualified(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
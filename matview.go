@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaterializedView 物化视图模型约定：视图名与普通模型一样由命名策略（或 TableName）决定，
+// ViewQuery 基于传入的 db 构造视图定义查询（定义中不能包含绑定参数）
+// 实现该接口的模型可直接配合 BaseRepository 做只读查询
+type MaterializedView interface {
+	ViewQuery(db *gorm.DB) *gorm.DB
+}
+
+// CreateMaterializedView 创建物化视图（已存在则跳过），withData 为 false 时仅创建结构，需首次刷新后才可查询
+func CreateMaterializedView(ctx context.Context, db *gorm.DB, view MaterializedView, withData bool) error {
+	name, err := parseTableName(db, view)
+	if err != nil {
+		return err
+	}
+	sql := "CREATE MATERIALIZED VIEW IF NOT EXISTS ? AS ?"
+	if withData {
+		sql += " WITH DATA"
+	} else {
+		sql += " WITH NO DATA"
+	}
+	query := view.ViewQuery(db.Session(&gorm.Session{NewDB: true}))
+	if err := db.WithContext(ctx).Exec(sql, clause.Table{Name: name}, query).Error; err != nil {
+		return fmt.Errorf("创建物化视图 %s 失败: %w", name, err)
+	}
+	log.Printf("物化视图 %s 创建成功!", name)
+	return nil
+}
+
+// DropMaterializedView 删除物化视图
+func DropMaterializedView(ctx context.Context, db *gorm.DB, view MaterializedView) error {
+	name, err := parseTableName(db, view)
+	if err != nil {
+		return err
+	}
+	if err := db.WithContext(ctx).Exec("DROP MATERIALIZED VIEW IF EXISTS ?", clause.Table{Name: name}).Error; err != nil {
+		return fmt.Errorf("删除物化视图 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// RefreshMaterializedView 刷新物化视图
+// concurrently 为 true 时刷新期间不阻塞读，但要求视图上存在唯一索引（可用 EnsureIndex 创建）且已有数据
+func RefreshMaterializedView(ctx context.Context, db *gorm.DB, view MaterializedView, concurrently bool) error {
+	name, err := parseTableName(db, view)
+	if err != nil {
+		return err
+	}
+	sql := "REFRESH MATERIALIZED VIEW ?"
+	if concurrently {
+		sql = "REFRESH MATERIALIZED VIEW CONCURRENTLY ?"
+	}
+	if err := db.WithContext(ctx).Exec(sql, clause.Table{Name: name}).Error; err != nil {
+		return fmt.Errorf("刷新物化视图 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// RefreshMaterializedViewEvery 按固定间隔刷新物化视图，直到 ctx 取消；刷新失败只记录日志
+func RefreshMaterializedViewEvery(ctx context.Context, db *gorm.DB, view MaterializedView, interval time.Duration, concurrently bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := RefreshMaterializedView(ctx, db, view, concurrently); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// RefreshMaterializedViewOnNotify 收到 channel 上的 NOTIFY 时刷新物化视图，直到 ctx 取消
+// 刷新期间到达的多条通知会合并为一次刷新
+func RefreshMaterializedViewOnNotify(ctx context.Context, db *gorm.DB, view MaterializedView, channel string, concurrently bool) error {
+	pending := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pending:
+				if err := RefreshMaterializedView(ctx, db, view, concurrently); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+	}()
+
+	return Listen(ctx, db, channel, func(string) {
+		select {
+		case pending <- struct{}{}:
+		default:
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// mergeBatchSize 回退为 upsert 时每批插入的行数
+const mergeBatchSize = 500
+
+// Merge 以 source 为准同步表数据：按 matchColumns 匹配，已存在的行更新 updateColumns（以及 UpdatedAt 等自动更新时间列），
+// 不存在的行插入，返回受影响的行数。用于把外部系统的用户列表一次性同步到表中：
+//
+//	n, err := repo.Merge(ctx, users, []string{"email"}, []string{"name", "age"})
+//
+// PostgreSQL 15+ 使用一条 MERGE 语句，source 以 jsonb 参数传入并按表的行类型解析；
+// 更早的版本回退为 INSERT ... ON CONFLICT 分批写入，此时 matchColumns 上必须有唯一约束。
+// source 中 matchColumns 相同的行只能出现一次；只匹配未删除的行，与已软删除行重复的会作为新行插入；不触发 OnCreated/OnUpdated 回调
+func (r *BaseRepository[T, ID]) Merge(ctx context.Context, source []*T, matchColumns, updateColumns []string) (int64, error) {
+	return invoke(ctx, r, "Merge", OpWrite, func(ctx context.Context) (int64, error) { return r.merge(ctx, source, matchColumns, updateColumns) }, source, matchColumns, updateColumns)
+}
+
+func (r *BaseRepository[T, ID]) merge(ctx context.Context, source []*T, matchColumns, updateColumns []string) (int64, error) {
+	if len(source) == 0 {
+		return 0, nil
+	}
+	if len(matchColumns) == 0 {
+		return 0, errors.New("Merge 至少需要一个匹配列")
+	}
+	s, err := r.modelSchema()
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range slices.Concat(matchColumns, updateColumns) {
+		if f := s.LookUpField(name); f == nil || f.DBName != name {
+			return 0, fmt.Errorf("表 %s 没有列 %s", s.Table, name)
+		}
+	}
+	for _, f := range s.Fields {
+		if f.AutoUpdateTime > 0 && f.DBName != "" && !slices.Contains(updateColumns, f.DBName) {
+			updateColumns = append(slices.Clip(updateColumns), f.DBName)
+		}
+	}
+
+	db := r.session(ctx)
+	if version := serverVersion(db); version > 0 && version < FeatureMerge.MinVersion {
+		log.Printf("服务端版本号 %d 不支持 MERGE，表 %s 回退为 INSERT ... ON CONFLICT", version, s.Table)
+		return r.mergeByUpsert(db, s, source, matchColumns, updateColumns)
+	}
+
+	fields, rows, err := mergeRows(db, s, source)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(rows)
+	if err != nil {
+		return 0, fmt.Errorf("编码 Merge 数据失败: %w", err)
+	}
+	var deletedAt string
+	if f := softDeleteField(s); f != nil {
+		deletedAt = f.DBName
+	}
+	res := db.Exec(mergeSQL(s.Table, fields, matchColumns, updateColumns, deletedAt), string(payload))
+	if res.Error != nil {
+		return 0, fmt.Errorf("表 %s 执行 MERGE 失败: %w", s.Table, res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// mergeRows 将实体转换为以列名为键的 map，供 jsonb_populate_recordset 解析；
+// 自增主键全部为零值时不参与插入，由序列生成；自动时间列按 gorm 的规则填充当前时间，零值字段使用 default 标签的值
+func mergeRows[T any](db *gorm.DB, s *schema.Schema, source []*T) ([]string, []map[string]any, error) {
+	now := db.NowFunc()
+	var fields []*schema.Field
+	for _, f := range s.Fields {
+		if f.DBName == "" || f.Tag.Get("generated") != "" {
+			continue
+		}
+		if f.AutoIncrement && f.PrimaryKey && !slices.ContainsFunc(source, func(e *T) bool {
+			_, zero := f.ValueOf(db.Statement.Context, reflect.ValueOf(e).Elem())
+			return !zero
+		}) {
+			continue
+		}
+		fields = append(fields, f)
+	}
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.DBName
+	}
+	rows := make([]map[string]any, len(source))
+	for i, entity := range source {
+		row := make(map[string]any, len(fields))
+		for _, f := range fields {
+			v, zero := f.ValueOf(db.Statement.Context, reflect.ValueOf(entity).Elem())
+			switch {
+			case f.AutoUpdateTime > 0, f.AutoCreateTime > 0 && zero:
+				v = now
+			case zero && f.DefaultValueInterface != nil:
+				// 与 Create 一致，零值使用 default 标签的值
+				v = f.DefaultValueInterface
+			default:
+				if valuer, ok := v.(driver.Valuer); ok {
+					dv, err := valuer.Value()
+					if err != nil {
+						return nil, nil, fmt.Errorf("第 %d 行的列 %s 取值失败: %w", i+1, f.DBName, err)
+					}
+					v = dv
+				}
+			}
+			row[f.DBName] = v
+		}
+		rows[i] = row
+	}
+	return names, rows, nil
+}
+
+// mergeSQL 生成 MERGE 语句，source 为 jsonb 数组，按目标表的行类型展开；deletedAt 非空时只匹配未删除的行
+func mergeSQL(table string, columns, matchColumns, updateColumns []string, deletedAt string) string {
+	ident := func(name string) string { return pgx.Identifier{name}.Sanitize() }
+	on := make([]string, len(matchColumns))
+	for i, c := range matchColumns {
+		on[i] = "t." + ident(c) + " = s." + ident(c)
+	}
+	if deletedAt != "" {
+		on = append(on, "t."+ident(deletedAt)+" IS NULL")
+	}
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = "s." + ident(c)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "MERGE INTO %s AS t USING jsonb_populate_recordset(NULL::%s, ?::jsonb) AS s ON %s",
+		quoteQualified(table), quoteQualified(table), strings.Join(on, " AND "))
+	if len(updateColumns) > 0 {
+		sets := make([]string, len(updateColumns))
+		for i, c := range updateColumns {
+			sets[i] = ident(c) + " = s." + ident(c)
+		}
+		b.WriteString(" WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", "))
+	}
+	fmt.Fprintf(&b, " WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(quoteColumns(columns), ", "), strings.Join(values, ", "))
+	return b.String()
+}
+
+// mergeByUpsert PostgreSQL 15 以前的回退实现：在一个事务中分批 INSERT ... ON CONFLICT DO UPDATE
+func (r *BaseRepository[T, ID]) mergeByUpsert(db *gorm.DB, s *schema.Schema, source []*T, matchColumns, updateColumns []string) (int64, error) {
+	conflict := clause.OnConflict{TargetWhere: softDeleteConflictTarget(s), DoNothing: len(updateColumns) == 0}
+	for _, c := range matchColumns {
+		conflict.Columns = append(conflict.Columns, clause.Column{Name: c})
+	}
+	if len(updateColumns) > 0 {
+		conflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(conflict).CreateInBatches(source, mergeBatchSize)
+		affected = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Merge 回退为 upsert 失败: %w", err)
+	}
+	return affected, nil
+}
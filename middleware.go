@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OpKind 仓库操作的类别
+type OpKind int
+
+const (
+	OpRead  OpKind = iota // 查询、导出等只读操作
+	OpWrite               // 创建、更新、删除、导入等写操作
+)
+
+func (k OpKind) String() string {
+	if k == OpWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// Operation 一次仓库调用的描述，Args 为调用参数（不含 ctx，可变参数以切片形式出现），
+// 中间件可据此记录日志、生成缓存键或校验实体，但不应修改
+type Operation struct {
+	Table  string
+	Method string
+	Kind   OpKind
+	Args   []any
+}
+
+// Handler 执行仓库操作，返回方法的结果（只返回 error 的方法为 nil）
+type Handler func(ctx context.Context, op *Operation) (any, error)
+
+// RepositoryMiddleware 包装仓库操作，日志、指标、重试、缓存、校验等横切逻辑以中间件组合，无需写进仓库本身：
+//
+//	repo.Use(LoggingMiddleware(200*time.Millisecond), RetryMiddleware(3, 50*time.Millisecond))
+//
+// 中间件可以直接返回结果而不调用 next（如命中缓存），结果类型必须与方法的返回值一致
+type RepositoryMiddleware func(next Handler) Handler
+
+// Use 注册中间件，先注册的在外层；应在装配仓库时调用，Transaction 中的仓库沿用已注册的中间件。
+// 表结构相关的操作（CreateTable、EnableHistory 等）与 Transaction 本身不经过中间件；
+// 已在同一张表的仓库操作内部（如 List 调用 Count）的嵌套调用也不再经过中间件，其他表的仓库照常经过
+func (r *BaseRepository[T, ID]) Use(mw ...RepositoryMiddleware) {
+	r.middleware = append(r.middleware[:len(r.middleware):len(r.middleware)], mw...)
+}
+
+// invoke 经中间件链执行 fn，未注册中间件时直接执行；fn 的 ctx 中带有当前操作（见 operationFrom）。
+// 仓库方法内部调用的同表方法（如 List 调用 Count、Stats 调用 CountByInterval）沿用外层的操作，不再经过中间件，
+// 避免重复记录日志、重试与限流；外层操作属于其他表时（如钩子中写审计表）照常经过本仓库的中间件
+func invoke[R any, T any, ID comparable](ctx context.Context, r *BaseRepository[T, ID], method string, kind OpKind, fn func(ctx context.Context) (R, error), args ...any) (R, error) {
+	table, _ := r.tableName()
+	if outer := operationFrom(ctx); outer != nil && outer.Table == table {
+		return fn(ctx)
+	}
+	op := &Operation{Table: table, Method: method, Kind: kind, Args: args}
+	if len(r.middleware) == 0 {
+		return fn(context.WithValue(ctx, operationKey{}, op))
+	}
+	h := Handler(func(ctx context.Context, op *Operation) (any, error) {
+		return fn(context.WithValue(ctx, operationKey{}, op))
+	})
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	res, err := h(ctx, op)
+	v, ok := res.(R)
+	if !ok && res != nil {
+		return v, fmt.Errorf("中间件返回的 %T 与 %s 的返回值类型不一致", res, method)
+	}
+	return v, err
+}
+
+type operationKey struct{}
+
+// operationFrom 返回 ctx 所在的仓库操作，不在仓库方法内时为 nil
+func operationFrom(ctx context.Context) *Operation {
+	op, _ := ctx.Value(operationKey{}).(*Operation)
+	return op
+}
+
+// run 经中间件链执行只返回 error 的操作
+func (r *BaseRepository[T, ID]) run(ctx context.Context, method string, kind OpKind, fn func(ctx context.Context) error, args ...any) error {
+	_, err := invoke(ctx, r, method, kind, func(ctx context.Context) (any, error) { return nil, fn(ctx) }, args...)
+	return err
+}
+
+// LoggingMiddleware 记录失败的操作与耗时超过 slow 的操作，slow <= 0 时只记录失败；
+// 记录不存在不视为失败
+func LoggingMiddleware(slow time.Duration) RepositoryMiddleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (any, error) {
+			start := time.Now()
+			res, err := next(ctx, op)
+			elapsed := time.Since(start)
+			switch {
+			case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+				log.Printf("%s.%s 失败 (%s): %v", op.Table, op.Method, elapsed, err)
+			case slow > 0 && elapsed >= slow:
+				log.Printf("%s.%s 耗时 %s", op.Table, op.Method, elapsed)
+			}
+			return res, err
+		}
+	}
+}
+
+// RetryMiddleware 只读操作因数据库不可用（连接失败、超时等，见熔断器的判定）失败时重试，最多执行 attempts 次，
+// 第 n 次重试前等待 n*backoff；写操作不重试，避免重复写入
+func RetryMiddleware(attempts int, backoff time.Duration) RepositoryMiddleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (any, error) {
+			res, err := next(ctx, op)
+			for i := 1; i < attempts && op.Kind == OpRead && isUnavailable(err) && ctx.Err() == nil; i++ {
+				select {
+				case <-time.After(time.Duration(i) * backoff):
+				case <-ctx.Done():
+					return res, err
+				}
+				log.Printf("%s.%s 第 %d 次重试: %v", op.Table, op.Method, i, err)
+				res, err = next(ctx, op)
+			}
+			return res, err
+		}
+	}
+}
+
+// ValidationMiddleware 写操作执行前用 validate 校验参数中的实体（*T 或 []*T），校验失败时不执行
+func ValidationMiddleware[T any](validate func(entity *T) error) RepositoryMiddleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (any, error) {
+			if op.Kind == OpWrite {
+				for _, arg := range op.Args {
+					var entities []*T
+					switch v := arg.(type) {
+					case *T:
+						entities = []*T{v}
+					case []*T:
+						entities = v
+					}
+					for _, e := range entities {
+						if err := validate(e); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+			return next(ctx, op)
+		}
+	}
+}
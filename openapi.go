@@ -0,0 +1,290 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// apiParam 接口参数（path/query/header）
+type apiParam struct {
+	name, in, typ, desc string
+	required            bool
+	enum                []string
+}
+
+// apiOperation 接口描述，同时用于注册路由（RegisterRoutes）与生成文档（OpenAPISpec），两者不会不一致
+type apiOperation struct {
+	method, path, summary string
+	handler               func(*UserHandler, *gin.Context)
+	params                []apiParam
+	body                  any
+	responses             map[int]any // 状态码 -> 响应体类型，nil 表示无响应体
+}
+
+var userOperations = []apiOperation{
+	{
+		method: "get", path: "/users", handler: (*UserHandler).list, summary: "分页查询用户",
+		params: []apiParam{
+			{name: "offset", in: "query", typ: "integer", desc: "跳过的行数"},
+			{name: "limit", in: "query", typ: "integer", desc: "每页行数（最大 100，超过时返回 400）"},
+			{name: "name", in: "query", typ: "string", desc: "姓名包含"},
+			{name: "status", in: "query", typ: "string", desc: "状态", enum: UserStatus("").EnumValues()},
+			{name: "min_age", in: "query", typ: "integer", desc: "最小年龄"},
+			{name: "max_age", in: "query", typ: "integer", desc: "最大年龄"},
+		},
+		responses: map[int]any{200: UserPage{}, 400: Problem{}},
+	},
+	{
+		method: "post", path: "/users", handler: (*UserHandler).create, summary: "创建用户", body: UserInput{},
+		params: []apiParam{
+			{name: "Idempotency-Key", in: "header", typ: "string", desc: "幂等键，重试时携带相同的键不会重复创建"},
+		},
+		responses: map[int]any{200: User{}, 201: User{}, 400: Problem{}, 409: Problem{}},
+	},
+	{
+		method: "get", path: "/users/stats", handler: (*UserHandler).stats, summary: "用户统计",
+		responses: map[int]any{200: UserStats{}},
+	},
+	{
+		method: "get", path: "/users/{id}", handler: (*UserHandler).get, summary: "查询用户", params: []apiParam{userIDParam},
+		responses: map[int]any{200: User{}, 400: Problem{}, 404: Problem{}},
+	},
+	{
+		method: "put", path: "/users/{id}", handler: (*UserHandler).update, summary: "更新用户", params: []apiParam{userIDParam}, body: UserInput{},
+		responses: map[int]any{200: User{}, 400: Problem{}, 404: Problem{}, 409: Problem{}},
+	},
+	{
+		method: "delete", path: "/users/{id}", handler: (*UserHandler).delete, summary: "删除用户", params: []apiParam{userIDParam},
+		responses: map[int]any{204: nil, 400: Problem{}, 404: Problem{}},
+	},
+}
+
+var userIDParam = apiParam{name: "id", in: "path", typ: "integer", desc: "用户ID", required: true}
+
+// ginPath OpenAPI 路径参数 {id} 转换为 gin 的 :id
+func (op apiOperation) ginPath() string {
+	return strings.NewReplacer("{", ":", "}", "").Replace(op.path)
+}
+
+// OpenAPISpec 生成用户接口的 OpenAPI 3.0 文档
+// 模型的结构由反射得到：example 标签作为示例值，validate 标签转换为 required、长度、范围与枚举约束
+func OpenAPISpec() map[string]any {
+	b := &openAPIBuilder{schemas: map[string]any{}}
+	paths := map[string]any{}
+	for _, op := range userOperations {
+		item, _ := paths[op.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[op.path] = item
+		}
+		item[op.method] = b.operation(op)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "User API",
+			"description": "基于 UserRepository 的用户资源 REST 接口，错误响应遵循 RFC 7807（application/problem+json）",
+			"version":     "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": b.schemas},
+	}
+}
+
+// RegisterOpenAPI 注册 /openapi.json 与 Swagger UI（/docs）
+func RegisterOpenAPI(r gin.IRouter) {
+	spec := OpenAPISpec()
+	r.GET("/openapi.json", func(c *gin.Context) { c.JSON(http.StatusOK, spec) })
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	})
+}
+
+// swaggerUIPage Swagger UI 页面，静态资源从 CDN 加载
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>User API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`
+
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+func (b *openAPIBuilder) operation(op apiOperation) map[string]any {
+	out := map[string]any{"summary": op.summary, "tags": []string{"users"}}
+	if len(op.params) > 0 {
+		params := make([]any, len(op.params))
+		for i, p := range op.params {
+			schema := map[string]any{"type": p.typ}
+			if len(p.enum) > 0 {
+				schema["enum"] = p.enum
+			}
+			params[i] = map[string]any{
+				"name": p.name, "in": p.in, "description": p.desc, "required": p.required, "schema": schema,
+			}
+		}
+		out["parameters"] = params
+	}
+	if op.body != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(op.body))}},
+		}
+	}
+	responses := map[string]any{}
+	for status, body := range op.responses {
+		resp := map[string]any{"description": http.StatusText(status)}
+		if body != nil {
+			mime := "application/json"
+			if _, ok := body.(Problem); ok {
+				mime = "application/problem+json"
+			}
+			resp["content"] = map[string]any{mime: map[string]any{"schema": b.schema(reflect.TypeOf(body))}}
+		}
+		responses[strconv.Itoa(status)] = resp
+	}
+	out["responses"] = responses
+	return out
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// schema 返回类型对应的 JSON Schema，结构体注册到 components 并以 $ref 引用
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == deletedAtType:
+		return map[string]any{"type": "string", "format": "date-time", "nullable": true}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := b.schemas[t.Name()]; !ok {
+			b.schemas[t.Name()] = nil // 先占位，避免自引用时无限递归
+			b.schemas[t.Name()] = b.structSchema(t)
+		}
+		return ref
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		s := map[string]any{"type": "string"}
+		if e, ok := reflect.Zero(t).Interface().(interface{ EnumValues() []string }); ok {
+			s["enum"] = e.EnumValues()
+		}
+		return s
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if n, _, _ := strings.Cut(tag, ","); n != "" {
+				name = n
+			}
+		}
+
+		prop := b.schema(f.Type)
+		if _, isRef := prop["$ref"]; !isRef {
+			if ex, ok := f.Tag.Lookup("example"); ok {
+				prop["example"] = exampleValue(prop["type"], ex)
+			}
+			if applyValidateTag(prop, f.Tag.Get("validate")) {
+				required = append(required, name)
+			}
+		}
+		props[name] = prop
+	}
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// applyValidateTag 将 validate 规则转换为 schema 约束，返回字段是否必填
+func applyValidateTag(prop map[string]any, tag string) bool {
+	required := false
+	for rule := range strings.SplitSeq(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			prop["format"] = "email"
+		case "oneof":
+			prop["enum"] = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			key := map[string]string{"min": "minimum", "max": "maximum"}[name]
+			if prop["type"] == "string" {
+				key = map[string]string{"min": "minLength", "max": "maxLength"}[name]
+			}
+			prop[key] = n
+		}
+	}
+	return required
+}
+
+// exampleValue 按 schema 类型转换 example 标签的值
+func exampleValue(typ any, ex string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(ex, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(ex, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(ex); err == nil {
+			return v
+		}
+	}
+	return ex
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 注册的每个用户路由都应出现在 OpenAPI 文档中，反之亦然
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewUserHandler(NewFakeUserRepository()).RegisterRoutes(r)
+
+	paths := OpenAPISpec()["paths"].(map[string]any)
+	documented := 0
+	for _, item := range paths {
+		documented += len(item.(map[string]any))
+	}
+	routes := r.Routes()
+	if len(routes) != documented {
+		t.Errorf("注册了 %d 个路由，文档中有 %d 个接口", len(routes), documented)
+	}
+	for _, route := range routes {
+		path := route.Path
+		for _, seg := range strings.Split(path, "/") {
+			if name, ok := strings.CutPrefix(seg, ":"); ok {
+				path = strings.Replace(path, seg, "{"+name+"}", 1)
+			}
+		}
+		item, _ := paths[path].(map[string]any)
+		if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("路由 %s %s 不在 OpenAPI 文档中", route.Method, route.Path)
+		}
+	}
+}
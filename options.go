@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// Option NewPostgresDB 的可选项，用于注入 PostgresConfig 无法承载的依赖（自定义 logger、tracer、插件等）
+type Option func(*dbOptions)
+
+type dbOptions struct {
+	logger         logger.Interface
+	tracer         Tracer
+	gormConfig     *gorm.Config
+	plugins        []gorm.Plugin
+	namingStrategy schema.Namer
+	queryTags      *queryTags
+	breaker        *CircuitBreaker
+	reconnector    *Reconnector
+	callbacks      []Callback
+}
+
+func newDBOptions(opts []Option) *dbOptions {
+	o := &dbOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLogger 使用自定义 gorm logger，优先于 PostgresConfig.LogLevel
+func WithLogger(l logger.Interface) Option {
+	return func(o *dbOptions) { o.logger = l }
+}
+
+// WithTracer 为每条语句创建追踪 span
+func WithTracer(t Tracer) Option {
+	return func(o *dbOptions) { o.tracer = t }
+}
+
+// WithGormConfig 以给定的 gorm.Config 为基础，未设置的 Logger/NowFunc 使用默认值
+func WithGormConfig(c *gorm.Config) Option {
+	return func(o *dbOptions) { o.gormConfig = c }
+}
+
+// WithPlugins 连接建立后注册 gorm 插件
+func WithPlugins(plugins ...gorm.Plugin) Option {
+	return func(o *dbOptions) { o.plugins = append(o.plugins, plugins...) }
+}
+
+// WithNamingStrategy 自定义表名/列名命名策略
+func WithNamingStrategy(n schema.Namer) Option {
+	return func(o *dbOptions) { o.namingStrategy = n }
+}
+
+// WithQueryTags 为每条语句附加 /*op='GetByID',service='users'*/ 形式的标签，service 为空时使用表名
+func WithQueryTags(service string) Option {
+	return func(o *dbOptions) { o.queryTags = &queryTags{service: service} }
+}
+
+// WithCircuitBreaker 挂载熔断器，数据库不可用时快速失败并返回 ErrCircuitOpen
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(o *dbOptions) { o.breaker = b }
+}
+
+// WithReconnect 挂载重连器，数据库重启或主备切换后断连错误集中出现时自动重置连接池
+func WithReconnect(cfg ReconnectConfig) Option {
+	return func(o *dbOptions) { o.reconnector = NewReconnector(cfg) }
+}
+
+// Callback 额外注册的 gorm 回调，Before/After 为参照的回调名（可为 "*"），均为空时追加到末尾；
+// Replace 为 true 时替换同名回调（如 gorm:update）
+type Callback struct {
+	Processor string // create、query、update、delete、row、raw
+	Name      string
+	Before    string
+	After     string
+	Replace   bool
+	Fn        func(*gorm.DB)
+}
+
+// WithCallbacks 连接建立后注册回调，在内置回调与插件之后注册：
+//
+//	db, err := NewPostgresDB(cfg, WithCallbacks(Callback{Processor: "create", Name: "audit:stamp", Before: "gorm:create", Fn: stamp}))
+func WithCallbacks(callbacks ...Callback) Option {
+	return func(o *dbOptions) { o.callbacks = append(o.callbacks, callbacks...) }
+}
+
+func (c Callback) register(db *gorm.DB) error {
+	cb := db.Callback()
+	p := cb.Create()
+	switch c.Processor {
+	case "create":
+	case "query":
+		p = cb.Query()
+	case "update":
+		p = cb.Update()
+	case "delete":
+		p = cb.Delete()
+	case "row":
+		p = cb.Row()
+	case "raw":
+		p = cb.Raw()
+	default:
+		return fmt.Errorf("未知的回调类型: %q", c.Processor)
+	}
+
+	type registrar interface {
+		Register(name string, fn func(*gorm.DB)) error
+		Replace(name string, fn func(*gorm.DB)) error
+	}
+	var r registrar = p
+	switch {
+	case c.Before != "" && c.After != "":
+		r = p.Before(c.Before).After(c.After)
+	case c.Before != "":
+		r = p.Before(c.Before)
+	case c.After != "":
+		r = p.After(c.After)
+	}
+	if c.Replace {
+		return r.Replace(c.Name, c.Fn)
+	}
+	return r.Register(c.Name, c.Fn)
+}
+
+// namingStrategy 根据配置生成命名策略，schema 通过表前缀 "<schema>." 实现
+func (cfg *PostgresConfig) namingStrategy() schema.Namer {
+	prefix := cfg.TablePrefix
+	if cfg.Schema != "" {
+		prefix = cfg.Schema + "." + prefix
+	}
+	return schema.NamingStrategy{TablePrefix: prefix, SingularTable: cfg.SingularTable}
+}
+
+// buildGormConfig 合并选项生成 gorm.Config，命名策略优先级：WithNamingStrategy > WithGormConfig > PostgresConfig
+func (o *dbOptions) buildGormConfig(level logger.LogLevel, namer schema.Namer) *gorm.Config {
+	c := &gorm.Config{}
+	if o.gormConfig != nil {
+		copied := *o.gormConfig
+		c = &copied
+	}
+	if o.logger != nil {
+		c.Logger = o.logger
+	} else if c.Logger == nil {
+		c.Logger = logger.Default.LogMode(level)
+	}
+	if c.NowFunc == nil {
+		c.NowFunc = func() time.Time {
+			return time.Now().Local()
+		}
+	}
+	if o.namingStrategy != nil {
+		c.NamingStrategy = o.namingStrategy
+	} else if c.NamingStrategy == nil {
+		c.NamingStrategy = namer
+	}
+	return c
+}
+
+// setup 连接建立后注册 tracer、熔断器、重连器、插件与额外回调
+func (o *dbOptions) setup(db *gorm.DB) error {
+	if o.tracer != nil {
+		if err := registerTracer(db, o.tracer); err != nil {
+			return fmt.Errorf("注册 tracer 失败: %w", err)
+		}
+	}
+	if o.breaker != nil {
+		if err := RegisterCircuitBreaker(db, o.breaker); err != nil {
+			return fmt.Errorf("注册熔断器失败: %w", err)
+		}
+	}
+	if o.reconnector != nil {
+		if err := RegisterReconnector(db, o.reconnector); err != nil {
+			return fmt.Errorf("注册重连器失败: %w", err)
+		}
+	}
+	for _, p := range o.plugins {
+		if err := db.Use(p); err != nil {
+			return fmt.Errorf("注册插件 %s 失败: %w", p.Name(), err)
+		}
+	}
+	for _, c := range o.callbacks {
+		if err := c.register(db); err != nil {
+			return fmt.Errorf("注册回调 %s 失败: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// Tracer 语句级追踪接口，可适配 OpenTelemetry 等实现
+// StartSpan 在语句执行前调用，返回的 end 在语句执行后调用
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (spanCtx context.Context, end func(sql string, err error))
+}
+
+const traceEndKey = "app:trace_end"
+
+// registerTracer 在全部处理器前后注册追踪回调，span 名形如 "gorm.query users"
+func registerTracer(db *gorm.DB, t Tracer) error {
+	end := func(db *gorm.DB) {
+		if v, ok := db.InstanceGet(traceEndKey); ok {
+			v.(func(string, error))(db.Statement.SQL.String(), db.Error)
+		}
+	}
+	for _, p := range callbackPoints(db, "*") {
+		op := p.processor
+		err := p.before.Register("app:trace_begin", func(db *gorm.DB) {
+			ctx, end := t.StartSpan(db.Statement.Context, "gorm."+op+" "+db.Statement.Table)
+			db.Statement.Context = ctx
+			db.InstanceSet(traceEndKey, end)
+		})
+		if err != nil {
+			return err
+		}
+		if err := p.after.Register("app:trace_end", end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveConfig 解析 NewPostgresDB 的 dsnOrCfg 参数，仅传 DSN 时连接池等参数使用默认值
+func resolveConfig(dsnOrCfg any) (*PostgresConfig, string, error) {
+	switch v := dsnOrCfg.(type) {
+	case string:
+		return &PostgresConfig{}, v, nil
+	case PostgresConfig:
+		return resolveConfig(&v)
+	case *PostgresConfig:
+		if v.URL != "" {
+			merged, err := MergeDSN(v.URL, v)
+			if err != nil {
+				return nil, "", err
+			}
+			v = merged
+		}
+		return v, v.DSN(), nil
+	default:
+		return nil, "", fmt.Errorf("不支持的数据库配置类型: %T", dsnOrCfg)
+	}
+}
+
+// open 打开数据库并注册通用回调与插件
+func (o *dbOptions) open(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, config)
+	if err != nil {
+		return nil, fmt.Errorf("连接数据库失败: %v", err)
+	}
+
+	// 注册通用主键生成回调（ULID/Sonyflake 等）
+	if err := RegisterIDGeneratorCallback(db); err != nil {
+		return nil, fmt.Errorf("注册主键生成回调失败: %w", err)
+	}
+	// 注册生成列回调，写操作跳过数据库计算的列
+	if err := RegisterGeneratedColumnCallback(db); err != nil {
+		return nil, fmt.Errorf("注册生成列回调失败: %w", err)
+	}
+	// 注册在途操作跟踪回调，供 Shutdown 优雅关闭
+	if err := RegisterShutdownCallbacks(db); err != nil {
+		return nil, fmt.Errorf("注册关闭跟踪回调失败: %w", err)
+	}
+	// 注册 SQL 注释回调，输出经 WithComment 附加到 ctx 的注释及语句标签
+	if err := registerSQLCommentCallbacks(db, o.queryTags); err != nil {
+		return nil, fmt.Errorf("注册 SQL 注释回调失败: %w", err)
+	}
+	if err := o.setup(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenWithDialector 使用任意 gorm 方言打开数据库，注册与 NewPostgresDB 相同的回调与插件，
+// 但不设置连接池、不 ping、也不替换全局 DB。单元测试中可配合 sqlmock 使用：
+//
+//	mockDB, mock, _ := sqlmock.New()
+//	db, err := OpenWithDialector(postgres.New(postgres.Config{Conn: mockDB}), WithGormConfig(&gorm.Config{SkipDefaultTransaction: true}))
+//	repo := NewUserRepository(db)
+func OpenWithDialector(dialector gorm.Dialector, opts ...Option) (*gorm.DB, error) {
+	o := newDBOptions(opts)
+	return o.open(dialector, o.buildGormConfig(logger.Warn, schema.NamingStrategy{}))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidCursor 游标无法解析（被篡改或来自其他模型）
+	ErrInvalidCursor = errors.New("无效的游标")
+	// ErrPageLimit 分页参数超出 PageLimits 的限制
+	ErrPageLimit = errors.New("分页参数超出限制")
+)
+
+// PageLimits 分页参数的限制，防止一次错误的调用（limit=0、limit=1000000、超深 offset）扫描整张表
+type PageLimits struct {
+	Default   int  // limit <= 0 时使用的每页行数
+	Max       int  // 每页最多返回的行数，0 表示不限制
+	MaxOffset int  // offset 上限，0 表示不限制；深翻页应改用 ListByCursor
+	Strict    bool // 为 true 时超出限制（包括负数 offset）返回 ErrPageLimit，否则截断到合法范围
+}
+
+// DefaultPageLimits 新建仓库使用的分页限制：只为 limit <= 0 补上与 HTTP 接口一致的默认值，不限制上限，
+// 避免批处理等内部调用被截断；面向外部的入口各自校验上限（HTTP、GraphQL 超过 maxPageSize 时拒绝），也可以 SetPageLimits 设置
+var DefaultPageLimits = PageLimits{Default: defaultPageSize}
+
+// normalize 按限制修正 offset 与 limit
+func (l PageLimits) normalize(offset, limit int) (int, int, error) {
+	if offset < 0 {
+		if l.Strict {
+			return 0, 0, fmt.Errorf("%w: offset 不能为负数: %d", ErrPageLimit, offset)
+		}
+		offset = 0
+	}
+	if l.MaxOffset > 0 && offset > l.MaxOffset {
+		return 0, 0, fmt.Errorf("%w: offset %d 超过上限 %d，请改用游标分页", ErrPageLimit, offset, l.MaxOffset)
+	}
+	if limit <= 0 {
+		limit = l.Default
+	}
+	if l.Max > 0 && limit > l.Max {
+		if l.Strict {
+			return 0, 0, fmt.Errorf("%w: limit %d 超过上限 %d", ErrPageLimit, limit, l.Max)
+		}
+		limit = l.Max
+	}
+	return offset, limit, nil
+}
+
+// SetPageLimits 设置 List/ListByCursor 的分页限制，应在装配仓库时调用
+func (r *BaseRepository[T, ID]) SetPageLimits(l PageLimits) {
+	r.limits = l
+}
+
+// Page 分页结果：List 按 offset 分页时填充 Offset，ListByCursor 按游标分页时填充 Cursor/NextCursor
+type Page[T any] struct {
+	Items      []*T   `json:"items"`
+	Total      int64  `json:"total" example:"42"` // 满足过滤条件的总数
+	Offset     int    `json:"offset" example:"0"`
+	Limit      int    `json:"limit" example:"20"`
+	Cursor     string `json:"cursor,omitempty"`      // 本页的起始游标，为空表示第一页
+	NextCursor string `json:"next_cursor,omitempty"` // 传给下一次 ListByCursor 获取下一页，最后一页为空
+	HasNext    bool   `json:"has_next" example:"true"`
+	PageCount  int    `json:"page_count" example:"3"`
+}
+
+// newOffsetPage 按 offset 分页的结果，计算是否有下一页与总页数
+func newOffsetPage[T any](items []*T, total int64, offset, limit int) *Page[T] {
+	page := &Page[T]{Items: items, Total: total, Offset: offset, Limit: limit}
+	page.HasNext = int64(offset+len(items)) < total
+	if limit > 0 {
+		page.PageCount = int((total + int64(limit) - 1) / int64(limit))
+	}
+	return page
+}
+
+// ListByCursor 按主键游标分页（keyset），结果按主键升序；与 offset 分页相比，翻页深度不影响性能，
+// 翻页期间插入或删除数据也不会导致重复或遗漏。cursor 为空时从第一页开始，opts 中的排序会被忽略
+//
+//	page, err := repo.ListByCursor(ctx, "", 20, Where("status = ?", "active"))
+//	next, err := repo.ListByCursor(ctx, page.NextCursor, 20, Where("status = ?", "active"))
+func (r *BaseRepository[T, ID]) ListByCursor(ctx context.Context, cursor string, limit int, opts ...QueryOption) (*Page[T], error) {
+	return invoke(ctx, r, "ListByCursor", OpRead, func(ctx context.Context) (*Page[T], error) { return r.listByCursor(ctx, cursor, limit, opts...) }, cursor, limit, opts)
+}
+
+func (r *BaseRepository[T, ID]) listByCursor(ctx context.Context, cursor string, limit int, opts ...QueryOption) (*Page[T], error) {
+	s, err := r.modelSchema()
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("表 %s 没有单列主键，无法按游标分页", s.Table)
+	}
+	if _, limit, err = r.limits.normalize(0, limit); err != nil {
+		return nil, err
+	}
+
+	o := newQueryOptions(opts)
+	o.orders = nil
+	page := &Page[T]{Cursor: cursor, Limit: limit}
+	if err := o.filter(r.session(ctx).Model(new(T))).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	page.PageCount = int((page.Total + int64(limit) - 1) / int64(limit))
+
+	db := o.apply(r.session(ctx))
+	if cursor != "" {
+		after, err := decodePageCursor[ID](cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(clause.Gt{Column: clause.PrimaryColumn, Value: after})
+	}
+	// 多取一行用于判断是否还有下一页
+	err = db.Order(clause.OrderByColumn{Column: clause.PrimaryColumn}).Limit(limit + 1).Find(&page.Items).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Items) > limit {
+		page.Items, page.HasNext = page.Items[:limit], true
+		last, err := r.primaryKey(page.Items[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = encodePageCursor(last)
+	}
+	return page, nil
+}
+
+// encodePageCursor 游标为主键值 JSON 的 base64 编码，对客户端不透明
+func encodePageCursor(id any) string {
+	raw, _ := json.Marshal(id)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePageCursor[ID any](cursor string) (ID, error) {
+	var id ID
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return id, fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+	}
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return id, fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+	}
+	return id, nil
+}
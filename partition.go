@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PartitionStrategy 分区方式
+type PartitionStrategy string
+
+const (
+	PartitionByRange PartitionStrategy = "RANGE"
+	PartitionByHash  PartitionStrategy = "HASH"
+)
+
+// PartitionInterval 按时间范围分区时每个分区覆盖的时长
+type PartitionInterval string
+
+const (
+	PartitionDaily   PartitionInterval = "day"
+	PartitionMonthly PartitionInterval = "month"
+)
+
+// PartitionSpec 分区表定义
+type PartitionSpec struct {
+	Strategy PartitionStrategy
+	Column   string // 分区键，必须包含在主键中
+	Modulus  int    // HASH 分区的分区数
+}
+
+// CreatePartitionedTable 按模型创建分区表（已存在则跳过）；HASH 分区会同时创建全部子分区
+// RANGE 分区的子分区由 EnsureTimePartitions 按时间滚动创建
+func CreatePartitionedTable(ctx context.Context, db *gorm.DB, model any, spec PartitionSpec) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("解析模型 %T 失败: %w", model, err)
+	}
+	// PostgreSQL 要求分区表的主键/唯一约束包含分区键
+	if len(stmt.Schema.PrimaryFields) > 0 {
+		found := false
+		for _, f := range stmt.Schema.PrimaryFields {
+			found = found || f.DBName == spec.Column
+		}
+		if !found {
+			return fmt.Errorf("表 %s 的主键必须包含分区键 %s", stmt.Table, spec.Column)
+		}
+	}
+	if spec.Strategy == PartitionByHash && spec.Modulus <= 0 {
+		return errors.New("HASH 分区需要指定分区数 Modulus")
+	}
+
+	db = db.WithContext(ctx)
+	if !db.Migrator().HasTable(model) {
+		options := fmt.Sprintf("PARTITION BY %s (%s)", spec.Strategy, db.Statement.Quote(spec.Column))
+		if err := db.Set("gorm:table_options", options).Migrator().CreateTable(model); err != nil {
+			return fmt.Errorf("创建分区表 %s 失败: %w", stmt.Table, err)
+		}
+		log.Printf("分区表 %s 创建成功!", stmt.Table)
+	}
+
+	if spec.Strategy == PartitionByHash {
+		for i := 0; i < spec.Modulus; i++ {
+			child := fmt.Sprintf("%s_p%d", stmt.Table, i)
+			sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS ? PARTITION OF ? FOR VALUES WITH (MODULUS %d, REMAINDER %d)", spec.Modulus, i)
+			if err := db.Exec(sql, clause.Table{Name: child}, clause.Table{Name: stmt.Table}).Error; err != nil {
+				return fmt.Errorf("创建分区 %s 失败: %w", child, err)
+			}
+		}
+	}
+	return nil
+}
+
+// partitionStart 返回 t 所在分区的起始时间
+func (iv PartitionInterval) partitionStart(t time.Time) time.Time {
+	if iv == PartitionDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func (iv PartitionInterval) next(t time.Time) time.Time {
+	if iv == PartitionDaily {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+func (iv PartitionInterval) layout() string {
+	if iv == PartitionDaily {
+		return "20060102"
+	}
+	return "200601"
+}
+
+// partitionName 子分区命名约定：<table>_p<yyyymm> 或 <table>_p<yyyymmdd>
+func (iv PartitionInterval) partitionName(table string, start time.Time) string {
+	return table + "_p" + start.Format(iv.layout())
+}
+
+// EnsureTimePartitions 为 RANGE 分区表创建当前及未来 ahead 个时间分区（已存在的跳过）
+func EnsureTimePartitions(ctx context.Context, db *gorm.DB, model any, interval PartitionInterval, ahead int) error {
+	table, err := parseTableName(db, model)
+	if err != nil {
+		return err
+	}
+	return ensureTimePartitions(ctx, db, table, interval, ahead)
+}
+
+func ensureTimePartitions(ctx context.Context, db *gorm.DB, table string, interval PartitionInterval, ahead int) error {
+	db = db.WithContext(ctx)
+	start := interval.partitionStart(time.Now())
+	for i := 0; i <= ahead; i++ {
+		end := interval.next(start)
+		child := interval.partitionName(table, start)
+		sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS ? PARTITION OF ? FOR VALUES FROM (%s) TO (%s)",
+			quoteLiteral(start.Format(time.RFC3339)), quoteLiteral(end.Format(time.RFC3339)))
+		if err := db.Exec(sql, clause.Table{Name: child}, clause.Table{Name: table}).Error; err != nil {
+			return fmt.Errorf("创建分区 %s 失败: %w", child, err)
+		}
+		start = end
+	}
+	return nil
+}
+
+// DetachOptions 旧分区的处理方式
+type DetachOptions struct {
+	ArchiveSchema string // 非空时将分离出的分区移动到该 schema 归档
+	Drop          bool   // 为 true 时分离后直接删除（优先级低于 ArchiveSchema）
+}
+
+// DetachPartitionsBefore 分离结束时间不晚于 before 的时间分区，并按 opts 归档或删除，返回处理的分区名
+func DetachPartitionsBefore(ctx context.Context, db *gorm.DB, model any, interval PartitionInterval, before time.Time, opts DetachOptions) ([]string, error) {
+	table, err := parseTableName(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	db = db.WithContext(ctx)
+	var children []string
+	if err := db.Raw(`SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = ?::regclass ORDER BY c.relname`, table).Scan(&children).Error; err != nil {
+		return nil, fmt.Errorf("查询表 %s 的分区失败: %w", table, err)
+	}
+
+	schemaPrefix, baseName := "", table
+	if s, t, ok := strings.Cut(table, "."); ok {
+		schemaPrefix, baseName = s+".", t
+	}
+
+	var detached []string
+	for _, child := range children {
+		suffix, ok := strings.CutPrefix(child, baseName+"_p")
+		if !ok {
+			continue
+		}
+		start, err := time.ParseInLocation(interval.layout(), suffix, time.Local)
+		if err != nil || interval.next(start).After(before) {
+			continue
+		}
+
+		full := schemaPrefix + child
+		if err := db.Exec("ALTER TABLE ? DETACH PARTITION ?", clause.Table{Name: table}, clause.Table{Name: full}).Error; err != nil {
+			return detached, fmt.Errorf("分离分区 %s 失败: %w", full, err)
+		}
+		switch {
+		case opts.ArchiveSchema != "":
+			if err := db.Exec("CREATE SCHEMA IF NOT EXISTS ?", clause.Table{Name: opts.ArchiveSchema}).Error; err != nil {
+				return detached, err
+			}
+			if err := db.Exec("ALTER TABLE ? SET SCHEMA ?", clause.Table{Name: full}, clause.Table{Name: opts.ArchiveSchema}).Error; err != nil {
+				return detached, fmt.Errorf("归档分区 %s 失败: %w", full, err)
+			}
+		case opts.Drop:
+			if err := db.Exec("DROP TABLE ?", clause.Table{Name: full}).Error; err != nil {
+				return detached, fmt.Errorf("删除分区 %s 失败: %w", full, err)
+			}
+		}
+		log.Printf("分区 %s 已分离", full)
+		detached = append(detached, full)
+	}
+	return detached, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PoolAlertKind 连接池告警类型
+type PoolAlertKind string
+
+const (
+	AlertWaitCount    PoolAlertKind = "wait_count"    // 等待连接的次数增长过快
+	AlertWaitDuration PoolAlertKind = "wait_duration" // 等待连接的累计时长增长过快
+	AlertLongHeld     PoolAlertKind = "long_held"     // 连接被长事务占用，可能存在泄漏
+	AlertResized      PoolAlertKind = "resized"       // 自动调整了 MaxOpenConns
+)
+
+// PoolAlert 连接池告警
+type PoolAlert struct {
+	Kind    PoolAlertKind
+	Message string
+	Stats   sql.DBStats
+}
+
+// PoolMonitorConfig 连接池监控配置，阈值为 0 时不检查对应项
+type PoolMonitorConfig struct {
+	Interval              time.Duration // 采样间隔，默认 30s
+	WaitCountThreshold    int64         // 单个采样间隔内 WaitCount 的增量阈值
+	WaitDurationThreshold time.Duration // 单个采样间隔内 WaitDuration 的增量阈值
+	LeakThreshold         time.Duration // 事务持续时间超过该值视为疑似泄漏
+	MinOpenConns          int           // 自动调优下限，与 MaxOpenConns 同时大于 0 时启用自动调优
+	MaxOpenConns          int           // 自动调优上限
+	OnAlert               func(PoolAlert)
+}
+
+// PoolMonitor 连接池监控与自动调优
+type PoolMonitor struct {
+	db    *gorm.DB
+	sqlDB *sql.DB
+	cfg   PoolMonitorConfig
+	last  sql.DBStats
+	calm  int // 连续无等待的采样次数，用于缩容
+}
+
+// NewPoolMonitor 创建连接池监控，未设置 OnAlert 时告警写入日志
+func NewPoolMonitor(db *gorm.DB, cfg PoolMonitorConfig) (*PoolMonitor, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.OnAlert == nil {
+		cfg.OnAlert = func(a PoolAlert) { log.Printf("[连接池告警] %s: %s", a.Kind, a.Message) }
+	}
+	return &PoolMonitor{db: db, sqlDB: sqlDB, cfg: cfg, last: sqlDB.Stats()}, nil
+}
+
+// Run 周期性采样，直到 ctx 取消
+func (m *PoolMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+func (m *PoolMonitor) check(ctx context.Context) {
+	stats := m.sqlDB.Stats()
+	waitCount := stats.WaitCount - m.last.WaitCount
+	waitDuration := stats.WaitDuration - m.last.WaitDuration
+	m.last = stats
+
+	if m.cfg.WaitCountThreshold > 0 && waitCount >= m.cfg.WaitCountThreshold {
+		m.cfg.OnAlert(PoolAlert{Kind: AlertWaitCount, Stats: stats,
+			Message: fmt.Sprintf("%s 内等待连接 %d 次（使用中 %d/%d）", m.cfg.Interval, waitCount, stats.InUse, stats.MaxOpenConnections)})
+	}
+	if m.cfg.WaitDurationThreshold > 0 && waitDuration >= m.cfg.WaitDurationThreshold {
+		m.cfg.OnAlert(PoolAlert{Kind: AlertWaitDuration, Stats: stats,
+			Message: fmt.Sprintf("%s 内累计等待连接 %s", m.cfg.Interval, waitDuration)})
+	}
+
+	if m.cfg.LeakThreshold > 0 {
+		m.checkLongHeld(ctx, stats)
+	}
+	if m.cfg.MinOpenConns > 0 && m.cfg.MaxOpenConns > 0 {
+		m.tune(stats, waitCount)
+	}
+}
+
+// checkLongHeld 通过 pg_stat_activity 查找本库中持续时间过长的事务
+func (m *PoolMonitor) checkLongHeld(ctx context.Context, stats sql.DBStats) {
+	var rows []struct {
+		PID     int
+		State   string
+		Seconds float64
+		Query   string
+	}
+	err := m.db.WithContext(ctx).Raw(`SELECT pid, COALESCE(state, '') AS state,
+			EXTRACT(EPOCH FROM now() - xact_start)::float8 AS seconds, left(query, 200) AS query
+		FROM pg_stat_activity
+		WHERE datname = current_database() AND usename = current_user AND pid <> pg_backend_pid()
+			AND xact_start < now() - make_interval(secs => ?)`, m.cfg.LeakThreshold.Seconds()).Scan(&rows).Error
+	if err != nil {
+		log.Printf("查询长事务失败: %v", err)
+		return
+	}
+	for _, r := range rows {
+		m.cfg.OnAlert(PoolAlert{Kind: AlertLongHeld, Stats: stats,
+			Message: fmt.Sprintf("pid=%d state=%q 事务已持续 %.0fs，最后语句: %s", r.PID, r.State, r.Seconds, r.Query)})
+	}
+}
+
+// tune 有等待时扩容 25%，连续 10 次采样无等待且空闲连接过半时缩容 1 个，始终在 [Min, Max] 范围内
+func (m *PoolMonitor) tune(stats sql.DBStats, waitCount int64) {
+	current := stats.MaxOpenConnections
+	if current == 0 {
+		// 未限制最大连接数时不做调优
+		return
+	}
+	target := current
+	switch {
+	case waitCount > 0:
+		m.calm = 0
+		target = max(current+current/4, current+1)
+	case stats.Idle > stats.InUse:
+		m.calm++
+		if m.calm >= 10 {
+			m.calm = 0
+			target = current - 1
+		}
+	default:
+		m.calm = 0
+	}
+	target = min(max(target, m.cfg.MinOpenConns), m.cfg.MaxOpenConns)
+	if target == current {
+		return
+	}
+
+	m.sqlDB.SetMaxOpenConns(target)
+	m.cfg.OnAlert(PoolAlert{Kind: AlertResized, Stats: stats,
+		Message: fmt.Sprintf("MaxOpenConns 由 %d 调整为 %d", current, target)})
+}
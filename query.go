@@ -0,0 +1,123 @@
+package main
+
+import "gorm.io/gorm"
+
+// QueryOption 查询选项，由 GetByID/List/Find 等查询方法使用
+type QueryOption func(*queryOptions)
+
+// Spec 查询规格：一组可复用的查询选项，作为参数在 Aggregate、FindInto 等方法之间传递
+//
+//	spec := Spec{Where("age > ?", 18), OrderBy("id")}
+type Spec []QueryOption
+
+type queryOptions struct {
+	filters    []func(*gorm.DB) *gorm.DB // 过滤条件，同时作用于计数
+	joins      []string
+	preloads   []preload
+	orders     []any
+	distinct   []any
+	isDist     bool
+	limit      int
+	maxRows    int
+	sampleSeed *float64
+}
+
+type preload struct {
+	relation string
+	args     []any
+}
+
+func newQueryOptions(opts []QueryOption) *queryOptions {
+	o := &queryOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// filter 只应用影响结果集的部分（条件、JOIN），用于 Count 等不需要加载关联的场景
+func (o *queryOptions) filter(db *gorm.DB) *gorm.DB {
+	for _, f := range o.filters {
+		db = f(db)
+	}
+	for _, j := range o.joins {
+		db = db.Joins(j)
+	}
+	if o.isDist {
+		db = db.Distinct(o.distinct...)
+	}
+	return db
+}
+
+// apply 应用全部选项
+func (o *queryOptions) apply(db *gorm.DB) *gorm.DB {
+	db = o.filter(db)
+	for _, p := range o.preloads {
+		db = db.Preload(p.relation, p.args...)
+	}
+	for _, order := range o.orders {
+		db = db.Order(order)
+	}
+	if o.limit > 0 {
+		db = db.Limit(o.limit)
+	}
+	return db
+}
+
+// Where 过滤条件，参数同 gorm.DB.Where
+func Where(query any, args ...any) QueryOption {
+	return func(o *queryOptions) {
+		o.filters = append(o.filters, func(db *gorm.DB) *gorm.DB {
+			return db.Where(query, args...)
+		})
+	}
+}
+
+// OrderBy 排序，如 OrderBy("created_at DESC")
+func OrderBy(value any) QueryOption {
+	return func(o *queryOptions) {
+		o.orders = append(o.orders, value)
+	}
+}
+
+// Limit 只取前 n 行，用于 Find 按游标分批读取（不计算总数）；List/ListByCursor 中以其 limit 参数为准
+//
+//	users, err := repo.Find(ctx, Where("id > ?", after), OrderBy("id"), Limit(500))
+func Limit(n int) QueryOption {
+	return func(o *queryOptions) {
+		o.limit = n
+	}
+}
+
+// WithPreload 预加载关联（如 User has many Orders），以单独的 IN 查询批量加载，避免 N+1 查询
+func WithPreload(relations ...string) QueryOption {
+	return func(o *queryOptions) {
+		for _, r := range relations {
+			o.preloads = append(o.preloads, preload{relation: r})
+		}
+	}
+}
+
+// WithPreloadWhere 带条件的预加载，如 WithPreloadWhere("Orders", "status = ?", "paid")
+func WithPreloadWhere(relation string, args ...any) QueryOption {
+	return func(o *queryOptions) {
+		o.preloads = append(o.preloads, preload{relation: relation, args: args})
+	}
+}
+
+// WithJoins 通过 LEFT JOIN 在同一条 SQL 中加载关联（适用于 belongs to / has one）
+func WithJoins(relations ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.joins = append(o.joins, relations...)
+	}
+}
+
+// Distinct 去重查询，可指定列，如 Distinct("email")；与 Pluck 组合可获取去重后的单列值
+func Distinct(columns ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.isDist = true
+		for _, c := range columns {
+			o.distinct = append(o.distinct, c)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Range PostgreSQL 范围类型的通用表示
+// 下界/上界为无穷时对应的 LowerInf/UpperInf 为 true，此时 Lower/Upper 的值被忽略
+type Range[E any] struct {
+	Lower    E
+	Upper    E
+	LowerInf bool
+	UpperInf bool
+	LowerInc bool // 是否包含下界 "["
+	UpperInc bool // 是否包含上界 "]"
+	Empty    bool
+}
+
+// TstzRange 对应 PostgreSQL tstzrange
+type TstzRange struct {
+	Range[time.Time]
+}
+
+// DateRange 对应 PostgreSQL daterange
+type DateRange struct {
+	Range[time.Time]
+}
+
+// Int4Range 对应 PostgreSQL int4range
+type Int4Range struct {
+	Range[int32]
+}
+
+// NewTstzRange 创建左闭右开的时间范围 [from, to)
+func NewTstzRange(from, to time.Time) TstzRange {
+	return TstzRange{Range[time.Time]{Lower: from, Upper: to, LowerInc: true}}
+}
+
+// NewDateRange 创建左闭右开的日期范围 [from, to)
+func NewDateRange(from, to time.Time) DateRange {
+	return DateRange{Range[time.Time]{Lower: from, Upper: to, LowerInc: true}}
+}
+
+// NewInt4Range 创建左闭右开的整数范围 [from, to)
+func NewInt4Range(from, to int32) Int4Range {
+	return Int4Range{Range[int32]{Lower: from, Upper: to, LowerInc: true}}
+}
+
+const (
+	tstzLayout = "2006-01-02 15:04:05.999999999Z07:00"
+	dateLayout = "2006-01-02"
+)
+
+func parseTstz(s string) (time.Time, error) {
+	// PostgreSQL 输出的时区偏移可能只有小时部分，如 +08
+	for _, layout := range []string{tstzLayout, "2006-01-02 15:04:05.999999999Z07"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间戳 %q", s)
+}
+
+func parseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(dateLayout, s, time.Local)
+}
+
+func parseInt4(s string) (int32, error) {
+	n, err := strconv.ParseInt(s, 10, 32)
+	return int32(n), err
+}
+
+func formatTstz(t time.Time) string { return strconv.Quote(t.Format(tstzLayout)) }
+func formatDate(t time.Time) string { return t.Format(dateLayout) }
+func formatInt4(n int32) string     { return strconv.FormatInt(int64(n), 10) }
+
+// Scan 实现 sql.Scanner
+func (r *TstzRange) Scan(src any) error { return r.Range.scan(src, parseTstz) }
+
+// Value 实现 driver.Valuer
+func (r TstzRange) Value() (driver.Value, error) { return r.Range.format(formatTstz), nil }
+
+// GormDataType 返回数据库列类型
+func (TstzRange) GormDataType() string { return "tstzrange" }
+
+// Scan 实现 sql.Scanner
+func (r *DateRange) Scan(src any) error { return r.Range.scan(src, parseDate) }
+
+// Value 实现 driver.Valuer
+func (r DateRange) Value() (driver.Value, error) { return r.Range.format(formatDate), nil }
+
+// GormDataType 返回数据库列类型
+func (DateRange) GormDataType() string { return "daterange" }
+
+// Scan 实现 sql.Scanner
+func (r *Int4Range) Scan(src any) error { return r.Range.scan(src, parseInt4) }
+
+// Value 实现 driver.Valuer
+func (r Int4Range) Value() (driver.Value, error) { return r.Range.format(formatInt4), nil }
+
+// GormDataType 返回数据库列类型
+func (Int4Range) GormDataType() string { return "int4range" }
+
+// scan 解析 PostgreSQL 范围类型的文本格式，如 [1,10)、("2024-01-01 10:00:00+08",)、empty
+func (r *Range[E]) scan(src any, parse func(string) (E, error)) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case nil:
+		*r = Range[E]{Empty: true}
+		return nil
+	default:
+		return fmt.Errorf("不支持的范围类型源数据: %T", src)
+	}
+
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "empty") {
+		*r = Range[E]{Empty: true}
+		return nil
+	}
+	if len(s) < 3 {
+		return fmt.Errorf("无效的范围值: %q", s)
+	}
+
+	var out Range[E]
+	out.LowerInc = s[0] == '['
+	out.UpperInc = s[len(s)-1] == ']'
+	lower, upper, ok := strings.Cut(s[1:len(s)-1], ",")
+	if !ok {
+		return fmt.Errorf("无效的范围值: %q", s)
+	}
+
+	var err error
+	if lower == "" {
+		out.LowerInf = true
+	} else if out.Lower, err = parse(strings.Trim(lower, `"`)); err != nil {
+		return err
+	}
+	if upper == "" {
+		out.UpperInf = true
+	} else if out.Upper, err = parse(strings.Trim(upper, `"`)); err != nil {
+		return err
+	}
+
+	*r = out
+	return nil
+}
+
+// format 生成 PostgreSQL 范围类型的文本格式
+func (r Range[E]) format(format func(E) string) string {
+	if r.Empty {
+		return "empty"
+	}
+
+	var sb strings.Builder
+	if r.LowerInc && !r.LowerInf {
+		sb.WriteByte('[')
+	} else {
+		sb.WriteByte('(')
+	}
+	if !r.LowerInf {
+		sb.WriteString(format(r.Lower))
+	}
+	sb.WriteByte(',')
+	if !r.UpperInf {
+		sb.WriteString(format(r.Upper))
+	}
+	if r.UpperInc && !r.UpperInf {
+		sb.WriteByte(']')
+	} else {
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
+// rangeValue 本文件中的范围类型均实现该接口
+type rangeValue interface {
+	driver.Valuer
+	GormDataType() string
+}
+
+// RangeOverlaps 范围重叠条件: column && value
+func RangeOverlaps(column string, value rangeValue) clause.Expr {
+	return clause.Expr{SQL: "? && ?::" + value.GormDataType(), Vars: []any{clause.Column{Name: column}, value}}
+}
+
+// RangeContains 范围包含条件: column @> value
+func RangeContains(column string, value rangeValue) clause.Expr {
+	return clause.Expr{SQL: "? @> ?::" + value.GormDataType(), Vars: []any{clause.Column{Name: column}, value}}
+}
+
+// RangeContainsPoint 范围包含某个元素: column @> value::elemType，elemType 如 timestamptz、date、int4
+func RangeContainsPoint(column string, value any, elemType string) clause.Expr {
+	return clause.Expr{SQL: "? @> ?::" + elemType, Vars: []any{clause.Column{Name: column}, value}}
+}
+
+// ExclusionElement 排它约束中的一个元素，如 {Column: "room_id", Operator: "="}、{Column: "during", Operator: "&&"}
+type ExclusionElement struct {
+	Column   string
+	Operator string
+}
+
+// AddExclusionConstraint 为表添加 GiST 排它约束（已存在则跳过），用于防止同一资源的预订时间段重叠
+func (r *BaseRepository[T, ID]) AddExclusionConstraint(name string, elems ...ExclusionElement) error {
+	if len(elems) == 0 {
+		return errors.New("排它约束至少需要一个元素")
+	}
+
+	table, err := r.tableName()
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := r.db.Raw("SELECT count(*) FROM pg_constraint WHERE conname = ? AND conrelid = ?::regclass", name, table).
+		Scan(&count).Error; err != nil {
+		return fmt.Errorf("查询约束 %s 失败: %w", name, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	parts := make([]string, 0, len(elems))
+	needBtreeGist := false
+	for _, e := range elems {
+		// 标量列使用 = 时需要 btree_gist 扩展提供 GiST 操作符类
+		if e.Operator == "=" {
+			needBtreeGist = true
+		}
+		parts = append(parts, r.db.Statement.Quote(e.Column)+" WITH "+e.Operator)
+	}
+	if needBtreeGist {
+		if err := ensureExtension(r.db, "btree_gist"); err != nil {
+			return err
+		}
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE ? ADD CONSTRAINT ? EXCLUDE USING gist (%s)", strings.Join(parts, ", "))
+	if err := r.db.Exec(sql, clause.Table{Name: table}, clause.Column{Name: name}).Error; err != nil {
+		return fmt.Errorf("表 %s 添加排它约束 %s 失败: %w", table, name, err)
+	}
+	log.Printf("表 %s 添加排它约束 %s 成功!", table, name)
+	return nil
+}
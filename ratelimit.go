@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited 该类操作的令牌已用尽，等待超过允许的时长
+var ErrRateLimited = errors.New("操作过于频繁，请稍后重试")
+
+// MethodClass 仓库方法的开销类别，同一类别共享一个令牌桶
+type MethodClass string
+
+const (
+	ClassExport    MethodClass = "export"    // 全表导出
+	ClassAggregate MethodClass = "aggregate" // 分组统计、聚合报表
+	ClassAdHoc     MethodClass = "adhoc"     // 原生查询、EXPLAIN ANALYZE 等临时查询
+)
+
+// MethodClasses 仓库方法名 -> 开销类别，未列出的方法不限流；自定义仓库方法可在 init 中加入
+var MethodClasses = map[string]MethodClass{
+	"ExportCSV":       ClassExport,
+	"ExportJSONL":     ClassExport,
+	"Aggregate":       ClassAggregate,
+	"CountGroupBy":    ClassAggregate,
+	"CountByInterval": ClassAggregate,
+	"Stats":           ClassAggregate,
+	"QueryRaw":        ClassAdHoc,
+	"ExplainAnalyze":  ClassAdHoc,
+}
+
+// RateLimit 一个类别的令牌桶配置，配置文件中为 rate_limits 段：
+//
+//	rate_limits:
+//	  export: {rate: 0.1, burst: 2}
+//	  aggregate: {rate: 5, burst: 10, wait: 2s}
+type RateLimit struct {
+	Rate  float64       `yaml:"rate"`  // 每秒补充的令牌数，<= 0 表示不限制
+	Burst int           `yaml:"burst"` // 最多积攒的令牌数，<= 0 时为 1
+	Wait  time.Duration `yaml:"wait"`  // 令牌不足时最多等待的时长，超过则返回 ErrRateLimited；0 表示不等待
+}
+
+// RateLimitMiddleware 按 MethodClasses 的类别对开销大的操作限流，避免高峰期临时的导出、统计查询占满连接池。
+// 与 ConcurrencyLimiter 限制同时执行的语句数不同，这里限制的是单位时间内的调用次数；
+// 令牌不足时最多等待 Wait，仍不足时不执行并返回 ErrRateLimited。同一个中间件注册到多个仓库时共享令牌桶：
+//
+//	repo.Use(RateLimitMiddleware(map[MethodClass]RateLimit{ClassExport: {Rate: 0.1, Burst: 2}}))
+func RateLimitMiddleware(limits map[MethodClass]RateLimit) RepositoryMiddleware {
+	buckets := make(map[MethodClass]*tokenBucket, len(limits))
+	for class, l := range limits {
+		if l.Rate > 0 {
+			buckets[class] = newTokenBucket(l.Rate, max(l.Burst, 1), l.Wait)
+		}
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (any, error) {
+			if b := buckets[MethodClasses[op.Method]]; b != nil {
+				if err := b.take(ctx); err != nil {
+					return nil, fmt.Errorf("%s.%s（%s 类）: %w", op.Table, op.Method, MethodClasses[op.Method], err)
+				}
+			}
+			return next(ctx, op)
+		}
+	}
+}
+
+// tokenBucket 令牌桶；令牌数可以为负，表示已被等待中的调用预支
+type tokenBucket struct {
+	rate    float64
+	burst   float64
+	maxWait time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, maxWait time.Duration) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), maxWait: maxWait, tokens: float64(burst), last: time.Now()}
+}
+
+// take 取一个令牌，不足时预支并等待补足；等待时长超过 maxWait 时不预支直接返回 ErrRateLimited，ctx 取消时归还预支的令牌
+func (b *tokenBucket) take(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > b.maxWait {
+		b.mu.Unlock()
+		return ErrRateLimited
+	}
+	b.tokens--
+	b.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens = math.Min(b.burst, b.tokens+1)
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultRawTimeout 原生 SQL 的默认超时，仅在调用方 ctx 未设置截止时间时生效
+var DefaultRawTimeout = 30 * time.Second
+
+// withDefaultTimeout 为没有截止时间的 ctx 加上默认超时
+func withDefaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// QueryRaw 执行原生查询并扫描到 R 切片，适用于一次性报表等无法用 Spec 表达的查询
+// 支持位置参数（?）和命名参数（@name，参数传 sql.Named 或 map[string]any）：
+//
+//	rows, err := QueryRaw[AgeStat](ctx, repo, "SELECT age, count(*) AS total FROM users WHERE age > @min GROUP BY age",
+//		sql.Named("min", 18))
+func QueryRaw[R any, T any, ID comparable](ctx context.Context, r *BaseRepository[T, ID], sql string, args ...any) ([]R, error) {
+	return invoke(ctx, r, "QueryRaw", OpRead, func(ctx context.Context) ([]R, error) {
+		ctx, cancel := withDefaultTimeout(ctx, DefaultRawTimeout)
+		defer cancel()
+
+		var rows []R
+		if err := r.session(ctx).Raw(sql, args...).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("执行原生查询失败: %w", err)
+		}
+		return rows, nil
+	}, sql, args)
+}
+
+// ExecRaw 执行原生写语句，返回影响行数；参数规则同 QueryRaw
+func (r *BaseRepository[T, ID]) ExecRaw(ctx context.Context, sql string, args ...any) (int64, error) {
+	return invoke(ctx, r, "ExecRaw", OpWrite, func(ctx context.Context) (int64, error) { return r.execRaw(ctx, sql, args...) }, sql, args)
+}
+
+func (r *BaseRepository[T, ID]) execRaw(ctx context.Context, sql string, args ...any) (int64, error) {
+	ctx, cancel := withDefaultTimeout(ctx, DefaultRawTimeout)
+	defer cancel()
+
+	result := r.session(ctx).Exec(sql, args...)
+	if result.Error != nil {
+		return 0, fmt.Errorf("执行原生SQL失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+// ReadOnlyRepository 只读仓库：只暴露查询方法，报表等代码路径在类型层面无法调用写操作；
+// 每次查询都在 READ ONLY 事务中执行，即使通过原生 SQL 误写也会被数据库拒绝
+// db 可以指向只读副本
+type ReadOnlyRepository[T any, ID comparable] struct {
+	db *gorm.DB
+}
+
+// NewReadOnlyRepository 创建只读仓库
+func NewReadOnlyRepository[T any, ID comparable](db *gorm.DB) *ReadOnlyRepository[T, ID] {
+	return &ReadOnlyRepository[T, ID]{db: db}
+}
+
+// readOnly 在 READ ONLY 事务中执行 fn
+func (r *ReadOnlyRepository[T, ID]) readOnly(ctx context.Context, fn func(base *BaseRepository[T, ID]) error) error {
+	return sessionDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return fn(NewBaseRepository[T, ID](tx))
+	}, &sql.TxOptions{ReadOnly: true})
+}
+
+// GetByID 根据ID查询实体
+func (r *ReadOnlyRepository[T, ID]) GetByID(ctx context.Context, id ID, opts ...QueryOption) (entity *T, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		entity, err = base.GetByID(ctx, id, opts...)
+		return err
+	})
+	return entity, err
+}
+
+// GetByKey 根据（复合）主键查询实体
+func (r *ReadOnlyRepository[T, ID]) GetByKey(ctx context.Context, key map[string]any) (entity *T, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		entity, err = base.GetByKey(ctx, key)
+		return err
+	})
+	return entity, err
+}
+
+// Find 根据查询选项查询实体列表
+func (r *ReadOnlyRepository[T, ID]) Find(ctx context.Context, opts ...QueryOption) (entities []*T, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		entities, err = base.Find(ctx, opts...)
+		return err
+	})
+	return entities, err
+}
+
+// ListAll 查询所有实体
+func (r *ReadOnlyRepository[T, ID]) ListAll(ctx context.Context) (entities []*T, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		entities, err = base.ListAll(ctx)
+		return err
+	})
+	return entities, err
+}
+
+// List 分页查询，计数与查询在同一个只读事务中，结果一致
+func (r *ReadOnlyRepository[T, ID]) List(ctx context.Context, offset, limit int, opts ...QueryOption) (page *Page[T], err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		page, err = base.List(ctx, offset, limit, opts...)
+		return err
+	})
+	return page, err
+}
+
+// ListByCursor 按主键游标分页，计数与查询在同一个只读事务中，结果一致
+func (r *ReadOnlyRepository[T, ID]) ListByCursor(ctx context.Context, cursor string, limit int, opts ...QueryOption) (page *Page[T], err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		page, err = base.ListByCursor(ctx, cursor, limit, opts...)
+		return err
+	})
+	return page, err
+}
+
+// Count 查询实体总数
+func (r *ReadOnlyRepository[T, ID]) Count(ctx context.Context) (count int64, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		count, err = base.Count(ctx)
+		return err
+	})
+	return count, err
+}
+
+// CountWhere 统计满足 spec 的实体数
+func (r *ReadOnlyRepository[T, ID]) CountWhere(ctx context.Context, spec Spec) (count int64, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		count, err = base.CountWhere(ctx, spec)
+		return err
+	})
+	return count, err
+}
+
+// CountGroupBy 按列分组统计满足 spec 的实体数
+func (r *ReadOnlyRepository[T, ID]) CountGroupBy(ctx context.Context, column string, spec Spec) (counts map[string]int64, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		counts, err = base.CountGroupBy(ctx, column, spec)
+		return err
+	})
+	return counts, err
+}
+
+// Pluck 查询单列的值
+func (r *ReadOnlyRepository[T, ID]) Pluck(ctx context.Context, column string, dest any, opts ...QueryOption) error {
+	return r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		return base.Pluck(ctx, column, dest, opts...)
+	})
+}
+
+// Aggregate 分组聚合查询
+func (r *ReadOnlyRepository[T, ID]) Aggregate(ctx context.Context, agg AggregateSpec) (rows []Row, err error) {
+	err = r.readOnly(ctx, func(base *BaseRepository[T, ID]) error {
+		rows, err = base.Aggregate(ctx, agg)
+		return err
+	})
+	return rows, err
+}
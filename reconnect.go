@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ReconnectConfig 故障切换后自动重连的配置，零值字段使用默认值
+type ReconnectConfig struct {
+	Threshold    int              // Window 内出现多少次断连错误后重置连接池，默认 5
+	Window       time.Duration    // 统计窗口，默认 10s
+	Cooldown     time.Duration    // 两次重置的最小间隔，默认 30s
+	PingAttempts int              // 重置后 Ping 的最大次数，间隔逐次翻倍（从 200ms 开始），默认 10
+	OnReset      func(ResetEvent) // 每次重置结束后调用，可用于告警或刷新缓存
+}
+
+// ResetEvent 一次连接池重置的结果
+type ResetEvent struct {
+	Errors    int           // 触发重置的断连错误数
+	LastError error         // 最后一个断连错误
+	Closed    int           // 关闭的空闲连接数
+	Recovered bool          // Ping 是否成功
+	Attempts  int           // Ping 次数
+	Duration  time.Duration // 从开始重置到 Ping 成功（或放弃）的耗时
+}
+
+// Reconnector 监测"连接被重置""服务端关闭"等错误的集中出现：数据库重启或主备切换后，连接池中的旧连接会逐个报错，
+// 达到阈值时一次性关闭全部空闲连接并 Ping 到恢复为止，此后新建的连接（多节点配置时）落到新主库
+type Reconnector struct {
+	cfg ReconnectConfig
+	db  *gorm.DB
+
+	mu        sync.Mutex
+	errors    []time.Time
+	lastReset time.Time
+	resetting bool
+}
+
+// NewReconnector 创建重连器，通过 WithReconnect 或 RegisterReconnector 挂载到 *gorm.DB
+func NewReconnector(cfg ReconnectConfig) *Reconnector {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 5
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+	if cfg.PingAttempts <= 0 {
+		cfg.PingAttempts = 10
+	}
+	return &Reconnector{cfg: cfg}
+}
+
+// record 记录语句的结果，断连错误达到阈值时在后台重置连接池
+func (r *Reconnector) record(err error) {
+	if !isConnectionLost(err) {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cutoff := now.Add(-r.cfg.Window)
+	kept := r.errors[:0]
+	for _, t := range r.errors {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.errors = append(kept, now)
+	if len(r.errors) < r.cfg.Threshold || r.resetting || now.Sub(r.lastReset) < r.cfg.Cooldown {
+		return
+	}
+	count := len(r.errors)
+	r.errors, r.resetting, r.lastReset = nil, true, now
+	go r.run(count, err)
+}
+
+func (r *Reconnector) run(count int, lastErr error) {
+	defer func() {
+		r.mu.Lock()
+		r.resetting = false
+		r.mu.Unlock()
+	}()
+	log.Printf("%s 内出现 %d 次断连错误，重置连接池: %v", r.cfg.Window, count, lastErr)
+	start := time.Now()
+	ev := ResetEvent{Errors: count, LastError: lastErr, Closed: resetPool(r.db)}
+
+	backoff := 200 * time.Millisecond
+	for ev.Attempts < r.cfg.PingAttempts && !ev.Recovered {
+		ev.Attempts++
+		ev.Recovered = r.ping() == nil
+		if !ev.Recovered && ev.Attempts < r.cfg.PingAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	ev.Duration = time.Since(start)
+	if ev.Recovered {
+		log.Printf("连接池重置完成，第 %d 次 Ping 成功，耗时 %s", ev.Attempts, ev.Duration)
+	} else {
+		log.Printf("连接池重置后 %d 次 Ping 均失败，等待下一次重置", ev.Attempts)
+	}
+	if r.cfg.OnReset != nil {
+		r.cfg.OnReset(ev)
+	}
+}
+
+func (r *Reconnector) ping() error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// resetPool 关闭全部空闲连接（pgxpool 模式下关闭全部连接），使用中的连接归还时若已断开会被丢弃，返回关闭的空闲连接数
+func resetPool(db *gorm.DB) int {
+	if pgxPool != nil {
+		closed := int(pgxPool.Stat().IdleConns())
+		pgxPool.Reset()
+		return closed
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0
+	}
+	maxIdle := 2 // database/sql 的默认值
+	if n, ok := poolMaxIdle.Load(sqlDB); ok {
+		maxIdle = n.(int)
+	}
+	idle := sqlDB.Stats().Idle
+	// database/sql 没有直接关闭空闲连接的方法，临时把空闲上限设为 0 再恢复
+	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	return idle
+}
+
+// poolMaxIdle 各 database/sql 连接池的空闲连接上限（*sql.DB -> int），database/sql 不提供读取方法，
+// 经 setMaxIdleConns 设置时记录，供 resetPool 重置后恢复；按连接池分别记录，多个连接与并发的热加载互不影响
+var poolMaxIdle sync.Map
+
+// setMaxIdleConns 设置并记录连接池的空闲连接上限
+func setMaxIdleConns(sqlDB *sql.DB, n int) {
+	sqlDB.SetMaxIdleConns(n)
+	poolMaxIdle.Store(sqlDB, n)
+}
+
+// isConnectionLost 错误是否表明连接已失效：isUnavailable 中除超时与资源不足（53，重置连接池无济于事）之外的错误
+func isConnectionLost(err error) bool {
+	if !isUnavailable(err) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return !strings.HasPrefix(pgErr.Code, "53")
+	}
+	var netErr net.Error
+	return !errors.As(err, &netErr) || !netErr.Timeout()
+}
+
+// RegisterReconnector 在所有 gorm 处理器之后记录语句错误
+func RegisterReconnector(db *gorm.DB, r *Reconnector) error {
+	r.db = db
+	return registerAround(callbackPoints(db, "*"), callbackHook{}, callbackHook{"app:reconnect_record", func(db *gorm.DB) {
+		if !db.DryRun {
+			r.record(db.Error)
+		}
+	}})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cacheInvalidateFunction 触发器函数：行被更新/删除时向 TG_ARGV[0] 频道发送 "表名:主键"
+// 主键列名通过 TG_ARGV[1] 传入，用 to_jsonb(OLD) 取值以兼容任意主键类型
+const cacheInvalidateFunction = `CREATE OR REPLACE FUNCTION app_cache_invalidate() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify(TG_ARGV[0], TG_TABLE_NAME || ':' || (to_jsonb(OLD) ->> TG_ARGV[1]));
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql`
+
+// redisCacheSet 版本键的值与回源前读到的一致（都不存在也算一致）时才写入缓存，期间被失效过的旧行不会写回
+var redisCacheSet = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '') ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1`)
+
+// redisCacheVersionTTL 版本键的保留时长；回源超过该时长时不写入缓存，版本键过期后重新出现也不会误判为一致
+const redisCacheVersionTTL = 10 * time.Minute
+
+// RedisCache 基于 Redis 的二级缓存，多个服务实例共享；失效由数据库触发器 + LISTEN/NOTIFY 驱动，
+// 因此其他服务直接写表时缓存同样能保持一致。使用前需调用 InstallCacheInvalidation 安装触发器，
+// 并在后台运行 Listen（事务池化模式下不可用）。失效时同时改写版本键，回源期间被失效的旧行不会写回缓存
+type RedisCache[T any, ID comparable] struct {
+	base    *BaseRepository[T, ID]
+	client  redis.UniversalClient
+	prefix  string
+	ttl     time.Duration
+	channel string
+}
+
+// NewRedisCache 创建 Redis 缓存，prefix 为键前缀（如 "app:"），ttl 兜底过期时间，channel 为失效通知频道
+func NewRedisCache[T any, ID comparable](base *BaseRepository[T, ID], client redis.UniversalClient, prefix string, ttl time.Duration, channel string) *RedisCache[T, ID] {
+	return &RedisCache[T, ID]{base: base, client: client, prefix: prefix, ttl: ttl, channel: channel}
+}
+
+// GetByID 根据ID查询实体，优先读 Redis；Redis 不可用时降级为直接查库
+func (c *RedisCache[T, ID]) GetByID(ctx context.Context, id ID) (*T, error) {
+	key, err := c.key(fmt.Sprint(id))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.client.Get(ctx, key).Bytes()
+	switch {
+	case err == nil:
+		var entity T
+		if err := json.Unmarshal(data, &entity); err == nil {
+			return &entity, nil
+		}
+		log.Printf("缓存 %s 反序列化失败，回源查询: %v", key, err)
+	case !errors.Is(err, redis.Nil):
+		log.Printf("读取缓存 %s 失败，回源查询: %v", key, err)
+	}
+
+	// 回源前记下版本，查库期间到达的失效会改写版本，写回时据此放弃，避免旧行在 TTL 内一直有效
+	start := time.Now()
+	version, err := c.client.Get(ctx, versionKey(key)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("读取缓存版本 %s 失败，不写入缓存: %v", key, err)
+	}
+	cacheable := err == nil || errors.Is(err, redis.Nil)
+
+	entity, err := c.base.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !cacheable || time.Since(start) >= redisCacheVersionTTL {
+		return entity, nil
+	}
+	if data, err := json.Marshal(entity); err == nil {
+		keys := []string{key, versionKey(key)}
+		if err := redisCacheSet.Run(ctx, c.client, keys, version, data, c.ttl.Milliseconds()).Err(); err != nil {
+			log.Printf("写入缓存 %s 失败: %v", key, err)
+		}
+	}
+	return entity, nil
+}
+
+// Invalidate 删除指定主键的缓存
+func (c *RedisCache[T, ID]) Invalidate(ctx context.Context, id ID) error {
+	key, err := c.key(fmt.Sprint(id))
+	if err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+// invalidate 删除缓存并把版本键改为新的随机值，正在回源的 GetByID 不再写回
+func (c *RedisCache[T, ID]) invalidate(ctx context.Context, key string) error {
+	version := fmt.Sprintf("%d-%x", time.Now().UnixNano(), rand.Uint64())
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, versionKey(key), version, redisCacheVersionTTL)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	return err
+}
+
+// Listen 监听失效通知并删除对应缓存，直到 ctx 取消；同一频道上其他表的通知会被忽略
+// 每个服务实例运行一个即可，多个实例重复删除同一个键是无害的
+func (c *RedisCache[T, ID]) Listen(ctx context.Context) error {
+	_, table, err := splitTableName(c.base.db, new(T))
+	if err != nil {
+		return err
+	}
+	return Listen(ctx, c.base.db, c.channel, func(payload string) {
+		t, id, ok := strings.Cut(payload, ":")
+		if !ok || t != table {
+			return
+		}
+		key, err := c.key(id)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if err := c.invalidate(ctx, key); err != nil {
+			log.Printf("删除缓存 %s 失败: %v", key, err)
+		}
+	})
+}
+
+// key 缓存键：前缀 + {表名:主键}，与触发器发送的通知内容对应；花括号为 Redis Cluster 的 hash tag，
+// 缓存键与版本键落在同一个槽，可在一个脚本中同时访问
+func (c *RedisCache[T, ID]) key(id string) (string, error) {
+	_, table, err := splitTableName(c.base.db, new(T))
+	if err != nil {
+		return "", err
+	}
+	return c.prefix + "{" + table + ":" + id + "}", nil
+}
+
+// versionKey 缓存键对应的版本键，每次失效时改写
+func versionKey(key string) string {
+	return key + ":v"
+}
+
+// InstallCacheInvalidation 为模型对应的表安装失效触发器：行被 UPDATE/DELETE 后（事务提交时）向 channel 发送通知
+// 重复执行是安全的；只支持单列主键
+func InstallCacheInvalidation(ctx context.Context, db *gorm.DB, model any, channel string) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("解析模型 %T 失败: %w", model, err)
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return fmt.Errorf("表 %s 没有单列主键，无法安装缓存失效触发器", stmt.Schema.Table)
+	}
+	table, err := parseTableName(db, model)
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(cacheInvalidateFunction).Error; err != nil {
+			return fmt.Errorf("创建缓存失效函数失败: %w", err)
+		}
+		if err := tx.Exec("DROP TRIGGER IF EXISTS app_cache_invalidate ON ?", clause.Table{Name: table}).Error; err != nil {
+			return err
+		}
+		sql := fmt.Sprintf("CREATE TRIGGER app_cache_invalidate AFTER UPDATE OR DELETE ON ? FOR EACH ROW EXECUTE FUNCTION app_cache_invalidate(%s, %s)",
+			quoteLiteral(channel), quoteLiteral(stmt.Schema.PrioritizedPrimaryField.DBName))
+		if err := tx.Exec(sql, clause.Table{Name: table}).Error; err != nil {
+			return fmt.Errorf("表 %s 创建缓存失效触发器失败: %w", table, err)
+		}
+		log.Printf("表 %s 缓存失效触发器安装成功!", table)
+		return nil
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ModelOptions RegisterModel 的选项
+type ModelOptions struct {
+	// DependsOn 需要先迁移的模型；belongs-to 关系（本模型持有外键）与 has-one/has-many 关系会自动推断，无需列出
+	DependsOn []any
+	// Before 在建表前执行，如创建枚举类型、扩展
+	Before func(ctx context.Context, db *gorm.DB) error
+	// After 在建表及生成列、部分唯一索引、CHECK 约束迁移之后执行，如建分区、物化视图
+	After func(ctx context.Context, db *gorm.DB) error
+	// AfterDrop 在 DropAll 删除表之后执行，如删除枚举类型
+	AfterDrop func(ctx context.Context, db *gorm.DB) error
+}
+
+type registeredModel struct {
+	model any
+	opts  ModelOptions
+}
+
+// modelRegistry RegisterModel 注册的模型，按注册顺序保存
+var modelRegistry struct {
+	mu     sync.Mutex
+	models []registeredModel
+}
+
+// RegisterModel 注册由 MigrateAll 管理的模型，一般在模型所在文件的 init 中调用；同一类型重复注册时覆盖选项
+//
+//	func init() {
+//		RegisterModel(&Order{}, ModelOptions{DependsOn: []any{&User{}}})
+//	}
+func RegisterModel(model any, opts ModelOptions) {
+	modelRegistry.mu.Lock()
+	defer modelRegistry.mu.Unlock()
+	for i, m := range modelRegistry.models {
+		if reflect.TypeOf(m.model) == reflect.TypeOf(model) {
+			modelRegistry.models[i].opts = opts
+			return
+		}
+	}
+	modelRegistry.models = append(modelRegistry.models, registeredModel{model: model, opts: opts})
+}
+
+// RegisteredModels 按依赖顺序返回已注册的模型（被依赖的在前），无依赖关系的保持注册顺序；存在循环依赖时返回错误
+func RegisteredModels(db *gorm.DB) ([]any, error) {
+	ordered, err := orderedModels(db)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]any, len(ordered))
+	for i, m := range ordered {
+		models[i] = m.model
+	}
+	return models, nil
+}
+
+// MigrateAll 按依赖顺序迁移全部已注册的模型：Before、AutoMigrate、生成列、软删除部分唯一索引、CHECK 约束、After
+func MigrateAll(ctx context.Context, db *gorm.DB) error {
+	ordered, err := orderedModels(db)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	for _, m := range ordered {
+		if m.opts.Before != nil {
+			if err := m.opts.Before(ctx, db); err != nil {
+				return fmt.Errorf("迁移 %T 前置步骤失败: %w", m.model, err)
+			}
+		}
+		if err := db.AutoMigrate(m.model); err != nil {
+			return fmt.Errorf("表 %T 自动迁移失败: %w", m.model, err)
+		}
+		if err := MigrateGeneratedColumns(ctx, db, m.model); err != nil {
+			return err
+		}
+		if err := MigrateSoftDeleteUniques(ctx, db, m.model); err != nil {
+			return err
+		}
+		if err := MigrateValidateChecks(ctx, db, m.model); err != nil {
+			return err
+		}
+		if m.opts.After != nil {
+			if err := m.opts.After(ctx, db); err != nil {
+				return fmt.Errorf("迁移 %T 后置步骤失败: %w", m.model, err)
+			}
+		}
+		log.Printf("表 %T 迁移完成", m.model)
+	}
+	return nil
+}
+
+// DropAll 按依赖的逆序删除全部已注册模型的表（数据不可恢复）
+func DropAll(ctx context.Context, db *gorm.DB) error {
+	ordered, err := orderedModels(db)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	for _, m := range slices.Backward(ordered) {
+		if err := db.Migrator().DropTable(m.model); err != nil {
+			return fmt.Errorf("删除表 %T 失败: %w", m.model, err)
+		}
+		if m.opts.AfterDrop != nil {
+			if err := m.opts.AfterDrop(ctx, db); err != nil {
+				return fmt.Errorf("删除 %T 后置步骤失败: %w", m.model, err)
+			}
+		}
+	}
+	return nil
+}
+
+// orderedModels 按表名建立依赖图并拓扑排序，每轮取注册顺序中最靠前的、依赖均已就绪的模型
+func orderedModels(db *gorm.DB) ([]registeredModel, error) {
+	modelRegistry.mu.Lock()
+	models := slices.Clone(modelRegistry.models)
+	modelRegistry.mu.Unlock()
+
+	tables := make([]string, len(models))
+	schemas := make([]*schema.Schema, len(models))
+	index := make(map[string]int, len(models))
+	for i, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m.model); err != nil {
+			return nil, fmt.Errorf("解析模型 %T 失败: %w", m.model, err)
+		}
+		tables[i], schemas[i] = stmt.Table, stmt.Schema
+		index[stmt.Table] = i
+	}
+
+	deps := make([]map[int]bool, len(models))
+	for i := range deps {
+		deps[i] = map[int]bool{}
+	}
+	addDep := func(from int, table string) {
+		if to, ok := index[table]; ok && to != from {
+			deps[from][to] = true
+		}
+	}
+	for i, m := range models {
+		for _, dep := range m.opts.DependsOn {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(dep); err != nil {
+				return nil, fmt.Errorf("解析模型 %T 的依赖 %T 失败: %w", m.model, dep, err)
+			}
+			if _, ok := index[stmt.Table]; !ok {
+				return nil, fmt.Errorf("模型 %T 依赖的 %T 未注册", m.model, dep)
+			}
+			addDep(i, stmt.Table)
+		}
+		rels := schemas[i].Relationships
+		for _, rel := range rels.BelongsTo {
+			addDep(i, rel.FieldSchema.Table)
+		}
+		for _, rel := range slices.Concat(rels.HasOne, rels.HasMany) {
+			if j, ok := index[rel.FieldSchema.Table]; ok {
+				addDep(j, tables[i])
+			}
+		}
+	}
+
+	done := make([]bool, len(models))
+	ordered := make([]registeredModel, 0, len(models))
+	for len(ordered) < len(models) {
+		next := -1
+		for i := range models {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for d := range deps[i] {
+				if !done[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i := range models {
+				if !done[i] {
+					cycle = append(cycle, tables[i])
+				}
+			}
+			return nil, fmt.Errorf("模型之间存在循环依赖: %s", strings.Join(cycle, ", "))
+		}
+		done[next] = true
+		ordered = append(ordered, models[next])
+	}
+	return ordered, nil
+}
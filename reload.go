@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ReloadableLogger 可在运行时修改级别与慢 SQL 阈值的 gorm logger，LoggingConfig.Logger 创建的即是它
+type ReloadableLogger struct {
+	writer logger.Writer
+
+	mu    sync.RWMutex
+	cfg   logger.Config
+	inner logger.Interface
+}
+
+// NewReloadableLogger 以 gorm 默认格式输出到 writer
+func NewReloadableLogger(writer logger.Writer, cfg logger.Config) *ReloadableLogger {
+	return &ReloadableLogger{writer: writer, cfg: cfg, inner: logger.New(writer, cfg)}
+}
+
+// Config 当前配置
+func (l *ReloadableLogger) Config() logger.Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cfg
+}
+
+// Update 以 fn 修改配置，之后的日志立即按新配置输出
+func (l *ReloadableLogger) Update(fn func(cfg *logger.Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fn(&l.cfg)
+	l.inner = logger.New(l.writer, l.cfg)
+}
+
+func (l *ReloadableLogger) current() logger.Interface {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.inner
+}
+
+// LogMode 返回固定级别的副本（如 db.Debug()），不影响 l 本身
+func (l *ReloadableLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return l.current().LogMode(level)
+}
+
+func (l *ReloadableLogger) Info(ctx context.Context, msg string, args ...any) {
+	l.current().Info(ctx, msg, args...)
+}
+
+func (l *ReloadableLogger) Warn(ctx context.Context, msg string, args ...any) {
+	l.current().Warn(ctx, msg, args...)
+}
+
+func (l *ReloadableLogger) Error(ctx context.Context, msg string, args ...any) {
+	l.current().Error(ctx, msg, args...)
+}
+
+func (l *ReloadableLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.current().Trace(ctx, begin, fc, err)
+}
+
+// RuntimeSettings 无需重启即可生效的设置，零值表示不修改
+type RuntimeSettings struct {
+	LogLevel      string        // silent/error/warn/info
+	SlowThreshold time.Duration // 慢 SQL 阈值
+	MaxOpenConns  int
+	MaxIdleConns  int
+}
+
+// runtimeSettings 取配置中可热加载的部分
+func (cfg *AppConfig) runtimeSettings() RuntimeSettings {
+	return RuntimeSettings{
+		LogLevel:      cfg.Logging.Level,
+		SlowThreshold: cfg.Logging.SlowThreshold,
+		MaxOpenConns:  cfg.Database.MaxOpenConns,
+		MaxIdleConns:  cfg.Database.MaxIdleConns,
+	}
+}
+
+// ApplyRuntimeSettings 把 s 中的非零项应用到 db：日志设置要求 db 以 ReloadableLogger 创建；
+// 连接池大小只对 database/sql 连接池生效，UsePgxPool 模式下 pgxpool 的容量创建后不可修改，需重启
+func ApplyRuntimeSettings(db *gorm.DB, s RuntimeSettings) error {
+	if s.LogLevel != "" || s.SlowThreshold > 0 {
+		rl, ok := db.Config.Logger.(*ReloadableLogger)
+		if !ok {
+			return errors.New("日志设置需要以 ReloadableLogger 创建数据库连接")
+		}
+		level, err := parseLogLevel(s.LogLevel)
+		if err != nil {
+			return err
+		}
+		rl.Update(func(cfg *logger.Config) {
+			if s.LogLevel != "" && cfg.LogLevel != level {
+				log.Printf("SQL 日志级别调整为 %s", s.LogLevel)
+				cfg.LogLevel = level
+			}
+			if s.SlowThreshold > 0 && cfg.SlowThreshold != s.SlowThreshold {
+				log.Printf("慢 SQL 阈值调整为 %s", s.SlowThreshold)
+				cfg.SlowThreshold = s.SlowThreshold
+			}
+		})
+	}
+
+	if s.MaxOpenConns <= 0 && s.MaxIdleConns <= 0 {
+		return nil
+	}
+	if pgxPool != nil {
+		log.Printf("pgxpool 模式下连接池大小需重启后生效")
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	stats := sqlDB.Stats()
+	if s.MaxOpenConns > 0 && stats.MaxOpenConnections != s.MaxOpenConns {
+		sqlDB.SetMaxOpenConns(s.MaxOpenConns)
+		log.Printf("MaxOpenConns 由 %d 调整为 %d", stats.MaxOpenConnections, s.MaxOpenConns)
+	}
+	if s.MaxIdleConns > 0 {
+		setMaxIdleConns(sqlDB, s.MaxIdleConns)
+	}
+	return nil
+}
+
+// WatchConfig 每隔 interval（默认 5s）检查配置文件，修改时间变化后重新加载 profile 并应用其中可热加载的设置，
+// 直到 ctx 取消；加载失败时保留当前设置并记录日志。其余配置（地址、账号等）修改后仍需重启。
+// logLevel 为命令行指定的日志级别，非空时配置文件中的级别不生效；SetLogLevelFor 的临时级别生效期间也不覆盖，到期后恢复为配置文件中的级别
+func WatchConfig(ctx context.Context, db *gorm.DB, path, profile, logLevel string, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	var modTime time.Time
+	if fi, err := os.Stat(path); err == nil {
+		modTime = fi.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil || fi.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = fi.ModTime()
+		reloadConfig(db, path, profile, logLevel)
+	}
+}
+
+// reloadConfig 重新加载配置并应用可热加载的设置，失败时保留当前设置；logLevel 见 WatchConfig
+func reloadConfig(db *gorm.DB, path, profile, logLevel string) {
+	cfg, err := LoadConfig(path, profile)
+	if err != nil {
+		log.Printf("重新加载配置失败，保留当前设置: %v", err)
+		return
+	}
+	s := cfg.runtimeSettings()
+	if logLevel != "" || deferLogLevel(s.LogLevel) {
+		s.LogLevel = ""
+	}
+	if err := ApplyRuntimeSettings(db, s); err != nil {
+		log.Printf("应用配置 %s 失败: %v", path, err)
+	}
+}
+
+// settingsPayload 设置通知的 JSON 格式，如 {"log_level": "info", "slow_threshold": "500ms", "max_open_conns": 50}
+type settingsPayload struct {
+	LogLevel      string `json:"log_level"`
+	SlowThreshold string `json:"slow_threshold"`
+	MaxOpenConns  int    `json:"max_open_conns"`
+	MaxIdleConns  int    `json:"max_idle_conns"`
+}
+
+// ListenSettings 监听 channel，收到 JSON 通知时应用其中的设置，直到 ctx 取消。多实例部署时一条通知即可调整所有实例：
+//
+//	SELECT pg_notify('app_settings', '{"log_level": "info"}');
+func ListenSettings(ctx context.Context, db *gorm.DB, channel string) error {
+	return Listen(ctx, db, channel, func(payload string) {
+		s, err := parseSettingsPayload(payload)
+		if err == nil {
+			err = ApplyRuntimeSettings(db, s)
+		}
+		if err != nil {
+			log.Printf("频道 %s 的设置通知无效: %v", channel, err)
+		}
+	})
+}
+
+func parseSettingsPayload(payload string) (RuntimeSettings, error) {
+	var p settingsPayload
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return RuntimeSettings{}, err
+	}
+	s := RuntimeSettings{LogLevel: p.LogLevel, MaxOpenConns: p.MaxOpenConns, MaxIdleConns: p.MaxIdleConns}
+	if p.SlowThreshold != "" {
+		d, err := time.ParseDuration(p.SlowThreshold)
+		if err != nil {
+			return s, fmt.Errorf("slow_threshold: %w", err)
+		}
+		s.SlowThreshold = d
+	}
+	return s, nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// replicaRouter 配置了 Replicas 时的读写分离路由，未配置时为 nil，Close 时关闭
+var replicaRouter *ReplicaRouter
+
+// ReplicaRouter 读写分离：事务外由子句构建的查询轮流发往只读副本，写操作、事务、原生 SQL、SELECT ... FOR UPDATE 以及 ctx 要求一致性的读发往主库。
+// 副本通过流复制异步追赶主库，刚写入的数据可能还读不到，以 ConsistencyToken 保证"读到自己的写"：
+//
+//	tok, _ := CurrentConsistencyToken(ctx, db) // 写操作之后，在主库上取当前 WAL 位置
+//	ctx = WithConsistency(ctx, tok)            // 之后的读只发往已回放到该位置的副本，都没追上时读主库
+//
+// HTTP 服务由 ConsistencyMiddleware 自动完成这两步
+type ReplicaRouter struct {
+	primary  *gorm.DB
+	replicas []*replica
+	window   time.Duration
+	next     atomic.Uint64
+}
+
+type replica struct {
+	host string
+	db   *sql.DB
+	pool *pgxpool.Pool // UsePgxPool 时管理 db 的连接，否则为 nil
+
+	ejected atomic.Bool // 延迟过大被 ReplicaLagMonitor 移出读轮换
+
+	mu        sync.Mutex
+	replayLSN uint64 // 最近一次查询到的回放位置，只增不减
+	status    ReplicaStatus
+}
+
+// newReplicaRouter 以主库的连接参数（账号、库名、SSL、会话参数、SSH 隧道等）连接 cfg.Replicas 中的每个副本，
+// 连接经与主库相同的 openConnPool 打开，池化模式、语句缓存与 pgxpool 等设置一致
+func newReplicaRouter(ctx context.Context, cfg *PostgresConfig, tunnel *ssh.Client) (*ReplicaRouter, error) {
+	window := cfg.ReplicaConsistencyWindow
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	r := &ReplicaRouter{window: window}
+	for _, host := range cfg.Replicas {
+		rc := *cfg
+		rc.URL, rc.Host, rc.Hosts, rc.TargetSessionAttrs = "", "", []string{host}, ""
+		db, pool, err := openConnPool(ctx, rc.DSN(), &rc, tunnel)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("连接副本 %s 失败: %w", host, err)
+		}
+		rc.configurePool(db)
+		r.replicas = append(r.replicas, &replica{host: host, db: db, pool: pool})
+	}
+	return r, nil
+}
+
+// Close 关闭全部副本连接
+func (r *ReplicaRouter) Close() error {
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+		if rep.pool != nil {
+			rep.pool.Close()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reader 为 ctx 选择副本，跳过已移出读轮换的副本，应读主库时返回 nil
+func (r *ReplicaRouter) reader(ctx context.Context) *replica {
+	o := sessionFrom(ctx)
+	if o.primary || len(r.replicas) == 0 {
+		return nil
+	}
+	var (
+		lsn      uint64
+		checkLSN bool
+	)
+	// 超出一致性窗口的令牌不再检查，副本的延迟由 ReplicaLagMonitor 保证在窗口之内
+	if tok := o.consistency; tok != nil && time.Since(tok.At) < r.window {
+		v, err := parseLSN(tok.LSN)
+		if err != nil {
+			// 只有时间没有 WAL 位置的令牌：窗口内一律读主库
+			return nil
+		}
+		lsn, checkLSN = v, true
+	}
+	start := int(r.next.Add(1))
+	for i := range r.replicas {
+		rep := r.replicas[(start+i)%len(r.replicas)]
+		if rep.ejected.Load() {
+			continue
+		}
+		if !checkLSN || rep.caughtUp(ctx, lsn) {
+			return rep
+		}
+	}
+	return nil
+}
+
+// caughtUp 副本是否已回放到 lsn，缓存的位置不够时重新查询；查询期间不持有锁，不阻塞其他读该副本的请求
+func (rep *replica) caughtUp(ctx context.Context, lsn uint64) bool {
+	rep.mu.Lock()
+	replayed := rep.replayLSN
+	rep.mu.Unlock()
+	if replayed >= lsn {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	var s sql.NullString
+	if err := rep.db.QueryRowContext(ctx, "SELECT pg_last_wal_replay_lsn()::text").Scan(&s); err != nil {
+		log.Printf("查询副本 %s 的回放位置失败: %v", rep.host, err)
+		return false
+	}
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if v, err := parseLSN(s.String); err == nil && v > rep.replayLSN {
+		rep.replayLSN = v
+	}
+	return rep.replayLSN >= lsn
+}
+
+// ConsistencyToken 一致性令牌：写操作之后主库的 WAL 位置与取得的时间，文本形式为 <LSN>@<毫秒时间戳>，可放在 Cookie 或请求头中
+type ConsistencyToken struct {
+	LSN string // 如 16/B374D848，为空时表示窗口内只读主库
+	At  time.Time
+}
+
+func (t ConsistencyToken) String() string {
+	return t.LSN + "@" + strconv.FormatInt(t.At.UnixMilli(), 10)
+}
+
+// ParseConsistencyToken 解析 ConsistencyToken.String 的结果
+func ParseConsistencyToken(s string) (ConsistencyToken, error) {
+	lsn, ms, ok := strings.Cut(s, "@")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if !ok || err != nil {
+		return ConsistencyToken{}, fmt.Errorf("一致性令牌格式错误: %q", s)
+	}
+	if _, err := parseLSN(lsn); lsn != "" && err != nil {
+		return ConsistencyToken{}, err
+	}
+	return ConsistencyToken{LSN: lsn, At: time.UnixMilli(n)}, nil
+}
+
+// CurrentConsistencyToken 在主库上取当前的 WAL 写入位置，应在写事务提交之后调用
+func CurrentConsistencyToken(ctx context.Context, db *gorm.DB) (ConsistencyToken, error) {
+	var lsn string
+	if err := db.WithContext(ReadFromPrimary(ctx)).Raw("SELECT pg_current_wal_lsn()::text").Scan(&lsn).Error; err != nil {
+		return ConsistencyToken{}, fmt.Errorf("查询主库 WAL 位置失败: %w", err)
+	}
+	return ConsistencyToken{LSN: lsn, At: time.Now()}, nil
+}
+
+// WithConsistency 之后的读只发往已回放到 tok 的副本，都没追上时读主库
+func WithConsistency(ctx context.Context, tok ConsistencyToken) context.Context {
+	o := sessionFrom(ctx)
+	o.consistency = &tok
+	return context.WithValue(ctx, sessionKey{}, o)
+}
+
+// ReadFromPrimary 之后的读全部发往主库，用于读取后立即据此写入等不能容忍延迟的场景
+func ReadFromPrimary(ctx context.Context) context.Context {
+	o := sessionFrom(ctx)
+	o.primary = true
+	return context.WithValue(ctx, sessionKey{}, o)
+}
+
+// parseLSN 把 pg_lsn 的文本形式 X/Y 转换为可比较的整数
+func parseLSN(s string) (uint64, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	h, err1 := strconv.ParseUint(hi, 16, 32)
+	l, err2 := strconv.ParseUint(lo, 16, 32)
+	if !ok || err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("无效的 WAL 位置: %q", s)
+	}
+	return h<<32 | l, nil
+}
+
+const replicaPoolKey = "app:replica_pool"
+
+// RegisterReplicaRouter 在 gorm 的查询处理器前切换到副本连接，处理器执行后恢复，不影响同一会话之后的写操作。
+// 只路由由子句构建的 SELECT（Find、First、Count 等）且不带 FOR UPDATE 等锁定子句；原生 SQL（Raw、Row、Rows、Scan）
+// 可能是 UPDATE ... RETURNING、SELECT ... FOR UPDATE 或修改数据的 CTE，一律发往主库
+func RegisterReplicaRouter(db *gorm.DB, r *ReplicaRouter) error {
+	r.primary = db
+	route := func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+			return
+		}
+		if _, locking := db.Statement.Clauses["FOR"]; locking || db.Statement.SQL.Len() > 0 {
+			return
+		}
+		if rep := r.reader(db.Statement.Context); rep != nil {
+			db.InstanceSet(replicaPoolKey, db.Statement.ConnPool)
+			db.Statement.ConnPool = rep.db
+		}
+	}
+	restore := func(db *gorm.DB) {
+		if pool, ok := db.InstanceGet(replicaPoolKey); ok {
+			db.Statement.ConnPool = pool.(gorm.ConnPool)
+		}
+	}
+	return registerAround(callbackPoints(db, "gorm:query", "query"),
+		callbackHook{"app:replica_route", route}, callbackHook{"app:replica_restore", restore})
+}
+
+// ConsistencyHeader 携带一致性令牌的请求头与响应头，浏览器客户端使用 Cookie consistency_token
+const ConsistencyHeader = "X-Consistency-Token"
+
+const consistencyCookie = "consistency_token"
+
+// ConsistencyMiddleware 请求携带令牌时按令牌路由读操作；写请求（非 GET/HEAD/OPTIONS）成功时，
+// 在响应头写出之前取主库当前位置，以响应头和 Cookie 返回新令牌，同一用户随后的读取能看到刚才的修改
+func ConsistencyMiddleware(r *ReplicaRouter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(ConsistencyHeader)
+		if raw == "" {
+			raw, _ = c.Cookie(consistencyCookie)
+		}
+		if tok, err := ParseConsistencyToken(raw); err == nil {
+			c.Request = c.Request.WithContext(WithConsistency(c.Request.Context(), tok))
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			c.Writer = &consistencyWriter{ResponseWriter: c.Writer, ctx: c.Request.Context(), router: r}
+		}
+		c.Next()
+	}
+}
+
+// consistencyWriter 在响应头发出前追加一致性令牌
+type consistencyWriter struct {
+	gin.ResponseWriter
+	ctx    context.Context
+	router *ReplicaRouter
+	once   sync.Once
+}
+
+func (w *consistencyWriter) issue(status int) {
+	w.once.Do(func() {
+		if status >= http.StatusBadRequest {
+			return
+		}
+		tok, err := CurrentConsistencyToken(w.ctx, w.router.primary)
+		if err != nil {
+			// 取不到位置时退化为窗口内读主库
+			log.Printf("生成一致性令牌失败: %v", err)
+			tok = ConsistencyToken{At: time.Now()}
+		}
+		w.Header().Set(ConsistencyHeader, tok.String())
+		http.SetCookie(w, &http.Cookie{Name: consistencyCookie, Value: tok.String(), Path: "/",
+			MaxAge: int(w.router.window.Seconds()), HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	})
+}
+
+func (w *consistencyWriter) WriteHeader(code int) {
+	w.issue(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *consistencyWriter) WriteHeaderNow() {
+	w.issue(w.Status())
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *consistencyWriter) Write(data []byte) (int, error) {
+	w.issue(w.Status())
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *consistencyWriter) WriteString(s string) (int, error) {
+	w.issue(w.Status())
+	return w.ResponseWriter.WriteString(s)
+}
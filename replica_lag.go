@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReplicaLagConfig 副本延迟监控配置，零值字段使用默认值
+type ReplicaLagConfig struct {
+	Interval time.Duration // 采样间隔，默认 5s
+	MaxLag   time.Duration // 回放延迟超过该值（或查询失败）时移出读轮换，默认等于一致性窗口
+	// RecoverLag 已移出的副本延迟降到该值以下才放回，避免在阈值附近反复进出，默认 MaxLag 的一半
+	RecoverLag time.Duration
+	OnChange   func(ReplicaLagEvent) // 副本移出或放回时调用，未设置时写入日志
+}
+
+// ReplicaLagEvent 副本移出或放回读轮换
+type ReplicaLagEvent struct {
+	Host    string
+	Ejected bool // true 为移出，false 为放回
+	Lag     time.Duration
+	Err     error // 查询失败导致移出时的错误
+}
+
+// ReplicaStatus 副本最近一次采样的结果
+type ReplicaStatus struct {
+	Host      string        `json:"host"`
+	Lag       time.Duration `json:"lag"` // 回放延迟，已回放全部已接收的 WAL 时为 0
+	ReplayLSN string        `json:"replay_lsn"`
+	InService bool          `json:"in_service"` // 是否在读轮换中
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// ReplicaLagMonitor 周期性测量每个副本的回放延迟，超过 MaxLag 的副本不再接收查询，
+// 全部副本都被移出时查询回到主库
+type ReplicaLagMonitor struct {
+	router *ReplicaRouter
+	cfg    ReplicaLagConfig
+}
+
+// NewReplicaLagMonitor 创建副本延迟监控，调用 Run 后开始采样
+func NewReplicaLagMonitor(r *ReplicaRouter, cfg ReplicaLagConfig) *ReplicaLagMonitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.MaxLag <= 0 {
+		cfg.MaxLag = r.window
+	}
+	if cfg.RecoverLag <= 0 || cfg.RecoverLag > cfg.MaxLag {
+		cfg.RecoverLag = cfg.MaxLag / 2
+	}
+	if cfg.OnChange == nil {
+		cfg.OnChange = func(e ReplicaLagEvent) {
+			switch {
+			case !e.Ejected:
+				log.Printf("[副本延迟] %s 已追上（%s），放回读轮换", e.Host, e.Lag)
+			case e.Err != nil:
+				log.Printf("[副本延迟] %s 不可用，移出读轮换: %v", e.Host, e.Err)
+			default:
+				log.Printf("[副本延迟] %s 延迟 %s，移出读轮换", e.Host, e.Lag)
+			}
+		}
+	}
+	return &ReplicaLagMonitor{router: r, cfg: cfg}
+}
+
+// Run 立即采样一次，之后周期性采样，直到 ctx 取消
+func (m *ReplicaLagMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *ReplicaLagMonitor) check(ctx context.Context) {
+	for _, rep := range m.router.replicas {
+		lag, lsn, err := rep.measureLag(ctx, m.cfg.Interval)
+		if ctx.Err() != nil {
+			return
+		}
+		rep.mu.Lock()
+		rep.status = ReplicaStatus{Host: rep.host, Lag: lag, ReplayLSN: lsn, CheckedAt: time.Now()}
+		if err != nil {
+			rep.status.Error = err.Error()
+		}
+		if v, err := parseLSN(lsn); err == nil && v > rep.replayLSN {
+			rep.replayLSN = v
+		}
+		rep.mu.Unlock()
+
+		ejected := rep.ejected.Load()
+		switch {
+		case !ejected && (err != nil || lag > m.cfg.MaxLag):
+			rep.ejected.Store(true)
+			m.cfg.OnChange(ReplicaLagEvent{Host: rep.host, Ejected: true, Lag: lag, Err: err})
+		case ejected && err == nil && lag <= m.cfg.RecoverLag:
+			rep.ejected.Store(false)
+			m.cfg.OnChange(ReplicaLagEvent{Host: rep.host, Lag: lag})
+		}
+	}
+}
+
+// measureLag 查询副本的回放延迟：已接收的 WAL 全部回放完时为 0（主库空闲时 pg_last_xact_replay_timestamp 不再更新，
+// 直接相减会误报），否则为当前时间与最后回放的事务提交时间之差。已提升为主库的节点延迟为 0
+func (rep *replica) measureLag(ctx context.Context, timeout time.Duration) (time.Duration, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var (
+		seconds float64
+		lsn     sql.NullString
+	)
+	err := rep.db.QueryRowContext(ctx, `SELECT
+		CASE WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		     ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END,
+		pg_last_wal_replay_lsn()::text`).Scan(&seconds, &lsn)
+	if err != nil {
+		return 0, "", fmt.Errorf("查询回放延迟失败: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), lsn.String, nil
+}
+
+// Status 返回每个副本最近一次采样的结果，可导出为监控指标；未启动 ReplicaLagMonitor 时只有 Host 与 InService
+func (r *ReplicaRouter) Status() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, len(r.replicas))
+	for i, rep := range r.replicas {
+		rep.mu.Lock()
+		statuses[i] = rep.status
+		rep.mu.Unlock()
+		statuses[i].Host = rep.host
+		statuses[i].InService = !rep.ejected.Load()
+	}
+	return statuses
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReportOptions 报表的执行限制与缓存，零值字段使用默认值
+type ReportOptions struct {
+	Params   []string       // 允许的参数名，SQL 中以 @name 引用；传入未声明的参数时报错，避免拼写错误被静默忽略
+	Timeout  time.Duration  // 执行超时，默认 DefaultRawTimeout；与调用方 ctx 的截止时间取较早者
+	MaxRows  int            // 最多返回的行数，超出时返回 ErrTooManyRows，默认 10000
+	CacheTTL time.Duration  // 结果缓存时长，0 表示不缓存
+	CacheMax int            // 最多缓存的参数组合数，默认 100
+	Tables   map[string]any // SQL 中以 {{.tables.<name>}} 引用的模型，按 db 的命名策略解析为带 schema 前缀、加引号的表名
+}
+
+// reportTablesKey 模板数据中表名所在的键，不能用作参数名
+const reportTablesKey = "tables"
+
+// Report 具名报表：SQL 模板 + 参数 + 输出结构 R，取代散落在各处经 GetDB 执行的原生查询。
+// SQL 先按 text/template 以参数渲染（只用于可选条件等结构变化），值一律以 @name 命名参数绑定，不拼入 SQL；
+// 查询在只读事务中执行，超时、行数上限与结果缓存按报表配置；行数上限以 LIMIT 追加在 SQL 末尾，SQL 本身不应以 LIMIT 结尾：
+//
+//	type MonthlySignups struct {
+//		Month time.Time
+//		Users int64
+//	}
+//	report, err := NewReport[MonthlySignups]("signups_by_month", `SELECT date_trunc('month', created_at) AS month,
+//		COUNT(*) AS users FROM {{.tables.users}} {{if .status}}WHERE status = @status{{end}} GROUP BY 1 ORDER BY 1`,
+//		ReportOptions{Params: []string{"status"}, Tables: map[string]any{"users": &User{}}, Timeout: time.Minute, CacheTTL: 10 * time.Minute})
+//	rows, err := report.Run(ctx, db, map[string]any{"status": UserStatusActive})
+type Report[R any] struct {
+	name string
+	tmpl *template.Template
+	opts ReportOptions
+
+	mu     sync.Mutex
+	cache  *lruCache[string, []R]
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewReport 创建报表并解析 SQL 模板
+func NewReport[R any](name, sqlTemplate string, opts ReportOptions) (*Report[R], error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(sqlTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("解析报表 %s 的 SQL 模板失败: %w", name, err)
+	}
+	if slices.Contains(opts.Params, reportTablesKey) {
+		return nil, fmt.Errorf("报表 %s 的参数名 %s 已保留给表名", name, reportTablesKey)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultRawTimeout
+	}
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = 10000
+	}
+	if opts.CacheMax <= 0 {
+		opts.CacheMax = 100
+	}
+	r := &Report[R]{name: name, tmpl: tmpl, opts: opts}
+	if opts.CacheTTL > 0 {
+		r.cache = newLRUCache[string, []R](opts.CacheMax, opts.CacheTTL, nil)
+	}
+	return r, nil
+}
+
+// Name 报表名称
+func (r *Report[R]) Name() string { return r.name }
+
+// Run 以 params 执行报表；配置了缓存时相同参数在 CacheTTL 内直接返回缓存的结果（调用方不应修改返回的切片）
+func (r *Report[R]) Run(ctx context.Context, db *gorm.DB, params map[string]any) ([]R, error) {
+	if params == nil {
+		params = map[string]any{}
+	}
+	for name := range params {
+		if !slices.Contains(r.opts.Params, name) {
+			return nil, fmt.Errorf("报表 %s 没有参数 %s", r.name, name)
+		}
+	}
+	tables, err := r.tableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	data := maps.Clone(params)
+	data[reportTablesKey] = tables
+	// 表名随 db 的命名策略变化，一并计入缓存键
+	key, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("编码报表 %s 的参数失败: %w", r.name, err)
+	}
+	if rows, ok := r.cached(string(key)); ok {
+		return rows, nil
+	}
+
+	var b strings.Builder
+	if err := r.tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("渲染报表 %s 的 SQL 失败: %w", r.name, err)
+	}
+	args := make([]any, 0, len(params)+1)
+	for name, v := range params {
+		args = append(args, sql.Named(name, v))
+	}
+	// 多取一行判断是否超出上限；LIMIT 直接追加在报表 SQL 之后，包一层子查询会丢失 ORDER BY 的顺序保证
+	query := strings.TrimRight(strings.TrimSpace(b.String()), ";") + " LIMIT @report_limit"
+	args = append(args, sql.Named("report_limit", r.opts.MaxRows+1))
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	ctx = WithComment(ctx, "report:"+r.name)
+	var rows []R
+	scan := func(tx *gorm.DB) error {
+		return tx.Raw(query, args...).Scan(&rows).Error
+	}
+	if inTransaction(db) {
+		err = scan(sessionDB(ctx, db))
+	} else {
+		err = Transaction(ctx, db, scan, ReadOnly())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("执行报表 %s 失败: %w", r.name, err)
+	}
+	if len(rows) > r.opts.MaxRows {
+		return nil, fmt.Errorf("报表 %s 超过 %d 行: %w", r.name, r.opts.MaxRows, ErrTooManyRows)
+	}
+
+	if r.cache != nil {
+		r.mu.Lock()
+		r.cache.put(string(key), rows)
+		r.mu.Unlock()
+	}
+	return rows, nil
+}
+
+// tableNames 按 db 的命名策略解析 Tables 中模型的表名并加引号
+func (r *Report[R]) tableNames(db *gorm.DB) (map[string]string, error) {
+	tables := make(map[string]string, len(r.opts.Tables))
+	for name, model := range r.opts.Tables {
+		table, err := parseTableName(db, model)
+		if err != nil {
+			return nil, fmt.Errorf("解析报表 %s 的表 %s 失败: %w", r.name, name, err)
+		}
+		tables[name] = quoteQualified(table)
+	}
+	return tables, nil
+}
+
+func (r *Report[R]) cached(key string) ([]R, bool) {
+	if r.cache == nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, ok := r.cache.get(key)
+	if ok {
+		r.hits.Add(1)
+	} else {
+		r.misses.Add(1)
+	}
+	return rows, ok
+}
+
+// Invalidate 清空缓存的结果，如数据修复后需要立即看到新结果
+func (r *Report[R]) Invalidate() {
+	if r.cache == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = newLRUCache[string, []R](r.opts.CacheMax, r.opts.CacheTTL, nil)
+}
+
+// Stats 返回缓存命中统计
+func (r *Report[R]) Stats() CacheStats {
+	s := CacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+	if r.cache != nil {
+		r.mu.Lock()
+		s.Size = r.cache.len()
+		r.mu.Unlock()
+	}
+	return s
+}
+
+// RegisterReport 将报表注册为同名的异步查询（见 RegisterQuery），耗时长的报表可经 JobQueue 提交后轮询结果
+func RegisterReport[R any](r *Report[R]) {
+	RegisterQuery(r.name, func(ctx context.Context, db *gorm.DB, params map[string]any) (any, error) {
+		return r.Run(ctx, db, params)
+	})
+}
+
+// UserStatusCount 报表 users_by_status 的结果行
+type UserStatusCount struct {
+	Status UserStatus `json:"status"`
+	Users  int64      `json:"users"`
+}
+
+// UsersByStatusReport 按状态统计未删除的用户数，可选参数 since 只统计此后注册的用户
+var UsersByStatusReport = mustReport(NewReport[UserStatusCount]("users_by_status",
+	`SELECT status, COUNT(*) AS users FROM {{.tables.users}}
+	WHERE deleted_at IS NULL {{if .since}}AND created_at >= @since{{end}}
+	GROUP BY status ORDER BY status`,
+	ReportOptions{Params: []string{"since"}, Tables: map[string]any{"users": &User{}}, CacheTTL: time.Minute}))
+
+func init() {
+	RegisterReport(UsersByStatusReport)
+}
+
+func mustReport[R any](r *Report[R], err error) *Report[R] {
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
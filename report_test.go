@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"postgresql-test/dbtest"
+)
+
+// 配置了 schema 时表名带前缀 "<schema>."，报表 SQL 须经命名策略解析表名，不能依赖 search_path
+func TestReportSchemaPrefix(t *testing.T) {
+	dbtest.WithRollback(t, testDB(t), func(tx *gorm.DB) {
+		ctx := context.Background()
+		var schemaName string
+		if err := tx.Raw("SELECT current_schema()").Scan(&schemaName).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Create(&User{Name: "alice", Email: "alice@example.com", Age: 30}).Error; err != nil {
+			t.Fatal(err)
+		}
+		// search_path 只剩 public，未带前缀的 users 会报 relation does not exist
+		if err := tx.Exec("SET LOCAL search_path = public").Error; err != nil {
+			t.Fatal(err)
+		}
+		prefixed, err := OpenWithDialector(postgres.New(postgres.Config{Conn: tx.Statement.ConnPool}),
+			WithNamingStrategy(schema.NamingStrategy{TablePrefix: schemaName + "."}),
+			WithGormConfig(&gorm.Config{SkipDefaultTransaction: true}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := UsersByStatusReport.Run(ctx, prefixed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Status != UserStatusActive || rows[0].Users != 1 {
+			t.Errorf("报表结果 %+v，期望 1 个 %s 用户", rows, UserStatusActive)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"postgresql-test/dbtest"
+)
+
+func TestUserRepositoryCreate(t *testing.T) {
+	withRollback(t, func(repo UserRepository) {
+		ctx := context.Background()
+		user := &User{Name: "zhangsan", Email: "ZhangSan@Example.com", Age: 25}
+		if err := repo.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		// 邮箱为 citext，查询忽略大小写
+		got, err := repo.GetByEmail(ctx, "zhangsan@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != user.ID {
+			t.Errorf("GetByEmail 返回 ID %d，期望 %d", got.ID, user.ID)
+		}
+	})
+}
+
+func TestLoadFixtures(t *testing.T) {
+	dbtest.WithRollback(t, testDB(t), func(tx *gorm.DB) {
+		fixtures := mustLoadFixtures(t, tx, "testdata/fixtures")
+		bob := Fixture[User](fixtures, "users", "bob")
+		if bob == nil || bob.ID == 0 {
+			t.Fatalf("夹具 users.bob 未插入: %+v", bob)
+		}
+		got, err := NewUserRepository(tx).GetByID(context.Background(), bob.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != UserStatusDisabled {
+			t.Errorf("users.bob 状态为 %q，期望 %q", got.Status, UserStatusDisabled)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// RetentionPolicy 单个模型的软删除保留策略：DeletedAt 早于 Retention 之前的行被永久删除
+// Archive 为 true 时删除前写入归档表（见 Archive.EnsureTable），删除与归档在同一条语句中完成
+type RetentionPolicy struct {
+	Model     any
+	Retention time.Duration
+	Archive   bool
+}
+
+// RetentionConfig 清理任务配置
+type RetentionConfig struct {
+	Interval   time.Duration // 清理间隔，默认 1h
+	BatchSize  int           // 每批删除的行数，默认 1000；每批单独提交，避免长时间持有行锁
+	BatchPause time.Duration // 批次之间的停顿，降低对主库与复制的压力
+}
+
+// RetentionStats 清理统计
+type RetentionStats struct {
+	Runs      uint64           `json:"runs"`
+	Purged    map[string]int64 `json:"purged"` // 按表累计永久删除的行数
+	LastRun   time.Time        `json:"last_run"`
+	LastError string           `json:"last_error,omitempty"`
+}
+
+type retentionTarget struct {
+	table     string
+	deletedAt string
+	pk        []string
+	retention time.Duration
+	columns   []string // 归档时复制的列，为空表示不归档
+}
+
+// RetentionWorker 软删除数据的定期清理任务
+type RetentionWorker struct {
+	db      *gorm.DB
+	cfg     RetentionConfig
+	targets []retentionTarget
+
+	mu    sync.Mutex
+	stats RetentionStats
+}
+
+// NewRetentionWorker 创建清理任务，模型必须包含 gorm.DeletedAt 字段与主键
+func NewRetentionWorker(db *gorm.DB, cfg RetentionConfig, policies ...RetentionPolicy) (*RetentionWorker, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	w := &RetentionWorker{db: db, cfg: cfg, stats: RetentionStats{Purged: map[string]int64{}}}
+	for _, p := range policies {
+		if p.Retention <= 0 {
+			return nil, fmt.Errorf("模型 %T 的保留时长必须大于 0", p.Model)
+		}
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(p.Model); err != nil {
+			return nil, fmt.Errorf("解析模型 %T 失败: %w", p.Model, err)
+		}
+		f := softDeleteField(stmt.Schema)
+		if f == nil {
+			return nil, fmt.Errorf("模型 %T 没有 gorm.DeletedAt 字段", p.Model)
+		}
+		t := retentionTarget{table: stmt.Table, deletedAt: f.DBName, retention: p.Retention}
+		for _, f := range stmt.Schema.PrimaryFields {
+			t.pk = append(t.pk, pgx.Identifier{f.DBName}.Sanitize())
+		}
+		if len(t.pk) == 0 {
+			return nil, fmt.Errorf("模型 %T 没有主键", p.Model)
+		}
+		if p.Archive {
+			t.columns = quoteColumns(stmt.Schema.DBNames)
+		}
+		w.targets = append(w.targets, t)
+	}
+	return w, nil
+}
+
+// Run 按间隔执行清理，直到 ctx 取消
+func (w *RetentionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.PurgeOnce(ctx); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// PurgeOnce 对全部模型执行一轮清理，返回本轮各表删除的行数；单表失败不影响其余表
+func (w *RetentionWorker) PurgeOnce(ctx context.Context) (map[string]int64, error) {
+	purged := make(map[string]int64, len(w.targets))
+	var errs []error
+	for _, t := range w.targets {
+		n, err := w.purge(ctx, t)
+		purged[t.table] = n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("清理表 %s 失败: %w", t.table, err))
+		}
+		if n > 0 {
+			log.Printf("表 %s 永久删除 %d 行软删除数据", t.table, n)
+		}
+	}
+	err := errors.Join(errs...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.Runs++
+	w.stats.LastRun = time.Now()
+	w.stats.LastError = ""
+	if err != nil {
+		w.stats.LastError = err.Error()
+	}
+	for table, n := range purged {
+		w.stats.Purged[table] += n
+	}
+	return purged, err
+}
+
+// purge 按主键分批删除（需要归档时同时写入归档表），每批一条语句（自动提交），直到不足一批
+func (w *RetentionWorker) purge(ctx context.Context, t retentionTarget) (int64, error) {
+	cutoff := time.Now().Add(-t.retention)
+	key := strings.Join(t.pk, ", ")
+	table := quoteQualified(t.table)
+	deletedAt := pgx.Identifier{t.deletedAt}.Sanitize()
+	where := fmt.Sprintf("(%s) IN (SELECT %s FROM %s WHERE %s < ? LIMIT ?)", key, key, table, deletedAt)
+	sql := "DELETE FROM " + table + " WHERE " + where
+	if len(t.columns) > 0 {
+		sql = archiveMoveSQL(t.table, t.table+ArchiveSuffix, t.columns, where)
+	}
+
+	var total int64
+	for {
+		res := sessionDB(ctx, w.db).Exec(sql, cutoff, w.cfg.BatchSize)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(w.cfg.BatchSize) {
+			return total, nil
+		}
+		if w.cfg.BatchPause > 0 {
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(w.cfg.BatchPause):
+			}
+		}
+	}
+}
+
+// Stats 返回累计清理统计
+func (w *RetentionWorker) Stats() RetentionStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.stats
+	s.Purged = maps.Clone(w.stats.Purged)
+	return s
+}
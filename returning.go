@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+
+	"gorm.io/gorm/clause"
+)
+
+// CreateReturning 创建实体并通过 RETURNING * 回填全部列，数据库计算的默认值、生成列等无需再次查询
+func (r *BaseRepository[T, ID]) CreateReturning(ctx context.Context, entity *T) error {
+	return r.run(ctx, "CreateReturning", OpWrite, func(ctx context.Context) error { return r.createReturning(ctx, entity) }, entity)
+}
+
+func (r *BaseRepository[T, ID]) createReturning(ctx context.Context, entity *T) error {
+	if err := r.session(ctx).Clauses(clause.Returning{}).Create(entity).Error; err != nil {
+		return err
+	}
+	r.hooks.fire(ctx, &r.hooks.created, entity)
+	return nil
+}
+
+// UpdateReturning 更新实体并回填更新后的全部列（触发器修改的值、生成列等）
+func (r *BaseRepository[T, ID]) UpdateReturning(ctx context.Context, entity *T) error {
+	return r.run(ctx, "UpdateReturning", OpWrite, func(ctx context.Context) error { return r.updateReturning(ctx, entity) }, entity)
+}
+
+func (r *BaseRepository[T, ID]) updateReturning(ctx context.Context, entity *T) error {
+	if err := r.session(ctx).Clauses(clause.Returning{}).Save(entity).Error; err != nil {
+		return err
+	}
+	r.hooks.fire(ctx, &r.hooks.updated, entity)
+	return nil
+}
+
+// UpdateWhereReturning 同 UpdateWhere，返回更新后的行；返回了实体，因此会触发更新事件回调
+func (r *BaseRepository[T, ID]) UpdateWhereReturning(ctx context.Context, spec Spec, fields map[string]any) ([]*T, error) {
+	return invoke(ctx, r, "UpdateWhereReturning", OpWrite, func(ctx context.Context) ([]*T, error) { return r.updateWhereReturning(ctx, spec, fields) }, spec, fields)
+}
+
+func (r *BaseRepository[T, ID]) updateWhereReturning(ctx context.Context, spec Spec, fields map[string]any) ([]*T, error) {
+	o := newQueryOptions(spec)
+	if len(o.filters) == 0 {
+		return nil, ErrEmptySpec
+	}
+	var entities []*T
+	if err := o.filter(r.session(ctx).Model(&entities)).Clauses(clause.Returning{}).Updates(fields).Error; err != nil {
+		return nil, err
+	}
+	r.hooks.fire(ctx, &r.hooks.updated, entities...)
+	return entities, nil
+}
+
+// DeleteWhereReturning 同 DeleteWhere，返回被删除（软删除时为删除后）的行，并触发删除事件回调
+func (r *BaseRepository[T, ID]) DeleteWhereReturning(ctx context.Context, spec Spec) ([]*T, error) {
+	return invoke(ctx, r, "DeleteWhereReturning", OpWrite, func(ctx context.Context) ([]*T, error) { return r.deleteWhereReturning(ctx, spec) }, spec)
+}
+
+func (r *BaseRepository[T, ID]) deleteWhereReturning(ctx context.Context, spec Spec) ([]*T, error) {
+	o := newQueryOptions(spec)
+	if len(o.filters) == 0 {
+		return nil, ErrEmptySpec
+	}
+	var entities []*T
+	if err := o.filter(r.session(ctx)).Clauses(clause.Returning{}).Delete(&entities).Error; err != nil {
+		return nil, err
+	}
+	r.hooks.fire(ctx, &r.hooks.deleted, entities...)
+	return entities, nil
+}
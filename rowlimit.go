@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// ErrTooManyRows 查询结果超过 RowLimit/MaxRows 设置的行数上限
+var ErrTooManyRows = errors.New("查询结果行数超过上限")
+
+// RowLimit ListAll/Find 的结果行数上限，防止过滤条件意外失效时把整张表读入内存
+type RowLimit struct {
+	Max      int  // 单次查询最多返回的行数，0 表示不限制
+	Truncate bool // 为 true 时截断到 Max 行并记录日志，否则返回 ErrTooManyRows
+}
+
+// SetRowLimit 设置 ListAll/Find 的行数上限，应在装配仓库时调用；单次查询可用 MaxRows 覆盖 Max
+func (r *BaseRepository[T, ID]) SetRowLimit(l RowLimit) {
+	r.rowLimit = l
+}
+
+// MaxRows 本次查询的行数上限，覆盖仓库 RowLimit 的 Max，超出时的处理方式仍由 RowLimit.Truncate 决定
+//
+//	users, err := repo.Find(ctx, Where("status = ?", "active"), MaxRows(10000))
+func MaxRows(n int) QueryOption {
+	return func(o *queryOptions) {
+		o.maxRows = n
+	}
+}
+
+// findLimited 执行查询，有上限时多取一行判断是否超出，内存中最多保留 max+1 行
+func (r *BaseRepository[T, ID]) findLimited(db *gorm.DB, maxRows int) ([]*T, error) {
+	if maxRows <= 0 {
+		maxRows = r.rowLimit.Max
+	}
+	var entities []*T
+	if maxRows <= 0 {
+		err := db.Find(&entities).Error
+		return entities, err
+	}
+
+	if err := db.Limit(maxRows + 1).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	if len(entities) <= maxRows {
+		return entities, nil
+	}
+	table, _ := r.tableName()
+	if !r.rowLimit.Truncate {
+		return nil, fmt.Errorf("%w: 表 %s 的查询超过 %d 行，请增加过滤条件或改用分页", ErrTooManyRows, table, maxRows)
+	}
+	log.Printf("表 %s 的查询超过 %d 行，结果已截断", table, maxRows)
+	return entities[:maxRows], nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SampleMethod 表采样方式
+type SampleMethod string
+
+const (
+	// SampleSystem 按数据页采样，只读取选中的页，代价与采样比例成正比，但同一页的行会一起出现
+	SampleSystem SampleMethod = "SYSTEM"
+	// SampleBernoulli 逐行采样，分布更均匀，但需要扫描全表
+	SampleBernoulli SampleMethod = "BERNOULLI"
+)
+
+// Sample 以 TABLESAMPLE SYSTEM 随机取约 percent%（0-100）的行，适合在大表上做分析抽样或冒烟测试；
+// 返回的行数是近似值，过滤条件在采样之后生效。受 SetRowLimit/MaxRows 设置的行数上限约束
+func (r *BaseRepository[T, ID]) Sample(ctx context.Context, percent float64, opts ...QueryOption) ([]*T, error) {
+	return r.SampleBy(ctx, SampleSystem, percent, opts...)
+}
+
+// SampleBy 按指定方式采样，配合 SampleSeed 可得到可重复的结果
+func (r *BaseRepository[T, ID]) SampleBy(ctx context.Context, method SampleMethod, percent float64, opts ...QueryOption) ([]*T, error) {
+	return invoke(ctx, r, "SampleBy", OpRead, func(ctx context.Context) ([]*T, error) { return r.sampleBy(ctx, method, percent, opts...) }, method, percent, opts)
+}
+
+func (r *BaseRepository[T, ID]) sampleBy(ctx context.Context, method SampleMethod, percent float64, opts ...QueryOption) ([]*T, error) {
+	if method != SampleSystem && method != SampleBernoulli {
+		return nil, fmt.Errorf("不支持的采样方式: %s", method)
+	}
+	if percent <= 0 || percent > 100 {
+		return nil, fmt.Errorf("采样比例必须在 (0, 100] 之间: %v", percent)
+	}
+	_, table, err := splitTableName(r.db, new(T))
+	if err != nil {
+		return nil, err
+	}
+	full, err := r.tableName()
+	if err != nil {
+		return nil, err
+	}
+
+	o := newQueryOptions(opts)
+	from := fmt.Sprintf("%s AS %s TABLESAMPLE %s (?)", quoteQualified(full), pgx.Identifier{table}.Sanitize(), method)
+	args := []any{percent}
+	if o.sampleSeed != nil {
+		from += " REPEATABLE (?)"
+		args = append(args, *o.sampleSeed)
+	}
+	db := r.session(ctx).Table(from, args...)
+	// 软删除等条件以别名引用表
+	db.Statement.Table = table
+	return r.findLimited(o.apply(db), o.maxRows)
+}
+
+// SampleSeed 采样种子，相同种子在表数据不变时返回相同的样本
+func SampleSeed(seed float64) QueryOption {
+	return func(o *queryOptions) {
+		o.sampleSeed = &seed
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DriftKind 表结构与模型不一致的类型
+type DriftKind string
+
+const (
+	DriftMissingTable  DriftKind = "missing_table"  // 表不存在
+	DriftMissingColumn DriftKind = "missing_column" // 模型中的列在表中不存在
+	DriftExtraColumn   DriftKind = "extra_column"   // 表中有模型没有的列（写入时被忽略，NOT NULL 且无默认值时插入会失败）
+	DriftType          DriftKind = "type"           // 列类型或长度不同
+	DriftNullable      DriftKind = "nullable"       // 可空性不同
+	DriftMissingIndex  DriftKind = "missing_index"  // 模型声明的索引不存在
+	DriftIndex         DriftKind = "index"          // 索引的列或唯一性不同
+)
+
+// SchemaDrift 一处表结构与模型定义的差异
+type SchemaDrift struct {
+	Table    string
+	Kind     DriftKind
+	Name     string // 列名或索引名
+	Expected string
+	Actual   string
+}
+
+func (d SchemaDrift) String() string {
+	s := fmt.Sprintf("%s: %s %s", d.Table, d.Kind, d.Name)
+	if d.Expected != "" || d.Actual != "" {
+		s += fmt.Sprintf("（模型: %s，数据库: %s）", d.Expected, d.Actual)
+	}
+	return s
+}
+
+// SchemaDriftError CheckSchema 在 SchemaCheckFail 模式下发现差异时返回
+type SchemaDriftError struct {
+	Drifts []SchemaDrift
+}
+
+func (e *SchemaDriftError) Error() string {
+	lines := make([]string, len(e.Drifts))
+	for i, d := range e.Drifts {
+		lines[i] = d.String()
+	}
+	return fmt.Sprintf("表结构与模型定义不一致（%d 处）:\n  %s", len(e.Drifts), strings.Join(lines, "\n  "))
+}
+
+// SchemaCheckMode 启动时发现表结构差异的处理方式
+type SchemaCheckMode string
+
+const (
+	SchemaCheckOff  SchemaCheckMode = "off"  // 不检查
+	SchemaCheckWarn SchemaCheckMode = "warn" // 记录日志后继续
+	SchemaCheckFail SchemaCheckMode = "fail" // 返回 *SchemaDriftError，拒绝启动
+)
+
+// CheckSchema 启动时调用：按 mode 校验 models 的表结构，mode 为空时同 warn
+func CheckSchema(ctx context.Context, db *gorm.DB, mode SchemaCheckMode, models ...any) error {
+	switch mode {
+	case SchemaCheckOff:
+		return nil
+	case "", SchemaCheckWarn, SchemaCheckFail:
+	default:
+		return fmt.Errorf("未知的表结构检查模式: %s", mode)
+	}
+	drifts, err := ValidateSchema(ctx, db, models...)
+	if err != nil || len(drifts) == 0 {
+		return err
+	}
+	if mode == SchemaCheckFail {
+		return &SchemaDriftError{Drifts: drifts}
+	}
+	for _, d := range drifts {
+		log.Printf("[表结构差异] %s", d)
+	}
+	return nil
+}
+
+// ValidateSchema 比较 models 的定义与数据库中实际的列（类型、长度、可空性）和索引（列、唯一性），返回全部差异，
+// 用于发现被手工修改过的环境。数据库中多出的索引不算差异；表达式索引只比较唯一性
+func ValidateSchema(ctx context.Context, db *gorm.DB, models ...any) ([]SchemaDrift, error) {
+	db = db.WithContext(ctx)
+	var drifts []SchemaDrift
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("解析模型 %T 失败: %w", model, err)
+		}
+		d, err := validateTable(db, model, stmt)
+		if err != nil {
+			return nil, fmt.Errorf("检查表 %s 失败: %w", stmt.Table, err)
+		}
+		drifts = append(drifts, d...)
+	}
+	return drifts, nil
+}
+
+func validateTable(db *gorm.DB, model any, stmt *gorm.Statement) ([]SchemaDrift, error) {
+	table := stmt.Table
+	m := db.Migrator()
+	if !m.HasTable(model) {
+		return []SchemaDrift{{Table: table, Kind: DriftMissingTable, Name: table}}, nil
+	}
+	columnTypes, err := m.ColumnTypes(model)
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, c := range columnTypes {
+		actual[c.Name()] = c
+	}
+
+	var drifts []SchemaDrift
+	for _, name := range stmt.Schema.DBNames {
+		f := stmt.Schema.FieldsByDBName[name]
+		if f.IgnoreMigration {
+			continue
+		}
+		c, ok := actual[name]
+		if !ok {
+			drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftMissingColumn, Name: name})
+			continue
+		}
+		delete(actual, name)
+
+		expectedType, expectedLen := normalizeColumnType(db.Dialector.DataTypeOf(f))
+		actualType := normalizeTypeName(c.DatabaseTypeName())
+		var actualLen int64
+		if actualType == "varchar" || actualType == "bpchar" {
+			actualLen, _ = c.Length()
+		}
+		if expectedType != actualType || expectedLen > 0 && expectedLen != actualLen {
+			drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftType, Name: name,
+				Expected: typeWithLength(expectedType, expectedLen), Actual: typeWithLength(actualType, actualLen)})
+		}
+		if nullable, ok := c.Nullable(); ok {
+			expectedNull := !f.NotNull && !f.PrimaryKey
+			if nullable != expectedNull {
+				drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftNullable, Name: name,
+					Expected: nullability(expectedNull), Actual: nullability(nullable)})
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(actual)) {
+		drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftExtraColumn, Name: name})
+	}
+
+	indexDrifts, err := validateIndexes(m, model, table, stmt.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return append(drifts, indexDrifts...), nil
+}
+
+func validateIndexes(m gorm.Migrator, model any, table string, s *schema.Schema) ([]SchemaDrift, error) {
+	indexes, err := m.GetIndexes(model)
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[string]gorm.Index, len(indexes))
+	for _, idx := range indexes {
+		actual[idx.Name()] = idx
+	}
+
+	expected := s.ParseIndexes()
+	var drifts []SchemaDrift
+	for _, name := range slices.Sorted(maps.Keys(expected)) {
+		idx := expected[name]
+		got, ok := actual[name]
+		if !ok {
+			drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftMissingIndex, Name: name})
+			continue
+		}
+		wantUnique := idx.Class == "UNIQUE"
+		if unique, ok := got.Unique(); ok && unique != wantUnique {
+			drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftIndex, Name: name,
+				Expected: uniqueness(wantUnique), Actual: uniqueness(unique)})
+		}
+		var columns []string
+		for _, f := range idx.Fields {
+			if f.Expression != "" || f.Field == nil {
+				columns = nil
+				break
+			}
+			columns = append(columns, f.DBName)
+		}
+		if columns != nil && !slices.Equal(columns, got.Columns()) {
+			drifts = append(drifts, SchemaDrift{Table: table, Kind: DriftIndex, Name: name,
+				Expected: strings.Join(columns, ", "), Actual: strings.Join(got.Columns(), ", ")})
+		}
+	}
+	return drifts, nil
+}
+
+// columnTypeNames gorm 生成的类型名与 information_schema.columns.udt_name 的对应关系
+var columnTypeNames = map[string]string{
+	"smallserial": "int2", "serial": "int4", "bigserial": "int8",
+	"smallint": "int2", "integer": "int4", "int": "int4", "bigint": "int8",
+	"boolean": "bool", "decimal": "numeric", "real": "float4", "double precision": "float8",
+	"character varying": "varchar", "character": "bpchar", "char": "bpchar",
+	"timestamp with time zone": "timestamptz", "timestamp without time zone": "timestamp", "timestamp": "timestamp",
+}
+
+// normalizeColumnType 把 varchar(100)、numeric(10, 2)、geography(Point,4326) 等拆为类型名与长度（仅字符类型有长度）
+func normalizeColumnType(t string) (string, int64) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	name, args, _ := strings.Cut(t, "(")
+	name = normalizeTypeName(name)
+	var n int64
+	if name == "varchar" || name == "bpchar" {
+		fmt.Sscanf(args, "%d", &n)
+	}
+	return name, n
+}
+
+func normalizeTypeName(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if n, ok := columnTypeNames[t]; ok {
+		return n
+	}
+	return t
+}
+
+func typeWithLength(t string, n int64) string {
+	if n > 0 {
+		return fmt.Sprintf("%s(%d)", t, n)
+	}
+	return t
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+func uniqueness(unique bool) string {
+	if unique {
+		return "UNIQUE"
+	}
+	return "非唯一"
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedFunc 种子数据写入函数，在事务中执行；应尽量幂等（如 SeedRows 的冲突跳过）
+type SeedFunc func(ctx context.Context, tx *gorm.DB) error
+
+// SeedRecord 已执行的种子集记录
+type SeedRecord struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time
+}
+
+func init() {
+	RegisterModel(&SeedRecord{}, ModelOptions{})
+}
+
+// Seeder 种子数据管理：按名称注册种子集，执行过的种子集记录在 seed_records 表中，不会重复执行
+type Seeder struct {
+	db    *gorm.DB
+	mu    sync.Mutex
+	seeds map[string]SeedFunc
+	order []string
+}
+
+// NewSeeder 创建种子数据管理器
+func NewSeeder(db *gorm.DB) *Seeder {
+	return &Seeder{db: db, seeds: make(map[string]SeedFunc)}
+}
+
+// Register 注册种子集，同名覆盖
+func (s *Seeder) Register(name string, fn SeedFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seeds[name]; !ok {
+		s.order = append(s.order, name)
+	}
+	s.seeds[name] = fn
+}
+
+// Seed 按顺序执行指定的种子集（不指定时执行全部已注册的种子集），已执行过的跳过
+// 每个种子集在独立事务中执行，并以事务级 advisory lock 防止多个实例并发执行同一种子集
+func (s *Seeder) Seed(ctx context.Context, names ...string) error {
+	s.mu.Lock()
+	if len(names) == 0 {
+		names = append([]string(nil), s.order...)
+	}
+	fns := make([]SeedFunc, len(names))
+	for i, name := range names {
+		fn, ok := s.seeds[name]
+		if !ok {
+			s.mu.Unlock()
+			return fmt.Errorf("种子集 %s 未注册", name)
+		}
+		fns[i] = fn
+	}
+	s.mu.Unlock()
+
+	if err := s.db.AutoMigrate(&SeedRecord{}); err != nil {
+		return fmt.Errorf("创建种子记录表失败: %w", err)
+	}
+	for i, name := range names {
+		if err := s.apply(ctx, name, fns[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Applied 返回已执行的种子集
+func (s *Seeder) Applied(ctx context.Context) ([]SeedRecord, error) {
+	var records []SeedRecord
+	err := s.db.WithContext(ctx).Order("applied_at").Find(&records).Error
+	return records, err
+}
+
+func (s *Seeder) apply(ctx context.Context, name string, fn SeedFunc) error {
+	applied := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "seed:"+name).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&SeedRecord{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		applied = true
+		return tx.Create(&SeedRecord{Name: name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("执行种子集 %s 失败: %w", name, err)
+	}
+	if applied {
+		log.Printf("种子集 %s 执行成功!", name)
+	}
+	return nil
+}
+
+// SeedRows 插入种子行，与 conflictColumns 上的未删除行冲突时跳过，可安全重复执行
+func SeedRows[T any](tx *gorm.DB, conflictColumns []string, rows []*T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(new(T)); err != nil {
+		return fmt.Errorf("解析模型 %T 失败: %w", new(T), err)
+	}
+	columns := make([]clause.Column, len(conflictColumns))
+	for i, c := range conflictColumns {
+		columns[i] = clause.Column{Name: c}
+	}
+	return tx.Clauses(clause.OnConflict{Columns: columns, TargetWhere: softDeleteConflictTarget(stmt.Schema), DoNothing: true}).Create(rows).Error
+}
+
+// RegisterUserSeeds 注册用户相关的种子集
+func RegisterUserSeeds(s *Seeder) {
+	s.Register("demo_users", func(ctx context.Context, tx *gorm.DB) error {
+		return SeedRows(tx, []string{"email"}, []*User{
+			{Name: "张三", Email: "zhangsan@example.com", Age: 25},
+			{Name: "李四", Email: "lisi@example.com", Age: 30},
+		})
+	})
+	s.Register("test_users", func(ctx context.Context, tx *gorm.DB) error {
+		return SeedRows(tx, []string{"email"}, []*User{
+			{Name: "测试用户", Email: "test@example.com", Age: 20},
+			{Name: "停用用户", Email: "disabled@example.com", Age: 40, Status: UserStatusDisabled},
+		})
+	})
+}
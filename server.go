@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ErrFeatureUnsupported 服务端版本过低，不支持该功能
+var ErrFeatureUnsupported = errors.New("PostgreSQL 版本不支持该功能")
+
+// Feature 依赖特定服务端版本的功能
+type Feature struct {
+	Name       string
+	MinVersion int // server_version_num，如 150000 表示 15.0
+}
+
+var (
+	FeatureGeneratedColumns       = Feature{"生成列 (GENERATED ALWAYS AS ... STORED)", 120000}
+	FeatureDateBin                = Feature{"date_bin 时间分桶", 140000}
+	FeatureCreateOrReplaceTrigger = Feature{"CREATE OR REPLACE TRIGGER", 140000}
+	FeatureMerge                  = Feature{"MERGE", 150000}
+)
+
+// features 启动时检查的全部功能
+var features = []Feature{FeatureGeneratedColumns, FeatureDateBin, FeatureCreateOrReplaceTrigger, FeatureMerge}
+
+func (f Feature) minVersion() string {
+	return fmt.Sprintf("%d", f.MinVersion/10000)
+}
+
+// ServerDetails 服务端版本与关键配置
+type ServerDetails struct {
+	Version        string            `json:"version"`         // version() 的完整输出
+	VersionNum     int               `json:"version_num"`     // server_version_num，如 170002
+	MaxConnections int               `json:"max_connections"` // 连接池上限不应超过该值减去其他客户端的占用
+	Settings       map[string]string `json:"settings"`        // serverSettings 中列出的参数
+}
+
+// serverSettings ServerInfo 额外读取的参数
+var serverSettings = []string{
+	"server_encoding", "TimeZone", "shared_preload_libraries", "wal_level",
+	"statement_timeout", "lock_timeout", "idle_in_transaction_session_timeout", "work_mem", "shared_buffers",
+}
+
+// Supports 是否支持功能
+func (s *ServerDetails) Supports(f Feature) bool {
+	return s.VersionNum >= f.MinVersion
+}
+
+// ServerInfo 查询服务端版本、max_connections 与关键参数
+func ServerInfo(ctx context.Context, db *gorm.DB) (*ServerDetails, error) {
+	db = db.WithContext(ctx)
+	info := &ServerDetails{Settings: map[string]string{}}
+	err := db.Raw("SELECT version(), current_setting('server_version_num')::int, current_setting('max_connections')::int").
+		Row().Scan(&info.Version, &info.VersionNum, &info.MaxConnections)
+	if err != nil {
+		return nil, fmt.Errorf("查询服务端版本失败: %w", err)
+	}
+	var rows []struct {
+		Name    string
+		Setting string
+	}
+	if err := db.Raw("SELECT name, setting FROM pg_settings WHERE name IN ?", serverSettings).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询服务端参数失败: %w", err)
+	}
+	for _, r := range rows {
+		info.Settings[r.Name] = r.Setting
+	}
+	return info, nil
+}
+
+// serverVersions 按连接池缓存的 server_version_num，版本在连接期间不会变化
+var serverVersions sync.Map
+
+// serverVersion 返回缓存的服务端版本，查询失败时返回 0（未知）
+func serverVersion(db *gorm.DB) int {
+	pool := db.Config.ConnPool
+	if v, ok := serverVersions.Load(pool); ok {
+		return v.(int)
+	}
+	var num int
+	if err := db.Raw("SELECT current_setting('server_version_num')::int").Scan(&num).Error; err != nil || num == 0 {
+		return 0
+	}
+	serverVersions.Store(pool, num)
+	return num
+}
+
+// requireFeature 服务端版本低于功能要求时记录警告并返回 ErrFeatureUnsupported，避免执行到一半才因语法错误失败；
+// 版本未知时放行
+func requireFeature(db *gorm.DB, f Feature) error {
+	version := serverVersion(db)
+	if version == 0 || version >= f.MinVersion {
+		return nil
+	}
+	log.Printf("警告: %s 需要 PostgreSQL %s 及以上，当前服务端版本号 %d", f.Name, f.minVersion(), version)
+	return fmt.Errorf("%w: %s 需要 PostgreSQL %s 及以上", ErrFeatureUnsupported, f.Name, f.minVersion())
+}
+
+// logServerInfo 启动时输出服务端版本，并对当前版本不支持的功能给出警告
+func logServerInfo(ctx context.Context, db *gorm.DB) {
+	info, err := ServerInfo(ctx, db)
+	if err != nil {
+		log.Printf("获取服务端信息失败: %v", err)
+		return
+	}
+	serverVersions.Store(db.Config.ConnPool, info.VersionNum)
+	log.Printf("服务端: %s，max_connections=%d", info.Version, info.MaxConnections)
+
+	var unsupported []string
+	for _, f := range features {
+		if !info.Supports(f) {
+			unsupported = append(unsupported, f.Name+"（需要 "+f.minVersion()+"+）")
+		}
+	}
+	if len(unsupported) > 0 {
+		log.Printf("警告: 当前 PostgreSQL 版本不支持以下功能，相关方法将直接返回错误: %s", strings.Join(unsupported, "、"))
+	}
+}
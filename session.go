@@ -0,0 +1,225 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"net/url"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type sessionKey struct{}
+
+// sessionOptions 经 ctx 传递的会话选项，由仓库在创建会话时读取
+type sessionOptions struct {
+	skipHooks   bool
+	comments    map[string]string
+	priority    Priority
+	holdsSlot   bool              // 已由 holdSlot 整体占用并发配额，语句不再单独占用
+	primary     bool              // 读操作也发往主库，见 ReadFromPrimary
+	consistency *ConsistencyToken // 读操作只发往已追上该位置的副本，见 WithConsistency
+}
+
+func sessionFrom(ctx context.Context) sessionOptions {
+	o, _ := ctx.Value(sessionKey{}).(sessionOptions)
+	return o
+}
+
+// equal 两组会话选项是否相同，注释按内容比较
+func (o sessionOptions) equal(p sessionOptions) bool {
+	return o.skipHooks == p.skipHooks && o.priority == p.priority && o.holdsSlot == p.holdsSlot &&
+		o.primary == p.primary && o.consistency == p.consistency && maps.Equal(o.comments, p.comments)
+}
+
+// SkipHooks 之后经仓库执行的操作跳过模型钩子（BeforeCreate/BeforeUpdate 等），用于数据修复、批量导入等场景
+func SkipHooks(ctx context.Context) context.Context {
+	o := sessionFrom(ctx)
+	o.skipHooks = true
+	return context.WithValue(ctx, sessionKey{}, o)
+}
+
+// WithComment 为之后执行的全部语句附加 sqlcommenter 格式的注释，便于在 pg_stat_activity 中定位调用方
+// comment 形如 "key:value"，不含冒号时键为 comment；多次调用合并，同名键以最后一次为准
+//
+//	ctx = WithComment(ctx, "job:cleanup")
+//	// /*job='cleanup'*/ DELETE FROM "users" WHERE ...
+func WithComment(ctx context.Context, comment string) context.Context {
+	key, value, ok := strings.Cut(comment, ":")
+	if !ok {
+		key, value = "comment", comment
+	}
+	o := sessionFrom(ctx)
+	comments := maps.Clone(o.comments)
+	if comments == nil {
+		comments = map[string]string{}
+	}
+	comments[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	o.comments = comments
+	return context.WithValue(ctx, sessionKey{}, o)
+}
+
+// sessionDB 以 ctx 创建会话并应用 ctx 中的会话选项；SQL 注释由全局回调统一添加，不在这里处理
+func sessionDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(ctx)
+	if sessionFrom(ctx).skipHooks {
+		db = db.Session(&gorm.Session{SkipHooks: true})
+	}
+	return db
+}
+
+// session 仓库方法统一的会话入口
+func (r *BaseRepository[T, ID]) session(ctx context.Context) *gorm.DB {
+	return sessionDB(ctx, r.db)
+}
+
+// sqlComment 按 sqlcommenter 规范生成注释：键排序，键值 URL 编码，值用单引号包裹
+// 编码后不会出现 "*/"、"?" 与单引号，可安全拼入 SQL
+func sqlComment(comments map[string]string) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(comments))
+	for _, k := range slices.Sorted(maps.Keys(comments)) {
+		parts = append(parts, url.PathEscape(k)+"='"+url.PathEscape(comments[k])+"'")
+	}
+	return "/*" + strings.Join(parts, ",") + "*/"
+}
+
+// sqlCommentExpr 作为主子句的 BeforeExpression 输出注释，单独的类型便于识别并清除上次执行留下的注释
+type sqlCommentExpr struct {
+	comment string
+}
+
+func (e sqlCommentExpr) Build(builder clause.Builder) {
+	builder.WriteString(e.comment)
+}
+
+// queryTags 语句标签配置，开启后每条语句自动带上 service 与 op（发起语句的仓库方法名）
+type queryTags struct {
+	service string // 为空时使用语句的表名
+	stack   bool   // 不在仓库方法内的语句从调用栈推断 op，见 repositoryOp
+}
+
+// RegisterSQLCommentCallbacks 在全部处理器前注册回调，把 ctx 中的注释加到语句开头
+func RegisterSQLCommentCallbacks(db *gorm.DB) error {
+	return registerSQLCommentCallbacks(db, nil)
+}
+
+func registerSQLCommentCallbacks(db *gorm.DB, tags *queryTags) error {
+	// 各处理器的主子句；软删除在 Delete 处理器中生成 UPDATE 语句，两个子句都需要处理
+	mainClauses := map[string][]string{
+		"create": {"INSERT"},
+		"query":  {"SELECT"},
+		"update": {"UPDATE"},
+		"delete": {"DELETE", "UPDATE"},
+		"row":    {"SELECT"},
+	}
+	for _, p := range callbackPoints(db, "*") {
+		if err := p.before.Register("app:sql_comment", injectSQLComment(tags, mainClauses[p.processor]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func injectSQLComment(tags *queryTags, mainClauses ...string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		comments := sessionFrom(stmt.Context).comments
+		if tags != nil {
+			comments = tags.merge(stmt.Context, comments, stmt.Table)
+		}
+		comment := sqlComment(comments)
+
+		// Raw/Exec 在执行回调前已生成 SQL，直接加在开头
+		if stmt.SQL.Len() > 0 {
+			if comment != "" && !strings.HasPrefix(stmt.SQL.String(), "/*") {
+				sql := stmt.SQL.String()
+				stmt.SQL.Reset()
+				stmt.SQL.WriteString(comment + " " + sql)
+			}
+			return
+		}
+
+		for _, name := range mainClauses {
+			c, ok := stmt.Clauses[name]
+			_, ours := c.BeforeExpression.(sqlCommentExpr)
+			switch {
+			case comment != "" && (!ok || c.BeforeExpression == nil || ours):
+				c.BeforeExpression = sqlCommentExpr{comment: comment}
+				stmt.Clauses[name] = c
+			case comment == "" && ours:
+				// 复用的链式查询上一次执行带了注释，这次的 ctx 没有
+				c.BeforeExpression = nil
+				stmt.Clauses[name] = c
+			}
+		}
+	}
+}
+
+// merge 在 ctx 注释基础上补充 service 与 op，ctx 中同名的键优先。
+// op 取自 ctx 中的仓库操作（见 invoke），只有开启 stack 时才为仓库方法外的语句遍历调用栈
+func (t *queryTags) merge(ctx context.Context, comments map[string]string, table string) map[string]string {
+	merged := make(map[string]string, len(comments)+2)
+	if service := cmp.Or(t.service, table); service != "" {
+		merged["service"] = service
+	}
+	if op := operationFrom(ctx); op != nil {
+		merged["op"] = op.Method
+	} else if t.stack {
+		if op := repositoryOp(); op != "" {
+			merged["op"] = op
+		}
+	}
+	maps.Copy(merged, comments)
+	return merged
+}
+
+// repositoryOp 从调用栈中找出发起语句的仓库方法名
+// 从内向外取连续的仓库方法调用中最外层的一个（如 CachedRepository.GetByID 委托给 BaseRepository.GetByID 时取前者），
+// gorm 等其他包的栈帧与仓库的未导出方法、invoke 跳过，遇到包内非仓库代码（如 Transaction 回调中的业务代码）时停止
+func repositoryOp() string {
+	var pcs [48]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	var op string
+	for {
+		frame, more := frames.Next()
+		if method, ok := repositoryMethod(frame.Function); ok {
+			if method != "" && unicode.IsUpper(rune(method[0])) {
+				op = method
+			}
+		} else if op != "" && strings.HasPrefix(frame.Function, pkgPrefix) && !strings.HasPrefix(frame.Function, pkgPrefix+"invoke[") {
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return op
+}
+
+// pkgPrefix 本包函数名的前缀，编译为程序时为 "main."，测试中为模块路径
+var pkgPrefix = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(sqlComment).Pointer()).Name(), "sqlComment")
+
+// repositoryMethod 解析形如 "main.(*BaseRepository[...]).GetByID.func1" 的函数名
+func repositoryMethod(fn string) (string, bool) {
+	recv, rest, ok := strings.Cut(strings.TrimPrefix(fn, pkgPrefix+"(*"), ").")
+	if !ok || !strings.HasPrefix(fn, pkgPrefix+"(*") {
+		return "", false
+	}
+	if i := strings.IndexByte(recv, '['); i >= 0 {
+		recv = recv[:i]
+	}
+	if !strings.HasSuffix(recv, "Repository") {
+		return "", false
+	}
+	method, _, _ := strings.Cut(rest, ".")
+	return method, true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrShuttingDown 数据库正在关闭，不再接受新的操作
+var ErrShuttingDown = errors.New("数据库正在关闭，拒绝新的操作")
+
+// shutdownGrace 超时取消在途查询后，等待其退出的最长时间
+const shutdownGrace = 5 * time.Second
+
+// opTracker 跟踪在途数据库操作，用于优雅关闭
+type opTracker struct {
+	mu      sync.RWMutex
+	closing bool
+	wg      sync.WaitGroup
+	stopCtx context.Context // Shutdown 超时后取消，连带取消全部在途查询
+	stop    context.CancelFunc
+}
+
+func newOpTracker() *opTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &opTracker{stopCtx: ctx, stop: cancel}
+}
+
+var tracker = newOpTracker()
+
+// begin 登记一次操作，返回的 ctx 会在 Shutdown 超时后被取消
+func (t *opTracker) begin(ctx context.Context) (context.Context, func(), error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closing {
+		return ctx, nil, ErrShuttingDown
+	}
+	t.wg.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopAfter := context.AfterFunc(t.stopCtx, cancel)
+	return ctx, func() {
+		stopAfter()
+		cancel()
+		t.wg.Done()
+	}, nil
+}
+
+// wait 等待全部在途操作结束，ctx 到期时返回 false
+func (t *opTracker) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+const trackDoneKey = "app:track_done"
+
+// RegisterShutdownCallbacks 在所有 gorm 处理器前后登记/注销在途操作
+func RegisterShutdownCallbacks(db *gorm.DB) error {
+	// Row/Rows 返回的结果在回调链结束后才被读取，不能替换并提前取消其 ctx，只做计数
+	err := registerAround(callbackPoints(db, "*", "row"),
+		callbackHook{"app:track_begin", trackBeginNoCancel}, callbackHook{"app:track_end", trackEnd})
+	if err != nil {
+		return err
+	}
+	return registerAround(callbackPoints(db, "*", "create", "query", "update", "delete", "raw"),
+		callbackHook{"app:track_begin", trackBegin}, callbackHook{"app:track_end", trackEnd})
+}
+
+func trackBegin(db *gorm.DB) {
+	ctx, done, err := tracker.begin(db.Statement.Context)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.Statement.Context = ctx
+	db.InstanceSet(trackDoneKey, done)
+}
+
+func trackBeginNoCancel(db *gorm.DB) {
+	_, done, err := tracker.begin(context.Background())
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(trackDoneKey, done)
+}
+
+func trackEnd(db *gorm.DB) {
+	if v, ok := db.InstanceGet(trackDoneKey); ok {
+		v.(func())()
+	}
+}
+
+// Shutdown 优雅关闭数据库：拒绝新的操作，等待在途操作完成；
+// ctx 到期仍未完成时取消在途查询（pgx 会向服务端发送取消请求），最后关闭连接池
+func Shutdown(ctx context.Context) error {
+	tracker.mu.Lock()
+	tracker.closing = true
+	tracker.mu.Unlock()
+
+	if !tracker.wait(ctx) {
+		log.Println("等待在途数据库操作超时，取消剩余查询")
+		tracker.stop()
+		graceCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+		defer cancel()
+		if !tracker.wait(graceCtx) {
+			log.Println("仍有数据库操作未退出，强制关闭连接池")
+		}
+	}
+	return Close()
+}
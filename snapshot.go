@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// MaintenanceDatabase 创建、删除数据库时连接的维护库：PostgreSQL 不允许在连接着源库时以其为模板复制或删除它
+const MaintenanceDatabase = "postgres"
+
+var snapshotNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,30}$`)
+
+// Snapshot 以当前数据库为模板复制出快照库 <库名>_snapshot_<name>（同名快照被覆盖），一般在迁移、加载种子和夹具之后调用，
+// 之后每个用例以 RestoreSnapshot 在毫秒级恢复到该状态。复制期间源库不能有其他连接，因此会断开 db 连接池中的连接
+// 以及其他客户端到该库的连接，调用期间不应有并发查询。需要 CREATEDB 权限
+//
+//	func TestMain(m *testing.M) {
+//		db := ... // 迁移并加载夹具
+//		if err := Snapshot(ctx, db, "seeded"); err != nil {
+//			log.Fatal(err)
+//		}
+//		os.Exit(m.Run())
+//	}
+func Snapshot(ctx context.Context, db *gorm.DB, name string) error {
+	return withMaintenanceConn(ctx, db, name, func(conn *pgx.Conn, source, snapshot string) error {
+		if err := dropDatabase(ctx, conn, snapshot); err != nil {
+			return err
+		}
+		if err := copyDatabase(ctx, conn, source, snapshot); err != nil {
+			return fmt.Errorf("创建快照 %s 失败: %w", name, err)
+		}
+		log.Printf("已创建数据库 %s 的快照 %s", source, snapshot)
+		return nil
+	})
+}
+
+// RestoreSnapshot 删除当前数据库并以快照库为模板重建（数据不可恢复），与 TruncateTable 一样受 SetAllowDestructive 保护。
+// 恢复后 db 的连接池在下一次查询时自动连接到新库
+func RestoreSnapshot(ctx context.Context, db *gorm.DB, name string, opts ...DestructiveOption) error {
+	if _, err := newDestructiveOptions(opts); err != nil {
+		return err
+	}
+	return withMaintenanceConn(ctx, db, name, func(conn *pgx.Conn, source, snapshot string) error {
+		var exists bool
+		if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", snapshot).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("快照 %s 不存在", name)
+		}
+		if err := dropDatabase(ctx, conn, source); err != nil {
+			return err
+		}
+		if err := copyDatabase(ctx, conn, snapshot, source); err != nil {
+			return fmt.Errorf("从快照 %s 恢复失败: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// DropSnapshot 删除快照库，快照不存在时不报错
+func DropSnapshot(ctx context.Context, db *gorm.DB, name string) error {
+	return withMaintenanceConn(ctx, db, name, func(conn *pgx.Conn, _, snapshot string) error {
+		return dropDatabase(ctx, conn, snapshot)
+	})
+}
+
+// withMaintenanceConn 以 db 的连接参数（含 SSH 隧道等拨号设置）连接维护库执行 fn，执行前后重置 db 的连接池
+func withMaintenanceConn(ctx context.Context, db *gorm.DB, name string, fn func(conn *pgx.Conn, source, snapshot string) error) error {
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("快照名 %q 无效，只能包含小写字母、数字和下划线，最长 30 个字符", name)
+	}
+	if activePoolerMode == PoolerTransaction {
+		return fmt.Errorf("数据库快照: %w", ErrUnsupportedWithPooler)
+	}
+	var connCfg *pgx.ConnConfig
+	if err := withPgConn(ctx, db, func(conn *pgx.Conn) error {
+		connCfg = conn.Config().Copy()
+		return nil
+	}); err != nil {
+		return err
+	}
+	source := connCfg.Database
+	connCfg.Database = MaintenanceDatabase
+
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return fmt.Errorf("连接维护库 %s 失败: %w", MaintenanceDatabase, err)
+	}
+	defer conn.Close(context.Background())
+
+	resetPool(db)
+	defer resetPool(db)
+	if err := terminateBackends(ctx, conn, source); err != nil {
+		return err
+	}
+	return fn(conn, source, source+"_snapshot_"+name)
+}
+
+// terminateBackends 断开其他客户端到 database 的连接
+func terminateBackends(ctx context.Context, conn *pgx.Conn, database string) error {
+	_, err := conn.Exec(ctx,
+		"SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()", database)
+	if err != nil {
+		return fmt.Errorf("断开数据库 %s 的连接失败: %w", database, err)
+	}
+	return nil
+}
+
+func copyDatabase(ctx context.Context, conn *pgx.Conn, template, target string) error {
+	_, err := conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{target}.Sanitize()+" TEMPLATE "+pgx.Identifier{template}.Sanitize())
+	return err
+}
+
+func dropDatabase(ctx context.Context, conn *pgx.Conn, database string) error {
+	if err := terminateBackends(ctx, conn, database); err != nil {
+		return err
+	}
+	if _, err := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{database}.Sanitize()); err != nil {
+		return fmt.Errorf("删除数据库 %s 失败: %w", database, err)
+	}
+	return nil
+}
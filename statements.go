@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrStatStatementsUnavailable 当前数据库未安装 pg_stat_statements 扩展
+var ErrStatStatementsUnavailable = errors.New("pg_stat_statements 扩展不可用")
+
+// QueryStat pg_stat_statements 中的一条归一化语句统计
+type QueryStat struct {
+	Query       string  `json:"query"` // 归一化后的语句，参数被替换为 $1、$2...
+	Calls       int64   `json:"calls"`
+	TotalTimeMS float64 `json:"total_time_ms"`
+	MeanTimeMS  float64 `json:"mean_time_ms"`
+	Rows        int64   `json:"rows"`
+}
+
+// TopQueries 返回当前数据库按总耗时排序的前 n 条语句
+func TopQueries(ctx context.Context, db *gorm.DB, n int) ([]QueryStat, error) {
+	db = db.WithContext(ctx)
+
+	var installed bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')").
+		Scan(&installed).Error; err != nil {
+		return nil, err
+	}
+	if !installed {
+		return nil, ErrStatStatementsUnavailable
+	}
+
+	// PostgreSQL 13 起耗时列由 total_time/mean_time 改名为 total_exec_time/mean_exec_time
+	var versionNum int
+	if err := db.Raw("SELECT current_setting('server_version_num')::int").Scan(&versionNum).Error; err != nil {
+		return nil, err
+	}
+	totalCol, meanCol := "total_exec_time", "mean_exec_time"
+	if versionNum < 130000 {
+		totalCol, meanCol = "total_time", "mean_time"
+	}
+
+	sql := fmt.Sprintf(`SELECT s.query, s.calls, s.%s AS total_time_ms, s.%s AS mean_time_ms, s.rows
+		FROM pg_stat_statements s JOIN pg_database d ON d.oid = s.dbid
+		WHERE d.datname = current_database()
+		ORDER BY s.%s DESC LIMIT ?`, totalCol, meanCol, totalCol)
+
+	var stats []QueryStat
+	if err := db.Raw(sql, n).Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("查询 pg_stat_statements 失败: %w", err)
+	}
+	return stats, nil
+}
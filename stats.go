@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TableStat 表级统计
+type TableStat struct {
+	Schema          string     `json:"schema"`
+	Table           string     `json:"table"`
+	TotalBytes      int64      `json:"total_bytes"` // 表 + 索引 + TOAST
+	TableBytes      int64      `json:"table_bytes"`
+	IndexBytes      int64      `json:"index_bytes"`
+	LiveTuples      int64      `json:"live_tuples"`
+	DeadTuples      int64      `json:"dead_tuples"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze"`
+}
+
+// IndexStat 索引级统计
+type IndexStat struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Index  string `json:"index"`
+	Bytes  int64  `json:"bytes"`
+	Scans  int64  `json:"scans"` // 为 0 的索引可能是无用索引
+}
+
+// DBStats 数据库容量与运行状态统计
+type DBStats struct {
+	DatabaseBytes int64            `json:"database_bytes"`
+	CacheHitRatio float64          `json:"cache_hit_ratio"`
+	Connections   map[string]int64 `json:"connections"` // 按 state 分组：active、idle、idle in transaction...
+	Tables        []TableStat      `json:"tables"`
+	Indexes       []IndexStat      `json:"indexes"`
+}
+
+// Stats 从 pg_stat_* 视图汇总当前数据库的统计信息
+func Stats(ctx context.Context, db *gorm.DB) (*DBStats, error) {
+	db = db.WithContext(ctx)
+	stats := &DBStats{Connections: map[string]int64{}}
+
+	if err := db.Raw("SELECT pg_database_size(current_database())").Scan(&stats.DatabaseBytes).Error; err != nil {
+		return nil, fmt.Errorf("查询数据库大小失败: %w", err)
+	}
+
+	err := db.Raw(`SELECT COALESCE(sum(blks_hit)::float8 / NULLIF(sum(blks_hit) + sum(blks_read), 0), 0)
+		FROM pg_stat_database WHERE datname = current_database()`).Scan(&stats.CacheHitRatio).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询缓存命中率失败: %w", err)
+	}
+
+	var conns []struct {
+		State string
+		Count int64
+	}
+	err = db.Raw(`SELECT COALESCE(state, 'unknown') AS state, count(*) AS count
+		FROM pg_stat_activity WHERE datname = current_database() GROUP BY 1`).Scan(&conns).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询连接状态失败: %w", err)
+	}
+	for _, c := range conns {
+		stats.Connections[c.State] = c.Count
+	}
+
+	err = db.Raw(`SELECT schemaname AS schema, relname AS "table",
+			pg_total_relation_size(relid) AS total_bytes, pg_relation_size(relid) AS table_bytes,
+			pg_indexes_size(relid) AS index_bytes, n_live_tup AS live_tuples, n_dead_tup AS dead_tuples,
+			last_autovacuum, last_autoanalyze
+		FROM pg_stat_user_tables ORDER BY total_bytes DESC`).Scan(&stats.Tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询表统计失败: %w", err)
+	}
+
+	err = db.Raw(`SELECT schemaname AS schema, relname AS "table", indexrelname AS index,
+			pg_relation_size(indexrelid) AS bytes, idx_scan AS scans
+		FROM pg_stat_user_indexes ORDER BY bytes DESC`).Scan(&stats.Indexes).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询索引统计失败: %w", err)
+	}
+	return stats, nil
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出统计指标（gauge）
+func (s *DBStats) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	gauge := func(name, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+
+	gauge("pg_database_size_bytes", "Size of the current database in bytes.")
+	fmt.Fprintf(bw, "pg_database_size_bytes %d\n", s.DatabaseBytes)
+
+	gauge("pg_cache_hit_ratio", "Buffer cache hit ratio of the current database.")
+	fmt.Fprintf(bw, "pg_cache_hit_ratio %g\n", s.CacheHitRatio)
+
+	gauge("pg_connections", "Connections to the current database by state.")
+	for state, n := range s.Connections {
+		fmt.Fprintf(bw, "pg_connections{state=%q} %d\n", state, n)
+	}
+
+	gauge("pg_table_size_bytes", "Total size of a table including indexes and TOAST.")
+	for _, t := range s.Tables {
+		fmt.Fprintf(bw, "pg_table_size_bytes{schema=%q,table=%q} %d\n", t.Schema, t.Table, t.TotalBytes)
+	}
+	gauge("pg_table_dead_tuples", "Estimated number of dead tuples.")
+	for _, t := range s.Tables {
+		fmt.Fprintf(bw, "pg_table_dead_tuples{schema=%q,table=%q} %d\n", t.Schema, t.Table, t.DeadTuples)
+	}
+
+	gauge("pg_index_size_bytes", "Size of an index.")
+	for _, i := range s.Indexes {
+		fmt.Fprintf(bw, "pg_index_size_bytes{schema=%q,table=%q,index=%q} %d\n", i.Schema, i.Table, i.Index, i.Bytes)
+	}
+	return bw.Flush()
+}
+
+// StatsHandler 以 Prometheus 文本格式暴露数据库统计，可挂载到 /metrics
+func StatsHandler(db *gorm.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		stats, err := Stats(req.Context(), db)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = stats.WritePrometheus(w)
+	})
+}
@@ -0,0 +1 @@
+INSERT INTO "users" ("name","email","age","status","created_at","updated_at","deleted_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id";
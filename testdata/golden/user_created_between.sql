@@ -0,0 +1 @@
+SELECT * FROM "users" WHERE (created_at >= $1 AND created_at < $2) AND "users"."deleted_at" IS NULL ORDER BY created_at,id;
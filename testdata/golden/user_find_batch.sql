@@ -0,0 +1 @@
+SELECT * FROM "users" WHERE id > $1 AND "users"."deleted_at" IS NULL ORDER BY id LIMIT $2;
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BucketInterval 时间分桶的粒度：date_trunc 支持的单位（minute、hour、day、week、month 等），
+// 或任意 interval 字面量（如 "15 minutes"，使用 date_bin，需 PostgreSQL 14+）
+type BucketInterval string
+
+const (
+	BucketMinute BucketInterval = "minute"
+	BucketHour   BucketInterval = "hour"
+	BucketDay    BucketInterval = "day"
+	BucketWeek   BucketInterval = "week"
+	BucketMonth  BucketInterval = "month"
+)
+
+// truncUnits date_trunc 支持的单位
+var truncUnits = []BucketInterval{"microseconds", "milliseconds", "second", "minute", "hour", "day", "week", "month", "quarter", "year"}
+
+// expr 返回 column 所在时间桶的起始时间表达式；date_bin 的桶以 2000-01-01 为起点对齐
+func (iv BucketInterval) expr(column string) clause.Expr {
+	if slices.Contains(truncUnits, iv) {
+		return clause.Expr{SQL: "date_trunc(?, ?)", Vars: []any{string(iv), clause.Column{Name: column}}}
+	}
+	return clause.Expr{SQL: "date_bin(?::interval, ?, TIMESTAMPTZ '2000-01-01')", Vars: []any{string(iv), clause.Column{Name: column}}}
+}
+
+// Bucket 时间桶统计结果，没有数据的桶不返回
+type Bucket struct {
+	Start time.Time `gorm:"column:bucket" json:"start"`
+	Count int64     `gorm:"column:count" json:"count"`
+}
+
+// CountByInterval 按时间桶统计行数，按桶起始时间升序返回，例如按天统计最近一周的注册量：
+//
+//	buckets, err := repo.CountByInterval(ctx, "created_at", BucketDay, Spec{Where("created_at >= ?", time.Now().AddDate(0, 0, -7))})
+func (r *BaseRepository[T, ID]) CountByInterval(ctx context.Context, column string, interval BucketInterval, spec Spec) ([]Bucket, error) {
+	return invoke(ctx, r, "CountByInterval", OpRead, func(ctx context.Context) ([]Bucket, error) { return r.countByInterval(ctx, column, interval, spec) }, column, interval, spec)
+}
+
+func (r *BaseRepository[T, ID]) countByInterval(ctx context.Context, column string, interval BucketInterval, spec Spec) ([]Bucket, error) {
+	if !slices.Contains(truncUnits, interval) {
+		if err := requireFeature(r.db, FeatureDateBin); err != nil {
+			return nil, err
+		}
+	}
+	bucket := interval.expr(column)
+	db := newQueryOptions(spec).filter(r.session(ctx).Model(new(T)))
+	var buckets []Bucket
+	err := db.Select("? AS bucket, COUNT(*) AS count", bucket).Group("bucket").Order("bucket").Scan(&buckets).Error
+	if err != nil {
+		return nil, fmt.Errorf("按时间桶统计失败: %w", err)
+	}
+	return buckets, nil
+}
+
+// TimeBucket 时间桶分组表达式，供 Aggregate 做 COUNT 以外的聚合，如按小时求平均值：
+//
+//	rows, err := repo.Aggregate(ctx, AggregateSpec{
+//		GroupBy:      []GroupExpr{TimeBucket("recorded_at", BucketHour, "hour")},
+//		Aggregations: []Aggregation{{Func: AggAvg, Column: "value"}},
+//		OrderBy:      []string{"hour"},
+//	})
+//
+// column 与 interval 会直接拼接进 SQL，只能使用常量
+func TimeBucket(column string, interval BucketInterval, alias string) GroupExpr {
+	col := quoteQualified(column)
+	if slices.Contains(truncUnits, interval) {
+		return GroupExpr{Expr: fmt.Sprintf("date_trunc(%s, %s)", quoteLiteral(string(interval)), col), Alias: alias, trusted: true}
+	}
+	return GroupExpr{
+		Expr:    fmt.Sprintf("date_bin(%s::interval, %s, TIMESTAMPTZ '2000-01-01')", quoteLiteral(string(interval)), col),
+		Alias:   alias,
+		trusted: true,
+	}
+}
+
+// CreateHypertable 将模型对应的表转换为 TimescaleDB 超表（已是超表则跳过），按 column 自动切分时间块；
+// 需要先执行 AutoMigrate 建表，且主键/唯一约束必须包含 column。chunk 为 0 时使用 TimescaleDB 默认值（7 天）
+func CreateHypertable(ctx context.Context, db *gorm.DB, model any, column string, chunk time.Duration) error {
+	if err := EnsureExtensions(ctx, db, "timescaledb"); err != nil {
+		return err
+	}
+	table, err := parseTableName(db, model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	sql := "SELECT create_hypertable(?::regclass, ?, if_not_exists => TRUE, migrate_data => TRUE)"
+	args := []any{table, column}
+	if chunk > 0 {
+		sql = "SELECT create_hypertable(?::regclass, ?, chunk_time_interval => ?::interval, if_not_exists => TRUE, migrate_data => TRUE)"
+		args = append(args, fmt.Sprintf("%d microseconds", chunk.Microseconds()))
+	}
+	if err := db.Exec(sql, args...).Error; err != nil {
+		return fmt.Errorf("表 %s 转换为超表失败: %w", table, err)
+	}
+	log.Printf("表 %s 已转换为按 %s 切分的超表", table, column)
+	return nil
+}
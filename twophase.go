@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TwoPhasePrefix TwoPhaseCommit 生成的全局事务ID前缀，恢复时据此区分本应用的预备事务
+const TwoPhasePrefix = "app2pc:"
+
+// twoPhaseGID 第 branch 个参与方（共 branches 个）的全局事务ID，形如 app2pc:order-42:1/2。
+// gid 在同一集群内必须唯一，两个参与方位于同一集群（如同一实例上的两个库）时共用 id 会导致第二个 PREPARE 失败
+func twoPhaseGID(id string, branch, branches int) string {
+	return fmt.Sprintf("%s%s:%d/%d", TwoPhasePrefix, id, branch, branches)
+}
+
+// PreparedTransaction 两阶段提交中单个数据库上的事务：写入完成后 Prepare，
+// 之后即使连接断开或进程退出，事务也保留在服务端，直到 COMMIT PREPARED / ROLLBACK PREPARED
+// 服务端需设置 max_prepared_transactions > 0
+type PreparedTransaction struct {
+	db       *gorm.DB
+	tx       *gorm.DB
+	gid      string
+	prepared bool
+	done     bool
+}
+
+// BeginPrepared 开启一个将以 gid 预备的事务，gid 在同一集群内必须唯一
+func BeginPrepared(ctx context.Context, db *gorm.DB, gid string, opts ...TxOption) (*PreparedTransaction, error) {
+	if inTransaction(db) {
+		return nil, errors.New("两阶段提交不能在已有事务中开启")
+	}
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	tx := sessionDB(ctx, db).Begin(&o.TxOptions)
+	if tx.Error != nil {
+		return nil, fmt.Errorf("开启事务 %s 失败: %w", gid, tx.Error)
+	}
+	return &PreparedTransaction{db: sessionDB(ctx, db), tx: tx, gid: gid}, nil
+}
+
+// GID 全局事务ID
+func (p *PreparedTransaction) GID() string { return p.gid }
+
+// DB 绑定到该事务的会话，Prepare 之前的写操作都应通过它执行
+func (p *PreparedTransaction) DB() *gorm.DB { return p.tx }
+
+// Prepare 执行 PREPARE TRANSACTION，成功后事务与连接分离，连接归还连接池
+func (p *PreparedTransaction) Prepare() error {
+	if p.prepared || p.done {
+		return fmt.Errorf("事务 %s 已预备或已结束", p.gid)
+	}
+	if err := p.tx.Exec("PREPARE TRANSACTION " + quoteLiteral(p.gid)).Error; err != nil {
+		p.tx.Rollback()
+		p.done = true
+		return fmt.Errorf("预备事务 %s 失败: %w", p.gid, err)
+	}
+	// 连接上已没有进行中的事务，COMMIT 只是让 database/sql 释放连接
+	p.tx.Commit()
+	p.prepared = true
+	return nil
+}
+
+// Commit 提交：已预备时执行 COMMIT PREPARED，否则直接提交（单库退化为普通事务）
+func (p *PreparedTransaction) Commit() error {
+	if p.done {
+		return fmt.Errorf("事务 %s 已结束", p.gid)
+	}
+	if !p.prepared {
+		p.done = true
+		return p.tx.Commit().Error
+	}
+	if err := p.db.Exec("COMMIT PREPARED " + quoteLiteral(p.gid)).Error; err != nil {
+		return fmt.Errorf("提交预备事务 %s 失败: %w", p.gid, err)
+	}
+	p.done = true
+	return nil
+}
+
+// Rollback 回滚：已预备时执行 ROLLBACK PREPARED；已结束时什么也不做
+func (p *PreparedTransaction) Rollback() error {
+	if p.done {
+		return nil
+	}
+	if !p.prepared {
+		p.done = true
+		return p.tx.Rollback().Error
+	}
+	if err := p.db.Exec("ROLLBACK PREPARED " + quoteLiteral(p.gid)).Error; err != nil {
+		return fmt.Errorf("回滚预备事务 %s 失败: %w", p.gid, err)
+	}
+	p.done = true
+	return nil
+}
+
+// TwoPhaseParticipant 两阶段提交的参与方：在 DB 上开启事务并执行 Fn
+type TwoPhaseParticipant struct {
+	DB *gorm.DB
+	Fn func(tx *gorm.DB) error
+}
+
+// TwoPhaseCommit 在多个数据库（通常是不同集群）上原子地写入：
+// 依次执行各参与方的 Fn 并 PREPARE，全部预备成功后逐个 COMMIT PREPARED；
+// 任一参与方在预备完成前失败时回滚全部参与方；各参与方的全局事务ID为 id 加上分支序号，见 PreparedXact.Branch
+// 提交阶段失败（如进程崩溃、网络中断）时部分参与方会留下预备事务，
+// 由 RecoverPreparedTransactions 按"全部已预备即提交"的原则处理
+//
+//	err := TwoPhaseCommit(ctx, "order-42",
+//		TwoPhaseParticipant{DB: ordersDB, Fn: func(tx *gorm.DB) error { return tx.Create(order).Error }},
+//		TwoPhaseParticipant{DB: billingDB, Fn: func(tx *gorm.DB) error { return tx.Create(invoice).Error }},
+//	)
+func TwoPhaseCommit(ctx context.Context, id string, participants ...TwoPhaseParticipant) error {
+	txs := make([]*PreparedTransaction, 0, len(participants))
+	rollback := func(cause error) error {
+		for _, p := range txs {
+			if err := p.Rollback(); err != nil {
+				log.Printf("两阶段提交 %s 回滚失败: %v", p.GID(), err)
+			}
+		}
+		return cause
+	}
+
+	for i, part := range participants {
+		p, err := BeginPrepared(ctx, part.DB, twoPhaseGID(id, i+1, len(participants)))
+		if err != nil {
+			return rollback(err)
+		}
+		txs = append(txs, p)
+		if err := part.Fn(p.DB()); err != nil {
+			return rollback(fmt.Errorf("参与方 %d 执行失败: %w", i, err))
+		}
+	}
+	for _, p := range txs {
+		if err := p.Prepare(); err != nil {
+			return rollback(err)
+		}
+	}
+
+	// 全部预备成功后结果已确定为提交，单个参与方提交失败不再回滚其他参与方
+	var errs []error
+	for _, p := range txs {
+		if err := p.Commit(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PreparedXact pg_prepared_xacts 中的一行
+type PreparedXact struct {
+	GID      string    `gorm:"column:gid"`
+	Prepared time.Time `gorm:"column:prepared"`
+	Owner    string    `gorm:"column:owner"`
+	Database string    `gorm:"column:database"`
+}
+
+// Age 已预备的时长
+func (x PreparedXact) Age() time.Duration { return time.Since(x.Prepared) }
+
+// Branch 解析 TwoPhaseCommit 生成的全局事务ID，返回调用方传入的 id、该参与方的序号（从 1 开始）与参与方总数
+func (x PreparedXact) Branch() (id string, branch, branches int, ok bool) {
+	rest, ok := strings.CutPrefix(x.GID, TwoPhasePrefix)
+	i := strings.LastIndexByte(rest, ':')
+	if !ok || i < 0 {
+		return "", 0, 0, false
+	}
+	if _, err := fmt.Sscanf(rest[i+1:], "%d/%d", &branch, &branches); err != nil || branch < 1 || branch > branches {
+		return "", 0, 0, false
+	}
+	return rest[:i], branch, branches, true
+}
+
+// ListPreparedTransactions 列出当前数据库中 gid 以 prefix 开头的预备事务，按预备时间排序
+func ListPreparedTransactions(ctx context.Context, db *gorm.DB, prefix string) ([]PreparedXact, error) {
+	var xacts []PreparedXact
+	err := db.WithContext(ctx).Raw(
+		"SELECT gid, prepared, owner, database FROM pg_prepared_xacts WHERE database = current_database() AND starts_with(gid, ?) ORDER BY prepared",
+		prefix).Scan(&xacts).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询预备事务失败: %w", err)
+	}
+	return xacts, nil
+}
+
+// PreparedResolution 恢复时对孤立预备事务的处理
+type PreparedResolution int
+
+const (
+	PreparedSkip     PreparedResolution = iota // 暂不处理（如协调方可能仍在运行）
+	PreparedCommit                             // COMMIT PREPARED
+	PreparedRollback                           // ROLLBACK PREPARED
+)
+
+// RecoverPreparedTransactions 扫描本应用（TwoPhasePrefix）预备超过 olderThan 仍未结束的事务，
+// 按 resolve 的结果提交或回滚，返回处理的事务数
+// 预备事务会持有锁并阻止 VACUUM 清理，应定期运行；resolve 通常检查其他参与方：
+// 以 PreparedXact.Branch 得到 id 与参与方总数，同一 id 的其他分支均已提交或仍为预备状态时提交，否则回滚
+func RecoverPreparedTransactions(ctx context.Context, db *gorm.DB, olderThan time.Duration, resolve func(PreparedXact) (PreparedResolution, error)) (int, error) {
+	xacts, err := ListPreparedTransactions(ctx, db, TwoPhasePrefix)
+	if err != nil {
+		return 0, err
+	}
+	var resolved int
+	var errs []error
+	for _, x := range xacts {
+		if x.Age() < olderThan {
+			continue
+		}
+		decision, err := resolve(x)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("判定预备事务 %s 失败: %w", x.GID, err))
+			continue
+		}
+		var stmt, action string
+		switch decision {
+		case PreparedCommit:
+			stmt, action = "COMMIT PREPARED ", "提交"
+		case PreparedRollback:
+			stmt, action = "ROLLBACK PREPARED ", "回滚"
+		default:
+			continue
+		}
+		if err := db.WithContext(ctx).Exec(stmt + quoteLiteral(x.GID)).Error; err != nil {
+			errs = append(errs, fmt.Errorf("处理预备事务 %s 失败: %w", x.GID, err))
+			continue
+		}
+		log.Printf("预备事务 %s 已%s", x.GID, action)
+		resolved++
+	}
+	return resolved, errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrNestedTxOptions 嵌套事务以 SAVEPOINT 实现，无法再指定隔离级别与访问模式
+var ErrNestedTxOptions = errors.New("嵌套事务不支持隔离级别与访问模式选项")
+
+// TxOption 事务选项，未指定时使用数据库默认值（READ COMMITTED、READ WRITE）
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	sql.TxOptions
+	deferrable bool
+}
+
+// ReadCommitted 使用 READ COMMITTED 隔离级别
+func ReadCommitted() TxOption {
+	return func(o *txOptions) { o.Isolation = sql.LevelReadCommitted }
+}
+
+// RepeatableRead 使用 REPEATABLE READ 隔离级别，事务内的查询看到同一快照
+func RepeatableRead() TxOption {
+	return func(o *txOptions) { o.Isolation = sql.LevelRepeatableRead }
+}
+
+// Serializable 使用 SERIALIZABLE 隔离级别，冲突的事务提交时以 40001 失败，
+// 可用 IsSerializationFailure 判断后整体重试
+func Serializable() TxOption {
+	return func(o *txOptions) { o.Isolation = sql.LevelSerializable }
+}
+
+// ReadOnly 以 READ ONLY 模式开启事务，事务内的写操作被数据库拒绝
+func ReadOnly() TxOption {
+	return func(o *txOptions) { o.ReadOnly = true }
+}
+
+// Deferrable 以 DEFERRABLE 模式开启事务，仅与 Serializable、ReadOnly 同时使用时生效：
+// 开始时等待一个安全快照，之后不会因序列化冲突失败，适合长时间运行的报表
+func Deferrable() TxOption {
+	return func(o *txOptions) { o.deferrable = true }
+}
+
+// Transaction 在事务中执行 fn，fn 返回错误或 panic 时回滚，否则提交
+// 隔离级别与 READ ONLY 经 sql.TxOptions 在 BEGIN 时设置，DEFERRABLE 通过 SET TRANSACTION 设置；
+// db 已处于事务中时以 SAVEPOINT 嵌套执行，此时指定选项返回 ErrNestedTxOptions
+//
+//	err := Transaction(ctx, db, func(tx *gorm.DB) error {
+//		return NewUserRepository(tx).Update(ctx, user)
+//	}, Serializable())
+func Transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...TxOption) error {
+	if !inTransaction(db) {
+		// 挂载了 ConcurrencyLimiter 时整个事务在取得连接前占用一个配额
+		var release func()
+		var err error
+		if ctx, release, err = holdSlot(ctx, db, sessionFrom(ctx).priority); err != nil {
+			return err
+		}
+		defer release()
+	}
+	if len(opts) == 0 {
+		return sessionDB(ctx, db).Transaction(fn)
+	}
+	if inTransaction(db) {
+		return ErrNestedTxOptions
+	}
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return sessionDB(ctx, db).Transaction(func(tx *gorm.DB) error {
+		if o.deferrable {
+			if err := tx.Exec("SET TRANSACTION DEFERRABLE").Error; err != nil {
+				return fmt.Errorf("设置事务模式失败: %w", err)
+			}
+		}
+		return fn(tx)
+	}, &o.TxOptions)
+}
+
+// Transaction 在事务中执行 fn，fn 收到绑定到该事务、共享已注册事件回调与中间件的仓库；选项同包级 Transaction
+func (r *BaseRepository[T, ID]) Transaction(ctx context.Context, fn func(repo *BaseRepository[T, ID]) error, opts ...TxOption) error {
+	return Transaction(ctx, r.db, func(tx *gorm.DB) error {
+		return fn(&BaseRepository[T, ID]{db: tx, hooks: r.hooks, limits: r.limits, rowLimit: r.rowLimit, middleware: r.middleware})
+	}, opts...)
+}
+
+// IsSerializationFailure 判断错误是否为序列化失败（40001）或死锁（40P01），这两类事务可以整体重试
+func IsSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// MigrateSoftDeleteUniques 在 AutoMigrate 之后调用：带软删除字段的模型上，未指定 where 的唯一索引
+// 改建为只约束未删除行的部分唯一索引，例如
+//
+//	Email CIText `gorm:"uniqueIndex"`  ->  CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE deleted_at IS NULL
+//
+// 这样账号软删除后可以用同一邮箱重新注册。已是部分索引的跳过；需要连同已删除行一起约束的，在标签中显式写 where
+func MigrateSoftDeleteUniques(ctx context.Context, db *gorm.DB, models ...any) error {
+	db = db.WithContext(ctx)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("解析模型 %T 失败: %w", model, err)
+		}
+		deletedAt := softDeleteField(stmt.Schema)
+		if deletedAt == nil {
+			continue
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if idx.Class != "UNIQUE" || idx.Where != "" {
+				continue
+			}
+			if err := migrateSoftDeleteUnique(db, model, stmt.Table, idx, deletedAt); err != nil {
+				return fmt.Errorf("迁移唯一索引 %s 失败: %w", idx.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func migrateSoftDeleteUnique(db *gorm.DB, model any, table string, idx schema.Index, deletedAt *schema.Field) error {
+	schemaName, _, err := splitTableName(db, model)
+	if err != nil {
+		return err
+	}
+	var partial []bool
+	err = db.Raw(`SELECT i.indpred IS NOT NULL FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = ? AND n.nspname = COALESCE(?, current_schema())`, idx.Name, schemaName).Scan(&partial).Error
+	if err != nil {
+		return err
+	}
+	if len(partial) > 0 && partial[0] {
+		return nil
+	}
+
+	columns := make([]string, len(idx.Fields))
+	for i, f := range idx.Fields {
+		columns[i] = f.Expression
+		if columns[i] == "" {
+			columns[i] = db.Statement.Quote(f.DBName)
+		}
+	}
+	name := idx.Name
+	if schemaName != nil {
+		name = *schemaName + "." + name
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP INDEX IF EXISTS ?", clause.Table{Name: name}).Error; err != nil {
+			return err
+		}
+		err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX ? ON ? (%s) WHERE ? IS NULL", strings.Join(columns, ", ")),
+			clause.Column{Name: idx.Name}, clause.Table{Name: table}, clause.Column{Name: deletedAt.DBName}).Error
+		if err != nil {
+			return err
+		}
+		log.Printf("唯一索引 %s 已改为只约束未删除的行", idx.Name)
+		return nil
+	})
+}
+
+// softDeleteConflictTarget 带软删除字段的模型在 ON CONFLICT 中需要附带 deleted_at IS NULL，
+// 才能匹配 MigrateSoftDeleteUniques 建立的部分唯一索引（对普通唯一索引同样成立）
+func softDeleteConflictTarget(s *schema.Schema) clause.Where {
+	f := softDeleteField(s)
+	if f == nil {
+		return clause.Where{}
+	}
+	return clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Name: f.DBName}, Value: nil}}}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ageBucketWidth = 10 // 年龄分布的区间宽度
+	signupDays     = 30 // 每日注册量统计最近的天数（含今天）
+)
+
+// UserStats 用户统计，供管理后台展示；只统计未删除的用户
+type UserStats struct {
+	Total         int64         `json:"total"`
+	ByStatus      []StatusCount `json:"by_status"`
+	AvgAge        float64       `json:"avg_age"`
+	MedianAge     float64       `json:"median_age"`
+	AgeBuckets    []AgeBucket   `json:"age_buckets"`     // 按 10 岁分段，没有用户的区间不返回
+	SignupsPerDay []Bucket      `json:"signups_per_day"` // 最近 30 天，没有注册的日期不返回
+}
+
+// StatusCount 各状态的用户数
+type StatusCount struct {
+	Status UserStatus `json:"status"`
+	Count  int64      `json:"count"`
+}
+
+// AgeBucket 年龄区间 [From, To] 内的用户数
+type AgeBucket struct {
+	From  int   `json:"from"`
+	To    int   `json:"to"`
+	Count int64 `json:"count"`
+}
+
+// Stats 汇总用户统计：总数与年龄的平均值/中位数一次查询得到，状态与年龄分布各一次分组查询，每日注册量按天分桶
+func (r *userRepository) Stats(ctx context.Context) (*UserStats, error) {
+	return invoke(ctx, r.BaseRepository, "Stats", OpRead, func(ctx context.Context) (*UserStats, error) {
+		stats := &UserStats{}
+		db := r.session(ctx).Model(&User{})
+
+		var summary struct {
+			Total     int64
+			AvgAge    float64
+			MedianAge float64
+		}
+		err := db.Session(&gorm.Session{}).Select(`COUNT(*) AS total, COALESCE(AVG(age), 0) AS avg_age,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY age), 0) AS median_age`).Scan(&summary).Error
+		if err != nil {
+			return nil, fmt.Errorf("统计用户年龄失败: %w", err)
+		}
+		stats.Total, stats.AvgAge, stats.MedianAge = summary.Total, summary.AvgAge, summary.MedianAge
+
+		err = db.Session(&gorm.Session{}).Select("status, COUNT(*) AS count").Group("status").Order("status").
+			Scan(&stats.ByStatus).Error
+		if err != nil {
+			return nil, fmt.Errorf("按状态统计用户失败: %w", err)
+		}
+
+		err = db.Session(&gorm.Session{}).
+			Select(fmt.Sprintf(`age / %[1]d * %[1]d AS "from", age / %[1]d * %[1]d + %[2]d AS "to", COUNT(*) AS count`,
+				ageBucketWidth, ageBucketWidth-1)).
+			Group(`"from", "to"`).Order(`"from"`).Scan(&stats.AgeBuckets).Error
+		if err != nil {
+			return nil, fmt.Errorf("统计年龄分布失败: %w", err)
+		}
+
+		since := PartitionDaily.partitionStart(time.Now()).AddDate(0, 0, 1-signupDays)
+		stats.SignupsPerDay, err = r.CountByInterval(ctx, "created_at", BucketDay, Spec{Where("created_at >= ?", since)})
+		if err != nil {
+			return nil, err
+		}
+		return stats, nil
+	})
+}
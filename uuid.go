@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UUIDModel 使用 UUID 主键的基础模型，主键由数据库 gen_random_uuid() 生成，创建后通过 RETURNING 回填
+// 嵌入后配合 NewBaseRepository[T, string] 使用
+type UUIDModel struct {
+	ID        string         `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" example:"0b6f1c5e-8d7a-4c3b-9f2e-1a2b3c4d5e6f"`
+	CreatedAt time.Time      `example:"2023-01-01T00:00:00Z"`
+	UpdatedAt time.Time      `example:"2023-01-01T00:00:00Z"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// EnsureUUIDGeneration 确保数据库提供 gen_random_uuid()
+// PostgreSQL 13+ 内置该函数，更早的版本需要 pgcrypto 扩展
+func EnsureUUIDGeneration(db *gorm.DB) error {
+	var exists bool
+	if err := db.Raw("SELECT to_regproc('gen_random_uuid') IS NOT NULL").Scan(&exists).Error; err != nil {
+		return fmt.Errorf("检查 gen_random_uuid 失败: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	return ensureExtension(db, "pgcrypto")
+}
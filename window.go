@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Window 窗口定义，PartitionBy 为分组列或表达式，OrderBy 为组内排序，如 "created_at DESC"
+type Window struct {
+	PartitionBy []string
+	OrderBy     []string
+}
+
+func (w Window) String() string {
+	var parts []string
+	if len(w.PartitionBy) > 0 {
+		parts = append(parts, "PARTITION BY "+strings.Join(w.PartitionBy, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		parts = append(parts, "ORDER BY "+strings.Join(w.OrderBy, ", "))
+	}
+	return strings.Join(parts, " ")
+}
+
+// RowNumberOver 组内行号，同值也不并列
+func RowNumberOver(w Window) string { return "row_number() OVER (" + w.String() + ")" }
+
+// RankOver 组内排名，同值并列并跳过后续名次（1, 1, 3）
+func RankOver(w Window) string { return "rank() OVER (" + w.String() + ")" }
+
+// DenseRankOver 组内排名，同值并列且名次连续（1, 1, 2）
+func DenseRankOver(w Window) string { return "dense_rank() OVER (" + w.String() + ")" }
+
+// TopPerGroup 只保留窗口排名不超过 n 的行，rank 为 RowNumberOver/RankOver/DenseRankOver 的结果；
+// 排名在满足其他过滤条件的行中计算。例如每个年龄段最新注册的 3 个用户：
+//
+//	repo.Find(ctx, Where("status = ?", UserStatusActive),
+//		TopPerGroup(RowNumberOver(Window{PartitionBy: []string{"age / 10"}, OrderBy: []string{"created_at DESC"}}), 3))
+func TopPerGroup(rank string, n int) QueryOption {
+	return Where(windowFilter{rank: rank, n: n})
+}
+
+// LatestPerGroup 每组只保留 orderBy 排序后的第一行，如按 email 去重取最新的一条：
+//
+//	repo.Find(ctx, LatestPerGroup("email", "created_at DESC"))
+func LatestPerGroup(partitionBy, orderBy string) QueryOption {
+	return TopPerGroup(RowNumberOver(Window{PartitionBy: []string{partitionBy}, OrderBy: []string{orderBy}}), 1)
+}
+
+// windowFilter 生成 ctid IN (SELECT ctid FROM (SELECT ctid, <rank> ... WHERE <其他条件>) WHERE rank <= n)；
+// 在构建 SQL 时才读取语句的表名与其他 WHERE 条件（包括软删除条件），与选项的先后顺序无关
+type windowFilter struct {
+	rank string
+	n    int
+}
+
+func (w windowFilter) Build(builder clause.Builder) {
+	var others []clause.Expression
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		if c, ok := stmt.Clauses["WHERE"]; ok {
+			if where, ok := c.Expression.(clause.Where); ok {
+				for _, e := range where.Exprs {
+					if _, self := e.(windowFilter); !self {
+						others = append(others, e)
+					}
+				}
+			}
+		}
+	}
+
+	builder.WriteQuoted(clause.Column{Table: clause.CurrentTable, Name: "ctid"})
+	builder.WriteString(" IN (SELECT ctid FROM (SELECT ctid, " + w.rank + " AS window_rank FROM ")
+	builder.WriteQuoted(clause.Table{Name: clause.CurrentTable})
+	if len(others) > 0 {
+		builder.WriteString(" WHERE ")
+		clause.Where{Exprs: others}.Build(builder)
+	}
+	builder.WriteString(") AS ranked WHERE window_rank <= ")
+	builder.AddVar(builder, w.n)
+	builder.WriteByte(')')
+}